@@ -1,11 +1,31 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/spf13/cobra"
+
+	"simplescript/internal/buildconfig"
+)
+
+var (
+	// what the flags were set to; only flags given explicitly override the manifest
+	flagConfig = buildconfig.Default()
+
+	// the options `build` and `wasm` run the toolchains with
+	buildConfig = buildconfig.Default()
 )
 
 func init() {
 	rootCmd.AddCommand(buildCmd)
+	addKeepGoFlag(buildCmd)
+	addUncheckedFlag(buildCmd)
+	addOutputFlag(buildCmd, &flagConfig.Output, "<stem>")
+	buildCmd.Flags().StringVar(&flagConfig.GOOS, "goos", "", "operating system to cross-compile for (with --goarch)")
+	buildCmd.Flags().StringVar(&flagConfig.GOARCH, "goarch", "", "architecture to cross-compile for (with --goos)")
 }
 
 var buildCmd = &cobra.Command{
@@ -14,6 +34,67 @@ var buildCmd = &cobra.Command{
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		filename := args[0]
+		buildConfig = resolveBuildConfig(cmd, filename)
 		processSource("build", filename)
 	},
 }
+
+// output is the field of the command's own output, Output or WasmOutput
+func addOutputFlag(cmd *cobra.Command, output *string, defaultName string) {
+	cmd.Flags().StringVarP(output, "output", "o", "", "output file (default "+defaultName+")")
+}
+
+// the TinyGo options of `wasm`; their defaults come from buildconfig.Default
+func addTinyGoFlags(cmd *cobra.Command) {
+	list := func(values []string) string { return strings.Join(values, ", ") }
+
+	cmd.Flags().StringVar(&flagConfig.Target, "target", flagConfig.Target, "wasm flavour: "+list(buildconfig.Targets))
+	cmd.Flags().StringVar(&flagConfig.Opt, "opt", flagConfig.Opt, "TinyGo optimization level: "+list(buildconfig.OptLevels))
+	cmd.Flags().StringVar(&flagConfig.GC, "gc", flagConfig.GC, "TinyGo garbage collector: "+list(buildconfig.GCModes))
+	cmd.Flags().StringVar(&flagConfig.Scheduler, "scheduler", flagConfig.Scheduler, "TinyGo goroutine scheduler: "+list(buildconfig.Schedulers))
+	cmd.Flags().StringVar(&flagConfig.Panic, "panic", flagConfig.Panic, "TinyGo panic strategy: "+list(buildconfig.PanicStrategies))
+	cmd.Flags().BoolVar(&flagConfig.Debug, "debug", false, "keep debug information in the module")
+}
+
+// layers the flags given on the command line over the manifest next to
+// filename, over the defaults
+func resolveBuildConfig(cmd *cobra.Command, filename string) buildconfig.Config {
+	cfg := buildconfig.Default()
+	if err := buildconfig.LoadManifest(filepath.Dir(filename), &cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("output") { cfg.Output, cfg.WasmOutput = flagConfig.Output, flagConfig.WasmOutput }
+	if flags.Changed("target") { cfg.Target = flagConfig.Target }
+	if flags.Changed("opt") { cfg.Opt = flagConfig.Opt }
+	if flags.Changed("gc") { cfg.GC = flagConfig.GC }
+	if flags.Changed("scheduler") { cfg.Scheduler = flagConfig.Scheduler }
+	if flags.Changed("panic") { cfg.Panic = flagConfig.Panic }
+	if flags.Changed("debug") { cfg.Debug = flagConfig.Debug }
+	if flags.Changed("goos") { cfg.GOOS = flagConfig.GOOS }
+	if flags.Changed("goarch") { cfg.GOARCH = flagConfig.GOARCH }
+	if flags.Changed("bundle") { cfg.Bundle = flagConfig.Bundle }
+
+	// only wasm writes bundles, so the manifest's one does not bother build
+	if flags.Lookup("bundle") == nil {
+		cfg.Bundle = ""
+	}
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	return cfg
+}
+
+// the output path as the success messages print it
+func displayPath(path string) string {
+	if filepath.IsAbs(path) || strings.HasPrefix(path, ".") {
+		return path
+	}
+
+	return "./" + path
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"simplescript/internal/backend"
+)
+
+var cOutput string
+
+func init() {
+	rootCmd.AddCommand(cCmd)
+	cCmd.Flags().StringVarP(&cOutput, "output", "o", "", "output file (default <stem>.c)")
+}
+
+var cCmd = &cobra.Command{
+	Use: "c [file.ss]",
+	Short: "Transpile to portable C99 plus the simplescript.h runtime header",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+		program, diags := analyzeSource(filename, readSource(filename))
+		reportDiagnostics(diags)
+
+		output := cOutput
+		if output == "" {
+			output = strings.TrimSuffix(filepath.Base(filename), ".ss") + ".c"
+		}
+
+		code, err := backend.GenerateC(mustBuildIR(program))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Generation Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// the generated file includes the header by a relative path
+		mustWriteFile(output, code)
+		mustWriteFile(filepath.Join(filepath.Dir(output), backend.CRuntimeHeaderName), backend.CRuntimeHeader)
+		fmt.Fprintf(os.Stderr, "✓ C written: ./%s (build with: cc -std=c99 %s -lm)\n", output, output)
+	},
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"simplescript/internal/diagnostic"
+)
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+var checkCmd = &cobra.Command{
+	Use: "check [files or directories...]",
+	Short: "Report errors without generating or building any code",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		checkPaths(args)
+	},
+}
+
+func checkPaths(paths []string) {
+	files, ok := collectSourceFiles(paths)
+	diags := []diagnostic.Diagnostic{}
+
+	for _, filename := range files {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Cannot read file '%s': %v\n", filename, err)
+			ok = false
+			continue
+		}
+
+		_, fileDiags := analyzeSource(filename, string(source))
+		diags = append(diags, fileDiags...)
+	}
+
+	writeDiagnostics(diags)
+
+	if !ok || diagnostic.HasErrors(diags) {
+		os.Exit(1)
+	}
+
+	if diagnosticsFormat == "text" {
+		fmt.Fprintf(os.Stderr, "✓ %d file(s) checked, no errors found\n", len(files))
+	}
+}
+
+// expands directories into the .ss files they contain, recursively
+func collectSourceFiles(paths []string) ([]string, bool) {
+	files := []string{}
+	ok := true
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			ok = false
+			continue
+		}
+
+		if !info.IsDir() {
+			if !strings.HasSuffix(path, ".ss") {
+				fmt.Fprintf(os.Stderr, "Error: File '%s' must have .ss extension\n", path)
+				ok = false
+				continue
+			}
+
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if d.IsDir() && p != path && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+
+			if !d.IsDir() && strings.HasSuffix(p, ".ss") {
+				files = append(files, p)
+			}
+
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			ok = false
+		}
+	}
+
+	return files, ok
+}
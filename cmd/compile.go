@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"simplescript/internal/ast"
+	"simplescript/internal/backend"
+	"simplescript/internal/bytecode"
+	"simplescript/internal/vm"
+)
+
+var compileOutput string
+
+func init() {
+	rootCmd.AddCommand(compileCmd)
+	compileCmd.Flags().StringVarP(&compileOutput, "output", "o", "", "output file (default <stem>.ssc)")
+}
+
+var compileCmd = &cobra.Command{
+	Use: "compile [file.ss]",
+	Short: "Precompile to portable bytecode (.ssc) for the built-in VM",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+		program, diags := analyzeSource(filename, readSource(filename))
+		reportDiagnostics(diags)
+
+		output := compileOutput
+		if output == "" {
+			output = strings.TrimSuffix(filepath.Base(filename), ".ss") + ".ssc"
+		}
+
+		var buf bytes.Buffer
+		if err := bytecode.Encode(&buf, mustCompileBytecode(program)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		mustWriteFile(output, buf.String())
+		fmt.Fprintf(os.Stderr, "✓ Bytecode written: ./%s\n", output)
+	},
+}
+
+func mustCompileBytecode(program *ast.Program) *bytecode.Chunk {
+	chunk, err := backend.CompileBytecode(mustBuildIR(program))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Compilation Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	return chunk
+}
+
+// executes a precompiled .ssc file on the VM
+func runBytecodeFile(filename string) {
+	file, err := os.Open(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: Cannot read file '%s': %v\n", filename, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	chunk, err := bytecode.Decode(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: '%s': %v\n", filename, err)
+		os.Exit(1)
+	}
+
+	if err := vm.NewVM(chunk, os.Stdout).WithArgs(programArgs).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
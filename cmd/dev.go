@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"simplescript/internal/backend"
+	"simplescript/internal/buildconfig"
+	"simplescript/internal/devserver"
+	"simplescript/internal/diagnostic"
+	"simplescript/internal/ir"
+)
+
+var devAddr string
+
+func init() {
+	rootCmd.AddCommand(devCmd)
+	addUncheckedFlag(devCmd)
+	addTinyGoFlags(devCmd)
+	addWasmBackendFlag(devCmd)
+	devCmd.Flags().StringVar(&devAddr, "addr", "localhost:8080", "address the development server listens on")
+}
+
+var devCmd = &cobra.Command{
+	Use: "dev [file.ss]",
+	Short: "Serve a browser bundle that rebuilds and reloads as the sources change",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+		readSource(filename)
+		buildConfig = resolveBuildConfig(cmd, filename)
+
+		if buildConfig.Target != "wasm" {
+			fmt.Fprintf(os.Stderr, "Error: dev serves browser bundles, which need the wasm target, got '%s'\n", buildConfig.Target)
+			os.Exit(1)
+		}
+		checkWasmBackend(cmd)
+
+		if !serveDev(filename) {
+			os.Exit(1)
+		}
+	},
+}
+
+// serves until interrupted; reports whether it shut down cleanly
+func serveDev(filename string) bool {
+	root, err := os.MkdirTemp("", "simplescript-dev-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	defer os.RemoveAll(root)
+
+	server := devserver.New(root, devBuilder(filename, filepath.Join(root, "gen")))
+	rebuild := func() {
+		start := time.Now()
+		if err := server.Rebuild(); err != nil {
+			fmt.Fprint(os.Stderr, strings.TrimSuffix(err.Error(), "\n")+"\n")
+			return
+		}
+		fmt.Fprintf(os.Stderr, "✓ Built %s in %s\n", filename, time.Since(start).Round(time.Millisecond))
+	}
+	rebuild()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// the manifest can change the build options, but they are read once at start
+	patterns := []string{"*.ss", buildconfig.ManifestName}
+	go devserver.Watch(ctx, filepath.Dir(filename), patterns, 250*time.Millisecond, rebuild)
+
+	httpServer := &http.Server{Addr: devAddr, Handler: server}
+	failed := make(chan error, 1)
+	go func() { failed <- httpServer.ListenAndServe() }()
+
+	fmt.Fprintf(os.Stderr, "Serving http://%s/ (Ctrl+C to stop)\n", devAddr)
+
+	select {
+	case err := <-failed:
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	case <-ctx.Done():
+		// open event streams never end on their own
+		shutdown, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdown)
+		return true
+	}
+}
+
+// Builds the bundle like `wasm --bundle`, but returns the diagnostics or the
+// toolchain's output instead of exiting, so the server can show them.
+// Rebuilds are incremental: the module is only rebuilt when the IR or the
+// generated Go changed, and TinyGo always builds in goDir, outside the
+// served directories, so its cache keeps the runtime library compiled.
+func devBuilder(filename, goDir string) devserver.Builder {
+	stem := strings.TrimSuffix(filepath.Base(filename), ".ss")
+	cache := &devCache{goDir: goDir}
+
+	return func(dir string) error {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		program, diags := analyzeSource(filename, string(source))
+		if diagnostic.HasErrors(diags) {
+			var text strings.Builder
+			diagnostic.WriteText(&text, diags, false)
+			return errors.New(text.String())
+		}
+
+		lowered, err := ir.Build(program)
+		if err == nil {
+			err = ir.Verify(lowered)
+		}
+		if err != nil {
+			return fmt.Errorf("IR Error: %v", err)
+		}
+
+		output := filepath.Join(dir, stem+".wasm")
+		wasmExec := ""
+
+		if wasmBackend == "native" {
+			err = cache.buildNative(lowered, output)
+		} else if wasmExec, err = cache.wasmExec(); err == nil {
+			err = cache.buildTinyGo(lowered, output)
+		}
+		if err != nil {
+			return err
+		}
+
+		return backend.WriteBundle(dir, stem, wasmExec, lowered)
+	}
+}
+
+// what the last dev build produced, for the next one to reuse
+type devCache struct {
+	goDir string
+	key string // the IR or Go code the module was built from
+	module []byte
+	bridge string // TinyGo's wasm_exec.js
+}
+
+// native modules record no positions, so they only depend on the IR listing
+func (c *devCache) buildNative(lowered *ir.Program, output string) error {
+	var key strings.Builder
+	ir.Fprint(&key, lowered)
+
+	return c.build("ir\n"+key.String(), output, func() error {
+		return buildNativeModule(lowered, output)
+	})
+}
+
+// the module depends on the Go code, which also carries the positions
+// runtime errors report
+func (c *devCache) buildTinyGo(lowered *ir.Program, output string) error {
+	code, err := backend.Generate(lowered, backend.GoOptions{Unchecked: unchecked, Exports: true})
+	if err != nil {
+		return fmt.Errorf("Generation Error: %v", err)
+	}
+
+	return c.build("go\n"+code, output, func() error {
+		if err := backend.WriteGoProgram(c.goDir, code); err != nil {
+			return err
+		}
+
+		cmd := exec.Command("tinygo", buildConfig.TinyGoArgs(output)...)
+		cmd.Dir = c.goDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("TinyGo error: %v\n%s", err, out)
+		}
+
+		return nil
+	})
+}
+
+// writes the module built from key to output, running build only when the
+// last build started from a different key
+func (c *devCache) build(key, output string, build func() error) error {
+	if c.module != nil && key == c.key {
+		return os.WriteFile(output, c.module, 0644)
+	}
+
+	c.module = nil
+	if err := build(); err != nil {
+		return err
+	}
+
+	module, err := os.ReadFile(output)
+	if err != nil {
+		return err
+	}
+
+	c.key, c.module = key, module
+	return nil
+}
+
+// TinyGo's bridge, located once
+func (c *devCache) wasmExec() (string, error) {
+	if c.bridge == "" {
+		bridge, err := tinygoWasmExec()
+		if err != nil {
+			return "", err
+		}
+		c.bridge = bridge
+	}
+
+	return c.bridge, nil
+}
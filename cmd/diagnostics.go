@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"simplescript/internal/diagnostic"
+)
+
+var diagnosticsFormat string
+
+// prints the diagnostics in the selected format and exits when any of them is an error
+func reportDiagnostics(diags []diagnostic.Diagnostic) {
+	writeDiagnostics(diags)
+
+	if diagnostic.HasErrors(diags) {
+		os.Exit(1)
+	}
+}
+
+// machine readable formats go to stdout, human output goes to stderr.
+// Machine readable formats always write a log, which is empty on a clean
+// run; the text format writes nothing then.
+func writeDiagnostics(diags []diagnostic.Diagnostic) {
+	format, err := diagnostic.ParseFormat(diagnosticsFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+
+	if format == diagnostic.Text {
+		if len(diags) > 0 {
+			diagnostic.WriteText(os.Stderr, diags, isTerminal(os.Stderr))
+		}
+	} else {
+		diagnostic.Write(os.Stdout, format, diags, false)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"simplescript/internal/ast"
+	"simplescript/internal/bytecode"
+	"simplescript/internal/frontend/lexer"
+	"simplescript/internal/frontend/parser"
+	"simplescript/internal/ir"
+)
+
+var (
+	emitStage string
+	emitFormat string
+	keepGo bool
+	unchecked bool
+)
+
+func init() {
+	rootCmd.AddCommand(emitCmd)
+	emitCmd.Flags().StringVar(&emitStage, "stage", "go", "what to print: tokens, ast, typed-ast, ir, bytecode or go")
+	emitCmd.Flags().StringVar(&emitFormat, "format", "text", "tree format for the ast stages: text or json")
+	addUncheckedFlag(emitCmd)
+}
+
+// keeps the generated <stem>.gen module directory instead of deleting it
+func addKeepGoFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&keepGo, "keep-go", false, "keep the generated Go module directory")
+}
+
+// drops the index and overflow checks from the generated Go
+func addUncheckedFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&unchecked, "unchecked", false, "generate Go without runtime safety checks")
+}
+
+var emitCmd = &cobra.Command{
+	Use: "emit [file.ss]",
+	Short: "Print the tokens, AST, IR or generated Go for a file",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+		sourceCode := readSource(filename)
+
+		switch emitStage {
+		case "tokens":
+			emitTokens(filename, sourceCode)
+		case "ast":
+			p := parser.NewParser(lexer.NewFileLexer(filename, sourceCode))
+			program, err := p.Parse()
+			if err != nil {
+				reportDiagnostics(p.Errors())
+			}
+
+			emitTree(program, false)
+		case "typed-ast":
+			program, diags := analyzeSource(filename, sourceCode)
+			reportDiagnostics(diags)
+			emitTree(program, true)
+		case "ir":
+			program, diags := analyzeSource(filename, sourceCode)
+			reportDiagnostics(diags)
+			ir.Fprint(os.Stdout, mustBuildIR(program))
+		case "bytecode":
+			program, diags := analyzeSource(filename, sourceCode)
+			reportDiagnostics(diags)
+			bytecode.Disassemble(os.Stdout, mustCompileBytecode(program))
+		case "go":
+			program, diags := analyzeSource(filename, sourceCode)
+			reportDiagnostics(diags)
+			fmt.Print(generateGo(program))
+		default:
+			fmt.Fprintf(
+				os.Stderr,
+				"Error: unknown stage '%s' (expected tokens, ast, typed-ast, ir, bytecode or go)\n",
+				emitStage,
+			)
+			os.Exit(2)
+		}
+	},
+}
+
+func emitTokens(filename, sourceCode string) {
+	l := lexer.NewFileLexer(filename, sourceCode)
+
+	for _, tok := range l.Tokenize() {
+		fmt.Printf("%d:%d\t%s\t%q\n", tok.Line, tok.Col, tok.Tag, tok.Slice)
+	}
+
+	reportDiagnostics(l.Errors())
+}
+
+func emitTree(program *ast.Program, typed bool) {
+	var err error
+
+	switch emitFormat {
+	case "text":
+		err = ast.Fprint(os.Stdout, program, typed)
+	case "json":
+		err = ast.FprintJSON(os.Stdout, program, typed)
+	default:
+		err = fmt.Errorf("unknown format '%s' (expected text or json)", emitFormat)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"simplescript/internal/backend"
+)
+
+var jsOutput string
+
+func init() {
+	rootCmd.AddCommand(jsCmd)
+	jsCmd.Flags().StringVarP(&jsOutput, "output", "o", "", "output file (default <stem>.js)")
+}
+
+var jsCmd = &cobra.Command{
+	Use: "js [file.ss]",
+	Short: "Transpile to a JavaScript ES module with a source map",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filename := args[0]
+		program, diags := analyzeSource(filename, readSource(filename))
+		reportDiagnostics(diags)
+
+		output := jsOutput
+		if output == "" {
+			output = strings.TrimSuffix(filepath.Base(filename), ".ss") + ".js"
+		}
+
+		// the map sits next to the module, so the script is referenced from there
+		source := filename
+		absOutput, errOut := filepath.Abs(output)
+		absSource, errSource := filepath.Abs(filename)
+		if errOut == nil && errSource == nil {
+			if rel, err := filepath.Rel(filepath.Dir(absOutput), absSource); err == nil {
+				source = rel
+			}
+		}
+
+		code, sourceMap, err := backend.GenerateJS(mustBuildIR(program), filepath.Base(output), filepath.ToSlash(source))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Generation Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		mustWriteFile(output, code)
+		mustWriteFile(output+".map", sourceMap)
+		fmt.Fprintf(os.Stderr, "✓ JavaScript written: ./%s\n", output)
+	},
+}
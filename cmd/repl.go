@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"simplescript/internal/repl"
+)
+
+func init() {
+	rootCmd.AddCommand(replCmd)
+}
+
+var replCmd = &cobra.Command{
+	Use: "repl",
+	Short: "Start an interactive session",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := repl.Start(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
@@ -10,9 +10,12 @@ import (
 	"github.com/spf13/cobra"
 
 	"simplescript/internal/analyzer"
+	"simplescript/internal/ast"
 	"simplescript/internal/backend"
+	"simplescript/internal/diagnostic"
 	"simplescript/internal/frontend/lexer"
 	"simplescript/internal/frontend/parser"
+	"simplescript/internal/ir"
 )
 
 var rootCmd = &cobra.Command{
@@ -22,101 +25,210 @@ var rootCmd = &cobra.Command{
 	Long: `A fast and easy-to-use language that transpiles to Go and WebAssembly. Built with love for the modern web.`,
 }
 
+func init() {
+	rootCmd.PersistentFlags().StringVar(
+		&diagnosticsFormat,
+		"diagnostics",
+		"text",
+		"diagnostics output format: text, json or sarif",
+	)
+	diagnostic.ToolVersion = rootCmd.Version
+}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
 }
 
-func processSource(command, filename string) {
+// Generates Go for the script, builds or runs it as command asks and
+// returns the lowered program; wasm modules also export the exported functions
+func processSource(command, filename string) *ir.Program {
 	sourceCode := readSource(filename)
 	stem := strings.TrimSuffix(filepath.Base(filename), ".ss")
-	tempFile := stem + ".gen.go"
 
 	// Frontend
-	tokens := lexer.MustTokenize(sourceCode)
-	program := parser.MustParse(tokens)
-	program = analyzer.MustAnalyze(program)
+	program, diags := analyzeSource(filename, sourceCode)
+	reportDiagnostics(diags)
 
 	// Backend
-	compiledProgram := backend.MustCompile(program)
-	generatedCode := backend.MustGenerate(compiledProgram, program)
+	lowered := mustBuildIR(program)
+	generatedCode := backend.MustGenerate(lowered, backend.GoOptions{Unchecked: unchecked, Exports: command == "wasm"})
+
+	tempDir, err := goModuleDir(stem)
+	if err == nil {
+		err = backend.WriteGoProgram(tempDir, generatedCode)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing generated program: %v\n", err)
+		os.Exit(1)
+	}
+
+	ok := handleCompletion(command, tempDir, stem)
+
+	// removed before exiting, since os.Exit skips deferred calls
+	if keepGo {
+		fmt.Fprintf(os.Stderr, "Generated Go kept at ./%s/\n", tempDir)
+	} else {
+		os.RemoveAll(tempDir)
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+
+	return lowered
+}
+
+// The directory receiving the generated module: a fresh temporary one, so
+// concurrent runs never share it and removing it cannot touch the user's
+// files, or ./<stem>.gen when --keep-go asks to look at it afterwards
+func goModuleDir(stem string) (string, error) {
+	if keepGo {
+		return stem + ".gen", nil
+	}
+
+	return os.MkdirTemp("", "simplescript-"+stem+"-")
+}
+
+func generateGo(program *ast.Program) string {
+	return backend.MustGenerate(mustBuildIR(program), backend.GoOptions{Unchecked: unchecked})
+}
+
+// lowers the analyzed program to the IR every backend consumes
+func mustBuildIR(program *ast.Program) *ir.Program {
+	lowered, err := ir.Build(program)
+	if err == nil {
+		err = ir.Verify(lowered)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "IR Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	return lowered
+}
+
+// runs the lexer, parser and analyzer, collecting every diagnostic found
+func analyzeSource(filename, sourceCode string) (*ast.Program, []diagnostic.Diagnostic) {
+	p := parser.NewParser(lexer.NewFileLexer(filename, sourceCode))
+	program, err := p.Parse()
+	if err != nil {
+		return nil, p.Errors()
+	}
 
-	mustWriteFile(tempFile, generatedCode)
-	defer os.Remove(tempFile)
+	a := analyzer.NewAnalyzer()
+	a.Analyze(program)
 
-	handleCompletion(command, tempFile, stem)
+	return program, a.Errors()
 }
 
-func handleCompletion(command, tempFile, stem string) {
+// Builds the generated module in tempDir; reports whether the command
+// succeeded, failures are already printed
+func handleCompletion(command, tempDir, stem string) bool {
 	switch command {
 	case "run":
-		runGoCode(tempFile)
+		return runGoCode(tempDir, stem)
 	case "build":
-		buildGoCode(tempFile, stem)
-		fmt.Printf("✓ Build successful: ./%s\n", stem)
+		output := buildConfig.Output
+		if output == "" {
+			output = buildConfig.ExecutableName(stem)
+		}
+
+		if !buildGoCode(tempDir, output, buildConfig.GoEnv()) {
+			return false
+		}
+		fmt.Fprintf(os.Stderr, "✓ Build successful: %s\n", displayPath(output))
 	case "wasm":
-		buildWasmWithTinyGo(tempFile, stem)
-		fmt.Printf("✓ Wasm successful: ./%s.wasm\n", stem)
+		output := buildConfig.WasmOutput
+		if output == "" {
+			output = stem + ".wasm"
+		}
+
+		if !buildWasmWithTinyGo(tempDir, output) {
+			return false
+		}
+		fmt.Fprintf(os.Stderr, "✓ Wasm successful: %s\n", displayPath(output))
 	}
+
+	return true
 }
 
 func readSource(filename string) string {
 	if !strings.HasSuffix(filename, ".ss") {
-		fmt.Println("Error: File must have .ss extension")
+		fmt.Fprintln(os.Stderr, "Error: File must have .ss extension")
 		os.Exit(1)
 	}
 
 	sourceCode, err := os.ReadFile(filename)
 	if err != nil {
-		fmt.Printf("Error: Cannot read file '%s': %v\n", filename, err)
+		fmt.Fprintf(os.Stderr, "Error: Cannot read file '%s': %v\n", filename, err)
 		os.Exit(1)
 	}
 
 	return string(sourceCode)
 }
 
+// the toolchains run inside the generated module, so output paths given
+// relative to the current directory are made absolute; missing parent
+// directories are created
+func mustAbs(path string) string {
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(abs), 0755)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	return abs
+}
+
 func mustWriteFile(filename, content string) {
 	err := os.WriteFile(filename, []byte(content), 0644)
 	if err != nil {
-		fmt.Printf("Error writing temporary file: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error writing temporary file: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func runGoCode(tempFile string) {
-	cmd := exec.Command("go", "run", tempFile)
-	cmd.Stdout, cmd.Stderr, cmd.Stdin = os.Stdout, os.Stderr, os.Stdin
-	if err := cmd.Run(); err != nil {
-		os.Exit(1)
+// builds the program inside tempDir and runs it from the current directory
+func runGoCode(tempDir, stem string) bool {
+	binary := filepath.Join(tempDir, stem)
+	if !buildGoCode(tempDir, binary, nil) {
+		return false
 	}
+
+	cmd := exec.Command(binary, programArgs...)
+	cmd.Stdout, cmd.Stderr, cmd.Stdin = os.Stdout, os.Stderr, os.Stdin
+	return cmd.Run() == nil
 }
 
-func buildGoCode(tempFile, outputName string) {
-	cmd := exec.Command("go", "build", "-o", outputName, tempFile)
+// builds the module in tempDir to output; a nil env is the current one
+func buildGoCode(tempDir, output string, env []string) bool {
+	cmd := exec.Command("go", "build", "-o", mustAbs(output), ".")
+	cmd.Dir, cmd.Env = tempDir, env
 	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
 	if err := cmd.Run(); err != nil {
-		fmt.Printf("Build error: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Build error: %v\n", err)
+		return false
 	}
+
+	return true
 }
 
-func buildWasmWithTinyGo(tempFile, outputName string) {
-	cmd := exec.Command(
-		"tinygo", "build",
-		"-o", outputName+".wasm",
-		"-target", "wasm",
-		"-opt", "z",
-		"-no-debug",
-		"-panic", "trap",
-		"-scheduler", "none",
-		"-gc", "leaking",
-		tempFile,
-	)
+func buildWasmWithTinyGo(tempDir, output string) bool {
+	cmd := exec.Command("tinygo", buildConfig.TinyGoArgs(mustAbs(output))...)
+	cmd.Dir = tempDir
 	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
 
 	if err := cmd.Run(); err != nil {
-		fmt.Printf("TinyGo error: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "TinyGo error: %v\n", err)
+		return false
 	}
+
+	return true
 }
@@ -1,19 +1,59 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/spf13/cobra"
+
+	"simplescript/internal/interpreter"
+)
+
+var (
+	viaGo bool
+
+	// the arguments after the script, which args() returns
+	programArgs []string
 )
 
 func init() {
 	rootCmd.AddCommand(runCmd)
+	addKeepGoFlag(runCmd)
+	addUncheckedFlag(runCmd)
+	runCmd.Flags().BoolVar(&viaGo, "via-go", false, "transpile to Go and execute with 'go run' instead of interpreting")
+	// flags after the script belong to the program
+	runCmd.Flags().SetInterspersed(false)
 }
 
 var runCmd = &cobra.Command{
-	Use: "run [file.ss]",
-	Short: "Transpile and execute immediately",
-	Args: cobra.ExactArgs(1),
+	Use: "run [file.ss | file.ssc] [args...]",
+	Short: "Execute a script immediately",
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		filename := args[0]
-		processSource("run", filename)
+		programArgs = args[1:]
+
+		if strings.HasSuffix(filename, ".ssc") {
+			runBytecodeFile(filename)
+			return
+		}
+
+		if viaGo {
+			processSource("run", filename)
+			return
+		}
+
+		interpretSource(filename)
 	},
 }
+
+func interpretSource(filename string) {
+	program, diags := analyzeSource(filename, readSource(filename))
+	reportDiagnostics(diags)
+
+	if err := interpreter.NewInterpreter(os.Stdout).WithArgs(programArgs).Run(program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
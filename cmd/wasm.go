@@ -1,19 +1,159 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
 	"github.com/spf13/cobra"
+
+	"simplescript/internal/backend"
+	"simplescript/internal/backend/wasm"
+	"simplescript/internal/ir"
 )
 
+var wasmBackend string
+
 func init() {
 	rootCmd.AddCommand(wasmCmd)
+	addKeepGoFlag(wasmCmd)
+	addUncheckedFlag(wasmCmd)
+	addOutputFlag(wasmCmd, &flagConfig.WasmOutput, "<stem>.wasm")
+	addTinyGoFlags(wasmCmd)
+	wasmCmd.Flags().StringVar(&flagConfig.Bundle, "bundle", "", "write the module with an index.html and an ES module loader to this directory")
+	addWasmBackendFlag(wasmCmd)
+}
+
+func addWasmBackendFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(
+		&wasmBackend,
+		"backend",
+		"tinygo",
+		"code generator: tinygo (through Go) or native (direct, no toolchain needed)",
+	)
+}
+
+// Exits unless --backend names a backend that can honour the resolved
+// options. The native backend writes one kind of module by itself: it
+// imports $root.print, so it cannot target WASI, and TinyGo's options
+// would be silently ignored.
+func checkWasmBackend(cmd *cobra.Command) {
+	fail := func(format string, args ...any) {
+		fmt.Fprintf(os.Stderr, "Error: "+format+"\n", args...)
+		os.Exit(1)
+	}
+
+	switch wasmBackend {
+	case "tinygo":
+	case "native":
+		if buildConfig.Target != "wasm" {
+			fail("the native backend only builds the wasm target, got '%s'", buildConfig.Target)
+		}
+
+		for _, name := range []string{"opt", "gc", "scheduler", "panic", "debug"} {
+			if cmd.Flags().Changed(name) {
+				fail("--%s is a TinyGo option and does not apply to --backend=native", name)
+			}
+		}
+	default:
+		fail("unknown wasm backend '%s' (expected tinygo or native)", wasmBackend)
+	}
 }
 
 var wasmCmd = &cobra.Command{
 	Use: "wasm [file.ss]",
-	Short: "Compile to WebAssembly (via TinyGo)",
+	Short: "Compile to WebAssembly (via TinyGo or the native backend)",
 	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		filename := args[0]
-		processSource("wasm", filename)
+		stem := strings.TrimSuffix(filepath.Base(filename), ".ss")
+		buildConfig = resolveBuildConfig(cmd, filename)
+		checkWasmBackend(cmd)
+
+		if buildConfig.Bundle != "" {
+			buildConfig.WasmOutput = filepath.Join(buildConfig.Bundle, stem+".wasm")
+		}
+
+		var lowered *ir.Program
+		if wasmBackend == "native" {
+			lowered = buildNativeWasm(filename)
+		} else {
+			lowered = processSource("wasm", filename)
+		}
+
+		if buildConfig.Bundle != "" {
+			writeBundle(stem, lowered)
+		}
 	},
 }
+
+// writes index.html, the loader with its declarations and, for TinyGo
+// modules, wasm_exec.js next to the module
+func writeBundle(stem string, lowered *ir.Program) {
+	wasmExec := ""
+	if wasmBackend == "tinygo" {
+		var err error
+		if wasmExec, err = tinygoWasmExec(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := backend.WriteBundle(buildConfig.Bundle, stem, wasmExec, lowered); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing bundle: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "✓ Bundle written: %s (serve it and open index.html)\n", displayPath(buildConfig.Bundle))
+}
+
+// the bridge must come from the TinyGo that built the module, since its
+// imports change between versions
+func tinygoWasmExec() (string, error) {
+	root, err := exec.Command("tinygo", "env", "TINYGOROOT").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate TinyGo's wasm_exec.js: %v", err)
+	}
+
+	source, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(root)), "targets", "wasm_exec.js"))
+	if err != nil {
+		return "", fmt.Errorf("cannot read TinyGo's wasm_exec.js: %v", err)
+	}
+
+	return string(source), nil
+}
+
+// lowers the program straight to a module importing `print` from
+// simplescript.wit; checkWasmBackend has rejected the TinyGo options
+func buildNativeWasm(filename string) *ir.Program {
+	program, diags := analyzeSource(filename, readSource(filename))
+	reportDiagnostics(diags)
+
+	output := buildConfig.WasmOutput
+	if output == "" {
+		output = strings.TrimSuffix(filepath.Base(filename), ".ss") + ".wasm"
+	}
+
+	lowered := mustBuildIR(program)
+	if err := buildNativeModule(lowered, mustAbs(output)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "✓ Wasm successful: %s\n", displayPath(output))
+
+	return lowered
+}
+
+func buildNativeModule(lowered *ir.Program, output string) error {
+	binary, err := wasm.Generate(lowered)
+	if err == nil {
+		err = wasm.Validate(binary)
+	}
+	if err != nil {
+		return fmt.Errorf("Generation Error: %v", err)
+	}
+
+	return os.WriteFile(output, binary, 0644)
+}
@@ -3,7 +3,10 @@ module simplescript
 go 1.24.0
 
 require github.com/spf13/cobra v1.10.2 // direct
-require	github.com/dave/jennifer v1.7.1 // direct
+
+require github.com/dave/jennifer v1.7.1 // direct
+
+require github.com/tetratelabs/wazero v1.9.0 // direct
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
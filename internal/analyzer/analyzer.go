@@ -2,39 +2,37 @@ package analyzer
 
 import (
 	"fmt"
-	"os"
 
 	"simplescript/internal/ast"
+	"simplescript/internal/diagnostic"
+	"simplescript/internal/types"
 )
 
 type Analyzer struct {
 	env *Environment
-	errors []string
+	top *Environment // the scope of the program's top-level declarations
+	fn *ast.FuncDecl // the function being analyzed, nil outside functions
+	errors []diagnostic.Diagnostic
 }
 
 func NewAnalyzer() *Analyzer {
 	return &Analyzer {
 		env: NewEnvironment(),
-		errors: []string{},
+		errors: []diagnostic.Diagnostic{},
 	}
 }
 
-func MustAnalyze(prog *ast.Program) *ast.Program {
+// creates an analyzer that declares top-level names into an existing environment
+func NewAnalyzerWithEnvironment(env *Environment) *Analyzer {
 	a := NewAnalyzer()
-	a.analyze(prog)
-
-	if len(a.Errors()) > 0 {
-		fmt.Println("Semantic Errors found:")
-		for _, msg := range a.Errors() {
-			fmt.Printf(" - %s\n", msg)
-		}
-		os.Exit(1)
-	}
-
-	return prog
+	a.env = env
+	return a
 }
 
-func (a *Analyzer) analyze(prog *ast.Program) error {
+func (a *Analyzer) Analyze(prog *ast.Program) error {
+	a.top = a.env
+	a.declareFuncs(prog.Statements)
+
 	for _, stmt := range prog.Statements {
 		a.analyzeStatement(stmt)
 	}
@@ -46,14 +44,27 @@ func (a *Analyzer) analyze(prog *ast.Program) error {
 	return nil
 }
 
-func (a *Analyzer) Errors() []string {
+// infers the type of a standalone expression
+func (a *Analyzer) AnalyzeExpression(expr ast.Expression) (types.Type, error) {
+	dataType := a.analyzeExpression(expr)
+
+	if len(a.errors) > 0 {
+		return dataType, fmt.Errorf("analysis finished with %d errors", len(a.errors))
+	}
+
+	return dataType, nil
+}
+
+func (a *Analyzer) Errors() []diagnostic.Diagnostic {
 	return a.errors
 }
 
-func (a *Analyzer) reportError(token ast.Token, format string, args ...any) {
-	msg := fmt.Sprintf(format, args...)
-	fullMsg := fmt.Sprintf("Semantic Error at line %d, col %d: %s", token.Line, token.Col, msg)
-	a.errors = append(a.errors, fullMsg)
+func (a *Analyzer) report(d diagnostic.Diagnostic) {
+	a.errors = append(a.errors, d)
+}
+
+func (a *Analyzer) reportError(code diagnostic.Code, node ast.Node, format string, args ...any) {
+	a.report(diagnostic.Errorf(code, node.Span(), format, args...))
 }
 
 func (a *Analyzer) analyzeStatement(stmt ast.Statement) {
@@ -64,9 +75,8 @@ func (a *Analyzer) analyzeStatement(stmt ast.Statement) {
 	case *ast.IfStmt: a.analyzeIfStmt(s)
 	case *ast.ForStmt: a.analyzeForStmt(s)
 	case *ast.SayStmt: a.analyzeSayStmt(s)
-	case *ast.ReturnStmt:
-		if s.ReturnValue != nil {
-			a.analyzeExpression(s.ReturnValue)
-		}
+	case *ast.FuncDecl: a.analyzeFuncDecl(s)
+	case *ast.CallStmt: a.analyzeCallStmt(s)
+	case *ast.ReturnStmt: a.analyzeReturn(s)
 	}
 }
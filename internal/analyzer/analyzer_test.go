@@ -0,0 +1,162 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"simplescript/internal/frontend/lexer"
+	"simplescript/internal/frontend/parser"
+)
+
+func analyze(t *testing.T, input string) []string {
+	t.Helper()
+
+	p := parser.NewParser(lexer.NewFileLexer("test.ss", input))
+	program, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+
+	a := NewAnalyzer()
+	a.Analyze(program)
+
+	messages := []string{}
+	for _, d := range a.Errors() {
+		messages = append(messages, d.Message)
+	}
+
+	return messages
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string // empty when the program is valid
+	}{
+		{`var xs: list[int] = [1, 2] xs[0] = 3 var ys: list = xs`, ""},
+		{`var xs: list[list[str]] = [["a"], []] say(xs)`, ""},
+		{`var x: num = 1`, "unknown type 'num'"},
+		{`var xs: list[num] = []`, "unknown type 'num'"},
+		{`var xs: list[int] = [1, "a"]`, "cannot use type 'str' as an element of 'list[int]'"},
+		{`var xs: list[list[int]] = [[1.5]]`, "cannot use type 'float' as an element of 'list[int]'"},
+		{`var xs: list[int] = [1] xs[0] = "a"`, "cannot assign type 'str' to 'int'"},
+		{`var grid: list[list[int]] = [[1]] grid[0][0] = 2 grid[0][0] += 1`, ""},
+		{`var grid: list[list[int]] = [[1]] grid[0][0] = "a"`, "cannot assign type 'str' to 'int'"},
+		{`grid[0][0] = 1`, "undefined variable 'grid'"},
+		{`var x: int = 1 x = 2.5`, "cannot assign type 'float' to 'int'"},
+		{`var m: map[str]int = [1]`, "variables of type 'map[str]int' are not supported yet"},
+		{`var f: func(int) bool = 1`, "variables of type 'func(int) bool' are not supported yet"},
+		{`var p: {x: int, x: str} = 1`, "duplicate field 'x'"},
+		{`var o: int? = 1`, "variables of type 'int?' are not supported yet"},
+		{`var s: str = [1]`, "cannot assign type 'list' to variable of type 'str'"},
+		{`const c: int = 1 c = 2`, "cannot assign to constant 'c'"},
+		{`const c: int = 1 c += 1`, "cannot assign to constant 'c'"},
+		{`var a: int = 1 const b: int = 2 a, b = b, a`, "cannot assign to constant 'b'"},
+		{`const c: int = 1 if true { var c: int = 2 c = 3 }`, ""},
+		{`const xs: list = [1] xs[0] = 2`, ""},
+	}
+
+	for _, tt := range tests {
+		messages := analyze(t, tt.input)
+
+		if tt.expected == "" {
+			if len(messages) > 0 {
+				t.Errorf("%q: unexpected errors %v", tt.input, messages)
+			}
+			continue
+		}
+
+		if len(messages) == 0 || !strings.Contains(messages[0], tt.expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", tt.input, tt.expected, messages)
+		}
+	}
+}
+
+func TestShadowingResolvesToDistinctSymbols(t *testing.T) {
+	if messages := analyze(t, `if true { var v: int = 1 say(v + 1) } if true { var v: str = "a" say(v + "b") }`); len(messages) > 0 {
+		t.Errorf("unexpected errors %v", messages)
+	}
+}
+
+func TestSayOptionsAndBuiltins(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string // empty when the program is valid
+	}{
+		{`var n: int = 2 var s: str = format("{} of {}", n, [n]) say(s, 1, sep=", ", end=s)`, ""},
+		{`say(format("{{}}"))`, ""},
+		{`say(1, sep=2)`, "the 'sep' option of say must be a str, got 'int'"},
+		{`say(1, end=true)`, "the 'end' option of say must be a str, got 'bool'"},
+		{`var s: str = format("{} and {}", 1)`, "format string has 2 placeholders but 1 values were given"},
+		{`var t: str = "{}" var s: str = format(t, 1)`, "the template of format must be a string literal"},
+		{`var s: str = format("{", 1)`, "unclosed '{' in format string"},
+		{`var s: str = format("}")`, "unmatched '}' in format string"},
+		{`var s: str = format()`, "format expects a template string"},
+		{`var n: int = len("a")`, "undefined function 'len'"},
+		{`var n: int = format("{}", 1)`, "cannot assign type 'str' to variable of type 'int'"},
+		{`var a: list = args() var home: str = env("HOME") say(a, home)`, ""},
+		{`var a: list = args(1)`, "args expects no arguments, got 1"},
+		{`var s: str = env()`, "env expects 1 argument, got 0"},
+		{`var s: str = env(1)`, "the name given to env must be a str, got 'int'"},
+		{`var n: int = env("N")`, "cannot assign type 'str' to variable of type 'int'"},
+	}
+
+	for _, tt := range tests {
+		messages := analyze(t, tt.input)
+
+		if tt.expected == "" {
+			if len(messages) > 0 {
+				t.Errorf("%q: unexpected errors %v", tt.input, messages)
+			}
+			continue
+		}
+
+		if len(messages) == 0 || !strings.Contains(messages[0], tt.expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", tt.input, tt.expected, messages)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string // empty when the program is valid
+	}{
+		{`say(add(1, 2)) func add(a: int, b: int) int { return a + b }`, ""},
+		{`export func first(xs: list[int]) int { if true { return xs[0] } return 0 } log("a") func log(s: str) { say(s) return }`, ""},
+		{`func f(n: int) int { if n < 1 { return 0 } else { return f(n - 1) } }`, ""},
+		{`func f() int { say(1) }`, "missing return at the end of function 'f'"},
+		{`func f() int { return }`, "missing return value: function 'f' returns 'int'"},
+		{`func f() { return 1 }`, "function 'f' does not return a value"},
+		{`func f() int { return "a" }`, "cannot return type 'str' from function 'f' returning 'int'"},
+		{`func f(a: int, a: str) {}`, "duplicate parameter 'a'"},
+		{`func f() {} func f() {}`, "function 'f' is already defined in this scope"},
+		{`func say() {}`, "cannot declare function 'say': the name belongs to a builtin"},
+		{`export func run() {}`, "cannot export function 'run': the name is reserved"},
+		{`func f(m: map[str]int) {}`, "parameters of type 'map[str]int' are not supported yet"},
+		{`if true { func f() {} }`, "functions can only be declared at the top level"},
+		{`var n: int = 1 func f() int { return n }`, "undefined variable 'n'"},
+		{`func f() {} var x: int = f()`, "function 'f' does not return a value"},
+		{`func f() {} var g: int = f`, "function 'f' can only be called, not used as a value"},
+		{`func f() {} f = 1`, "cannot assign to function 'f'"},
+		{`func f(a: int) {} f()`, "function 'f' expects 1 arguments, got 0"},
+		{`func f(a: int) {} f("a")`, "cannot use type 'str' as argument 1 of 'f', which takes 'int'"},
+		{`var f: int = 1 f()`, "'f' is a variable of type 'int', not a function"},
+		{`format("{}", 1)`, "the result of format is not used"},
+	}
+
+	for _, tt := range tests {
+		messages := analyze(t, tt.input)
+
+		if tt.expected == "" {
+			if len(messages) > 0 {
+				t.Errorf("%q: unexpected errors %v", tt.input, messages)
+			}
+			continue
+		}
+
+		if len(messages) == 0 || !strings.Contains(messages[0], tt.expected) {
+			t.Errorf("%q: expected an error containing %q, got %v", tt.input, tt.expected, messages)
+		}
+	}
+}
@@ -1,13 +1,19 @@
 package analyzer
 
+import (
+	"simplescript/internal/ast"
+	"simplescript/internal/types"
+)
+
 type Environment struct {
-	store map[string]string
+	store map[string]*ast.Symbol
 	outer *Environment
+	function bool // the parameter scope of a function, which hides outer variables
 }
 
 func NewEnvironment() *Environment {
 	return &Environment {
-		store: make(map[string]string),
+		store: make(map[string]*ast.Symbol),
 		outer: nil,
 	}
 }
@@ -18,14 +24,44 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
-func (e *Environment) Define(name, dataType string) {
-	e.store[name] = dataType
+// declares a new variable, shadowing any outer one with the same name
+func (e *Environment) Define(name string, dataType types.Type) *ast.Symbol {
+	symbol := &ast.Symbol{Name: name, Type: dataType}
+	e.store[name] = symbol
+	return symbol
+}
+
+// creates the scope of a function's parameters, from which only the
+// functions declared around it are visible
+func NewFunctionEnvironment(outer *Environment) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.function = true
+	return env
 }
 
-func (e *Environment) Resolve(name string) (string, bool) {
+func (e *Environment) Resolve(name string) (*ast.Symbol, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
-		return e.outer.Resolve(name)
+		obj, ok = e.outer.Resolve(name)
+		if ok && e.function && obj.Func == nil {
+			return nil, false
+		}
 	}
 	return obj, ok
 }
+
+// like Resolve but also sees the variables outside a function
+func (e *Environment) resolveOutside(name string) (*ast.Symbol, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.resolveOutside(name)
+	}
+	return obj, ok
+}
+
+// copies every name declared directly in other into this environment
+func (e *Environment) Merge(other *Environment) {
+	for name, symbol := range other.store {
+		e.store[name] = symbol
+	}
+}
@@ -1,99 +1,241 @@
 package analyzer
 
 import (
-	"fmt"
-
 	"simplescript/internal/ast"
+	"simplescript/internal/diagnostic"
+	"simplescript/internal/types"
+	"simplescript/internal/value"
 )
 
-func (a *Analyzer) analyzeExpression(expr ast.Expression) string {
+// infers the type of the expression and records it on the node
+func (a *Analyzer) analyzeExpression(expr ast.Expression) types.Type {
+	dataType := a.inferType(expr)
+
+	if expr != nil {
+		expr.SetType(dataType)
+	}
+
+	return dataType
+}
+
+func (a *Analyzer) inferType(expr ast.Expression) types.Type {
 	switch e := expr.(type) {
 	case *ast.IntegerLiteral:
-		return "int"
+		return types.Int
 	case *ast.FloatLiteral:
-		return "float"
+		return types.Float
 	case *ast.StringLiteral:
-		return "str"
+		return types.Str
 	case *ast.BooleanLiteral:
-		return "bool"
+		return types.Bool
 	case *ast.ListLiteral:
 		for _, element := range e.Elements {
 			a.analyzeExpression(element)
 		}
-		return "list"
+		return types.AnyList
 	case *ast.Identifier:
-		if dataType, exists := a.env.Resolve(e.Value); exists {
-			return dataType
+		if symbol, exists := a.env.Resolve(e.Value); exists {
+			if symbol.Func != nil {
+				a.reportError(diagnostic.TypeError, e, "function '%s' can only be called, not used as a value", e.Value)
+				return types.Unknown
+			}
+
+			e.Symbol = symbol
+			return symbol.Type
+		}
+
+		if _, outside := a.env.resolveOutside(e.Value); outside && a.fn != nil {
+			a.report(diagnostic.Errorf(
+				diagnostic.NameError,
+				e.Span(),
+				"undefined variable '%s'",
+				e.Value,
+			).WithHint("functions only see their parameters and their own variables; pass '%s' as a parameter", e.Value))
+			return types.Unknown
 		}
-		a.reportError(e.Token, "undefined variable '%s'", e.Value)
-		return "unknown"
+
+		a.report(diagnostic.Errorf(
+			diagnostic.NameError,
+			e.Span(),
+			"undefined variable '%s'",
+			e.Value,
+		).WithHint("declare it first, e.g. 'var %s: int = 0'", e.Value))
+		return types.Unknown
 	case *ast.PrefixExpression: return a.analyzePrefix(e)
 	case *ast.InfixExpression: return a.analyzeInfix(e)
+	case *ast.IndexExpression:
+		// list elements are dynamically typed
+		a.analyzeExpression(e.Left)
+		a.analyzeExpression(e.Index)
+		return types.Unknown
+	case *ast.CallExpression:
+		if result := a.analyzeCall(e); result != nil {
+			return result
+		}
+
+		a.reportError(diagnostic.TypeError, e, "function '%s' does not return a value", e.Function)
+		return types.Unknown
 	}
-	return "unknown"
+	return types.Unknown
 }
 
-func (a *Analyzer) analyzePrefix(node *ast.PrefixExpression) string {
+func (a *Analyzer) analyzePrefix(node *ast.PrefixExpression) types.Type {
 	rightType := a.analyzeExpression(node.Right)
 
-	if rightType == "unknown" { return "unknown" }
+	if types.IsUnknown(rightType) { return types.Unknown }
 
 	switch node.Operator {
 	case "-":
-		if rightType != "int" && rightType != "float" {
-			msg := fmt.Sprintf("invalid operation: cannot use '-' on type '%s'", rightType)
-			a.errors = append(a.errors, msg)
+		if !types.IsNumeric(rightType) {
+			a.reportError(diagnostic.TypeError, node, "invalid operation: cannot use '-' on type '%s'", rightType)
 		}
 		return rightType
 	case "!":
-		if rightType != "bool" {
-			msg := fmt.Sprintf("invalid operation: cannot use '!' on type '%s'", rightType)
-			a.errors = append(a.errors, msg)
+		if rightType != types.Bool {
+			a.reportError(diagnostic.TypeError, node, "invalid operation: cannot use '!' on type '%s'", rightType)
 		}
-		return "bool"
+		return types.Bool
 	}
 
-	return "unknown"
+	return types.Unknown
 }
 
-func (a *Analyzer) analyzeInfix(node *ast.InfixExpression) string {
+func (a *Analyzer) analyzeInfix(node *ast.InfixExpression) types.Type {
 	leftType := a.analyzeExpression(node.Left)
 	rightType := a.analyzeExpression(node.Right)
 
-	if leftType == "unknown" || rightType == "unknown" {
-		return "unknown"
+	if types.IsUnknown(leftType) || types.IsUnknown(rightType) {
+		return types.Unknown
 	}
 
 	if node.Operator == "==" || node.Operator == "!=" || node.Operator == "<" ||
 		node.Operator == ">" || node.Operator == "<=" || node.Operator == ">=" {
-		if leftType != rightType {
-			msg := fmt.Sprintf("type mismatch: cannot compare '%s' with '%s'", leftType, rightType)
-			a.errors = append(a.errors, msg)
+		if !types.AssignableTo(leftType, rightType) && !types.AssignableTo(rightType, leftType) {
+			a.reportError(
+				diagnostic.TypeError,
+				node,
+				"type mismatch: cannot compare '%s' with '%s'",
+				leftType,
+				rightType,
+			)
 		}
 
-		return "bool"
+		return types.Bool
 	}
 
 	if node.Operator == "+" || node.Operator == "-" || node.Operator == "*" || node.Operator == "/" {
-		if leftType != rightType {
-			msg := fmt.Sprintf(
+		if !types.Identical(leftType, rightType) {
+			a.reportError(
+				diagnostic.TypeError,
+				node,
 				"type mismatch: invalid operation '%s %s %s'",
 				leftType,
 				node.Operator,
 				rightType,
 			)
-			a.errors = append(a.errors, msg)
-			return "unknown"
+			return types.Unknown
 		}
 
-		if leftType == "str" && node.Operator != "+" {
-			msg := fmt.Sprintf("invalid operation: cannot use '%s' on strings", node.Operator)
-			a.errors = append(a.errors, msg)
-			return "unknown"
+		if leftType == types.Str && node.Operator != "+" {
+			a.reportError(diagnostic.TypeError, node, "invalid operation: cannot use '%s' on strings", node.Operator)
+			return types.Unknown
 		}
 
 		return leftType
 	}
 
-	return "unknown"
+	return types.Unknown
+}
+
+// checks a call of a builtin or a declared function and returns its result
+// type, which is nil for functions that return nothing
+func (a *Analyzer) analyzeCall(node *ast.CallExpression) types.Type {
+	argTypes := []types.Type{}
+	for _, arg := range node.Args {
+		argTypes = append(argTypes, a.analyzeExpression(arg))
+	}
+
+	switch node.Function {
+	case "format": return a.analyzeFormat(node)
+	case "args":
+		if len(node.Args) != 0 {
+			a.reportError(diagnostic.TypeError, node, "args expects no arguments, got %d", len(node.Args))
+		}
+		return types.AnyList
+	case "env":
+		if len(node.Args) != 1 {
+			a.reportError(diagnostic.TypeError, node, "env expects 1 argument, got %d", len(node.Args))
+		} else if !types.AssignableTo(argTypes[0], types.Str) {
+			a.reportError(diagnostic.TypeError, node.Args[0], "the name given to env must be a str, got '%s'", argTypes[0])
+		}
+		return types.Str
+	}
+
+	symbol, exists := a.env.Resolve(node.Function)
+	if !exists {
+		a.reportError(diagnostic.NameError, node, "undefined function '%s'", node.Function)
+		return types.Unknown
+	}
+
+	if symbol.Func == nil {
+		a.reportError(diagnostic.TypeError, node, "'%s' is a variable of type '%s', not a function", node.Function, symbol.Type)
+		return types.Unknown
+	}
+
+	fn := symbol.Type.(*types.Func)
+	node.Symbol = symbol
+
+	if len(node.Args) != len(fn.Params) {
+		a.reportError(diagnostic.TypeError, node, "function '%s' expects %d arguments, got %d", node.Function, len(fn.Params), len(node.Args))
+		return fn.Result
+	}
+
+	for i, arg := range node.Args {
+		if !types.AssignableTo(argTypes[i], fn.Params[i]) {
+			a.reportError(
+				diagnostic.TypeError,
+				arg,
+				"type mismatch: cannot use type '%s' as argument %d of '%s', which takes '%s'",
+				argTypes[i],
+				i+1,
+				node.Function,
+				fn.Params[i],
+			)
+		} else {
+			a.checkElements(arg, fn.Params[i])
+		}
+	}
+
+	return fn.Result
+}
+
+func (a *Analyzer) analyzeFormat(node *ast.CallExpression) types.Type {
+	if len(node.Args) == 0 {
+		a.reportError(diagnostic.TypeError, node, "format expects a template string as its first argument")
+		return types.Str
+	}
+
+	template, ok := node.Args[0].(*ast.StringLiteral)
+	if !ok {
+		a.reportError(diagnostic.TypeError, node.Args[0], "the template of format must be a string literal")
+		return types.Str
+	}
+
+	pieces, err := value.SplitFormat(template.Value)
+	if err != nil {
+		a.reportError(diagnostic.TypeError, template, "%v", err)
+		return types.Str
+	}
+
+	if placeholders := len(pieces) - 1; placeholders != len(node.Args)-1 {
+		a.reportError(
+			diagnostic.TypeError,
+			node,
+			"format string has %d placeholders but %d values were given",
+			placeholders,
+			len(node.Args)-1,
+		)
+	}
+
+	return types.Str
 }
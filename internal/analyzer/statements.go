@@ -1,65 +1,162 @@
 package analyzer
 
-import "simplescript/internal/ast"
+import (
+	"simplescript/internal/ast"
+	"simplescript/internal/diagnostic"
+	"simplescript/internal/types"
+)
 
 func (a *Analyzer) analyzeVarDecl(node *ast.VarDecl) {
 	if _, exists := a.env.store[node.Name]; exists {
 		a.reportError(
-			node.Token,
+			diagnostic.NameError,
+			node,
 			"variable '%s' is already defined in this scope",
 			node.Name,
 		)
 		return
 	}
 
-	if node.DataType == "" {
-		a.reportError(
-			node.Token,
-			"explicit type declaration is required for variable '%s' (e.g., '%s: int')",
-			node.Name,
+	if node.DataType == nil {
+		a.report(diagnostic.Errorf(
+			diagnostic.TypeError,
+			node.Span(),
+			"explicit type declaration is required for variable '%s'",
 			node.Name,
-		)
+		).WithHint("annotate the type, e.g. '%s: int'", node.Name))
+		return
+	}
+
+	dataType := a.resolveType(node.DataType)
+	if dataType == nil {
+		return
+	}
+
+	if !supported(dataType) {
+		a.reportError(diagnostic.TypeError, node.DataType, "variables of type '%s' are not supported yet", dataType)
 		return
 	}
 
 	valueType := a.analyzeExpression(node.Value)
 
-	if node.DataType != valueType && valueType != "unknown" {
+	if !types.AssignableTo(valueType, dataType) {
 		a.reportError(
-			node.Token,
+			diagnostic.TypeError,
+			node.Value,
 			"type mismatch: cannot assign type '%s' to variable of type '%s'",
 			valueType,
-			node.DataType,
+			dataType,
 		)
+	} else {
+		a.checkElements(node.Value, dataType)
 	}
 
-	a.env.Define(node.Name, node.DataType)
+	node.Symbol = a.env.Define(node.Name, dataType)
+	node.Symbol.Const = node.IsConst
 }
 
 func (a *Analyzer) analyzeAssignment(node *ast.Assignment) {
+	// what each target can hold, or nil when it could not be resolved
+	targetTypes := []types.Type{}
+
 	for _, target := range node.Targets {
 		var varName string
+		var varNode ast.Node = target
+		var targetType types.Type
 
 		switch t := target.(type) {
 		case *ast.Identifier: varName = t.Value
 		case *ast.IndexExpression:
 			if id, ok := t.Left.(*ast.Identifier); ok {
 				varName = id.Value
+				varNode = id
+			} else {
+				// an element of an element, like grid[1][0]
+				a.analyzeExpression(t.Left)
+				targetType = types.ElemOf(declaredType(t.Left))
 			}
 
 			a.analyzeExpression(t.Index)
+			t.SetType(types.Unknown)
 		}
 
 		if varName != "" {
-			_, exists := a.env.Resolve(varName)
+			symbol, exists := a.env.Resolve(varName)
 			if !exists {
-				a.reportError(node.Token, "undefined variable '%s'", varName)
+				a.reportError(diagnostic.NameError, varNode, "undefined variable '%s'", varName)
+			} else if id, ok := varNode.(*ast.Identifier); ok {
+				id.SetType(symbol.Type)
+				id.Symbol = symbol
+
+				// a list element holds what the list's element type allows
+				if symbol.Func != nil {
+					a.reportError(diagnostic.TypeError, target, "cannot assign to function '%s'", varName)
+				} else if target == varNode {
+					targetType = symbol.Type
+
+					if symbol.Const {
+						a.reportError(diagnostic.TypeError, target, "cannot assign to constant '%s'", varName)
+					}
+				} else {
+					targetType = types.ElemOf(symbol.Type)
+				}
 			}
 		}
+
+		targetTypes = append(targetTypes, targetType)
+	}
+
+	for i, val := range node.Values {
+		valueType := a.analyzeExpression(val)
+
+		if node.Operator != "=" || i >= len(targetTypes) || targetTypes[i] == nil {
+			continue
+		}
+
+		if !types.AssignableTo(valueType, targetTypes[i]) {
+			a.reportError(
+				diagnostic.TypeError,
+				val,
+				"type mismatch: cannot assign type '%s' to '%s'",
+				valueType,
+				targetTypes[i],
+			)
+		} else {
+			a.checkElements(val, targetTypes[i])
+		}
 	}
+}
 
-	for _, val := range node.Values {
-		a.analyzeExpression(val)
+// the type expr was declared with: an element of a typed list keeps the
+// element type, though reading it gives a dynamically typed value
+func declaredType(expr ast.Expression) types.Type {
+	if index, ok := expr.(*ast.IndexExpression); ok {
+		return types.ElemOf(declaredType(index.Left))
+	}
+
+	return expr.Type()
+}
+
+// a list literal stored in a typed list must only hold elements of its type
+func (a *Analyzer) checkElements(value ast.Expression, target types.Type) {
+	literal, ok := value.(*ast.ListLiteral)
+	elem := types.ElemOf(target)
+	if !ok || types.IsUnknown(elem) {
+		return
+	}
+
+	for _, el := range literal.Elements {
+		if !types.AssignableTo(el.Type(), elem) {
+			a.reportError(
+				diagnostic.TypeError,
+				el,
+				"type mismatch: cannot use type '%s' as an element of '%s'",
+				el.Type(),
+				target,
+			)
+		} else {
+			a.checkElements(el, elem)
+		}
 	}
 }
 
@@ -78,14 +175,26 @@ func (a *Analyzer) analyzeSayStmt(node *ast.SayStmt) {
 	for _, arg := range node.Args {
 		a.analyzeExpression(arg)
 	}
+
+	a.analyzeSayOption("sep", node.Sep)
+	a.analyzeSayOption("end", node.End)
+}
+
+func (a *Analyzer) analyzeSayOption(name string, option ast.Expression) {
+	if option == nil { return }
+
+	if optionType := a.analyzeExpression(option); !types.AssignableTo(optionType, types.Str) {
+		a.reportError(diagnostic.TypeError, option, "the '%s' option of say must be a str, got '%s'", name, optionType)
+	}
 }
 
 func (a *Analyzer) analyzeIfStmt(node *ast.IfStmt) {
 	conditionType := a.analyzeExpression(node.Condition)
 
-	if conditionType != "bool" && conditionType != "unknown" {
+	if !types.AssignableTo(conditionType, types.Bool) {
 		a.reportError(
-			node.Token,
+			diagnostic.TypeError,
+			node.Condition,
 			"condition in 'if' statement must evaluate to a boolean, got '%s'",
 			conditionType,
 		)
@@ -99,15 +208,185 @@ func (a *Analyzer) analyzeIfStmt(node *ast.IfStmt) {
 }
 
 func (a *Analyzer) analyzeForStmt(node *ast.ForStmt) {
+	// the start is evaluated once before the iterator exists; the end is
+	// re-evaluated on every pass, inside the loop scope
+	a.analyzeExpression(node.Start)
+
 	previousEnv := a.env
 	a.env = NewEnclosedEnvironment(previousEnv)
 
-	a.env.Define(node.Iterator, "int")
+	node.Symbol = a.env.Define(node.Iterator, types.Int)
 
-	a.analyzeExpression(node.Start)
 	a.analyzeExpression(node.End)
 
 	a.analyzeBlock(node.Body)
 
 	a.env = previousEnv
 }
+
+// names a function cannot take: the builtins, and for exported functions the
+// exports and hooks the wasm modules already define
+var (
+	builtinFuncs = map[string]bool{"say": true, "format": true, "args": true, "env": true}
+	reservedExports = map[string]bool{
+		"run": true, "memory": true, "alloc": true, "malloc": true, "calloc": true, "realloc": true,
+		"free": true, "resume": true, "_start": true, "_initialize": true,
+	}
+)
+
+// declares the program's top-level functions before anything else is
+// analyzed, so they can be called before their declaration and recursively
+func (a *Analyzer) declareFuncs(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		node, ok := stmt.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+
+		if builtinFuncs[node.Name] {
+			a.reportError(diagnostic.NameError, node, "cannot declare function '%s': the name belongs to a builtin", node.Name)
+			continue
+		}
+
+		if node.Exported && reservedExports[node.Name] {
+			a.reportError(diagnostic.NameError, node, "cannot export function '%s': the name is reserved by the wasm module", node.Name)
+			continue
+		}
+
+		if _, exists := a.env.store[node.Name]; exists {
+			a.reportError(diagnostic.NameError, node, "function '%s' is already defined in this scope", node.Name)
+			continue
+		}
+
+		// invalid annotations are reported here and then typed as unknown,
+		// so the body and the calls are still checked
+		fn := &types.Func{}
+		for _, param := range node.Params {
+			fn.Params = append(fn.Params, a.paramType(param.DataType, "parameters"))
+		}
+
+		if node.Result != nil {
+			fn.Result = a.paramType(node.Result, "results")
+		}
+
+		node.Symbol = a.env.Define(node.Name, fn)
+		node.Symbol.Const = true
+		node.Symbol.Func = node
+	}
+}
+
+func (a *Analyzer) paramType(expr ast.TypeExpr, what string) types.Type {
+	dataType := a.resolveType(expr)
+	if dataType == nil {
+		return types.Unknown
+	}
+
+	if !supported(dataType) {
+		a.reportError(diagnostic.TypeError, expr, "%s of type '%s' are not supported yet", what, dataType)
+		return types.Unknown
+	}
+
+	return dataType
+}
+
+func (a *Analyzer) analyzeFuncDecl(node *ast.FuncDecl) {
+	if a.env != a.top {
+		a.reportError(diagnostic.SyntaxError, node, "functions can only be declared at the top level")
+		return
+	}
+
+	// the declaration was rejected by declareFuncs
+	if node.Symbol == nil || node.Symbol.Func != node {
+		return
+	}
+
+	fn := node.Symbol.Type.(*types.Func)
+	previousEnv := a.env
+	a.env = NewFunctionEnvironment(previousEnv)
+	a.fn = node
+
+	for i, param := range node.Params {
+		if _, exists := a.env.store[param.Name]; exists {
+			a.reportError(diagnostic.NameError, param, "duplicate parameter '%s'", param.Name)
+		}
+
+		param.Symbol = a.env.Define(param.Name, fn.Params[i])
+	}
+
+	// the body shares the parameters' scope, so it cannot redeclare them
+	for _, stmt := range node.Body.Statements {
+		a.analyzeStatement(stmt)
+	}
+
+	if fn.Result != nil && !terminates(node.Body.Statements) {
+		a.reportError(diagnostic.TypeError, node.Body, "missing return at the end of function '%s'", node.Name)
+	}
+
+	a.env = previousEnv
+	a.fn = nil
+}
+
+func (a *Analyzer) analyzeReturn(node *ast.ReturnStmt) {
+	// a return outside functions ends the program, and its value is ignored
+	if a.fn == nil {
+		if node.ReturnValue != nil {
+			a.analyzeExpression(node.ReturnValue)
+		}
+		return
+	}
+
+	result := a.fn.Symbol.Type.(*types.Func).Result
+
+	switch {
+	case node.ReturnValue == nil:
+		if result != nil {
+			a.reportError(diagnostic.TypeError, node, "missing return value: function '%s' returns '%s'", a.fn.Name, result)
+		}
+	case result == nil:
+		a.analyzeExpression(node.ReturnValue)
+		a.reportError(diagnostic.TypeError, node.ReturnValue, "function '%s' does not return a value", a.fn.Name)
+	default:
+		valueType := a.analyzeExpression(node.ReturnValue)
+
+		if !types.AssignableTo(valueType, result) {
+			a.reportError(
+				diagnostic.TypeError,
+				node.ReturnValue,
+				"type mismatch: cannot return type '%s' from function '%s' returning '%s'",
+				valueType,
+				a.fn.Name,
+				result,
+			)
+		} else {
+			a.checkElements(node.ReturnValue, result)
+		}
+	}
+}
+
+func (a *Analyzer) analyzeCallStmt(node *ast.CallStmt) {
+	if builtinFuncs[node.Call.Function] {
+		a.analyzeExpression(node.Call)
+		a.reportError(diagnostic.TypeError, node, "the result of %s is not used", node.Call.Function)
+		return
+	}
+
+	// a function without a result leaves the call untyped
+	node.Call.SetType(a.analyzeCall(node.Call))
+}
+
+// reports whether the statements always end in a return, so control never
+// reaches the end of a function body
+func terminates(stmts []ast.Statement) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+
+	switch s := stmts[len(stmts)-1].(type) {
+	case *ast.ReturnStmt: return true
+	case *ast.Block: return terminates(s.Statements)
+	case *ast.IfStmt:
+		return s.Alternative != nil && terminates(s.Consequence.Statements) && terminates([]ast.Statement{s.Alternative})
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"simplescript/internal/ast"
+	"simplescript/internal/diagnostic"
+	"simplescript/internal/types"
+)
+
+// resolves a type annotation, reporting names that are not types; the
+// result is nil when the annotation is invalid
+func (a *Analyzer) resolveType(expr ast.TypeExpr) types.Type {
+	switch e := expr.(type) {
+	case *ast.NamedType:
+		if e.Name == "list" {
+			return types.AnyList
+		}
+
+		if t, ok := types.Universe[e.Name]; ok {
+			return t
+		}
+
+		a.reportError(diagnostic.TypeError, e, "unknown type '%s'", e.Name)
+		return nil
+	case *ast.ListType:
+		if elem := a.resolveType(e.Elem); elem != nil {
+			return &types.List{Elem: elem}
+		}
+	case *ast.MapType:
+		key, value := a.resolveType(e.Key), a.resolveType(e.Value)
+		if key != nil && value != nil {
+			return &types.Map{Key: key, Value: value}
+		}
+	case *ast.FuncType:
+		fn := &types.Func{}
+		valid := true
+
+		for _, param := range e.Params {
+			t := a.resolveType(param)
+			valid = valid && t != nil
+			fn.Params = append(fn.Params, t)
+		}
+
+		if e.Result != nil {
+			fn.Result = a.resolveType(e.Result)
+			valid = valid && fn.Result != nil
+		}
+
+		if valid {
+			return fn
+		}
+	case *ast.StructType:
+		st := &types.Struct{}
+		seen := map[string]bool{}
+		valid := true
+
+		for _, field := range e.Fields {
+			if seen[field.Name] {
+				a.reportError(diagnostic.TypeError, field, "duplicate field '%s'", field.Name)
+				valid = false
+			}
+			seen[field.Name] = true
+
+			t := a.resolveType(field.Type)
+			valid = valid && t != nil
+			st.Fields = append(st.Fields, types.Field{Name: field.Name, Type: t})
+		}
+
+		if valid {
+			return st
+		}
+	case *ast.OptionalType:
+		if elem := a.resolveType(e.Elem); elem != nil {
+			return &types.Optional{Elem: elem}
+		}
+	}
+
+	return nil
+}
+
+// no backend can represent these values yet
+func supported(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.Basic: return true
+	case *types.List: return supported(t.Elem)
+	}
+
+	return false
+}
@@ -1,21 +1,80 @@
 package ast
 
+import "simplescript/internal/types"
+
+// a location in the source file; Line and Col are 1-based, Offset is a byte index
+type Position struct {
+	Offset int
+	Line int
+	Col int
+}
+
+// the source range covered by a token or node, End is exclusive
+type Span struct {
+	File string
+	Start Position
+	End Position
+}
+
+// returns the smallest span containing both spans
+func (s Span) Cover(other Span) Span {
+	if other.Start.Offset < s.Start.Offset {
+		s.Start = other.Start
+	}
+
+	if other.End.Offset > s.End.Offset {
+		s.End = other.End
+	}
+
+	return s
+}
+
+type Node interface {
+	Span() Span
+	SetSpan(span Span)
+}
+
 type Expression interface {
+	Node
 	expressionNode()
 	String() string
+	// the type inferred by the analyzer, nil before analysis
+	Type() types.Type
+	SetType(dataType types.Type)
 }
 
 type Statement interface {
+	Node
 	statementNode()
 }
 
-type baseExpr struct{}
+type baseNode struct {
+	Loc Span
+}
+func (b *baseNode) Span() Span { return b.Loc }
+func (b *baseNode) SetSpan(span Span) { b.Loc = span }
+
+type baseExpr struct{
+	baseNode
+	dataType types.Type
+}
 func (b *baseExpr) expressionNode() {}
 func (b *baseExpr) String() string { return "" }
+func (b *baseExpr) Type() types.Type { return b.dataType }
+func (b *baseExpr) SetType(dataType types.Type) { b.dataType = dataType }
 
-type baseStmt struct{}
+type baseStmt struct{ baseNode }
 func (b *baseStmt) statementNode() {}
 
+// a declared variable, created by the analyzer for each declaration so that
+// shadowed variables sharing a name stay distinct
+type Symbol struct {
+	Name string
+	Type types.Type
+	Const bool
+	Func *FuncDecl // the declaration when the symbol names a function
+}
+
 type Program struct {
 	baseStmt
 	Statements []Statement
@@ -0,0 +1,190 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// an ordered, printable view of a node used by the text and JSON dumps
+type dumpNode struct {
+	Kind string
+	Span Span
+	Type string
+	Fields []dumpField
+}
+
+type dumpField struct {
+	Name string
+	Value any
+}
+
+// writes an indented tree of the node; typed adds the analyzer's types
+func Fprint(w io.Writer, node Node, typed bool) error {
+	var buf bytes.Buffer
+	printValue(&buf, dump(reflect.ValueOf(node), typed), 0)
+	_, err := w.Write(bytes.TrimPrefix(buf.Bytes(), []byte(" ")))
+	return err
+}
+
+// writes the node as indented JSON; typed adds the analyzer's types
+func FprintJSON(w io.Writer, node Node, typed bool) error {
+	data, err := json.MarshalIndent(dump(reflect.ValueOf(node), typed), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+var (
+	nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+	exprType = reflect.TypeOf((*Expression)(nil)).Elem()
+	tokenType = reflect.TypeOf(Token{})
+	symbolType = reflect.TypeOf(&Symbol{})
+)
+
+func dump(v reflect.Value, typed bool) any {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+
+		if v.Kind() == reflect.Pointer && v.Type().Implements(nodeType) {
+			return dumpStruct(v, typed)
+		}
+
+		return dump(v.Elem(), typed)
+	case reflect.Slice:
+		items := []any{}
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, dump(v.Index(i), typed))
+		}
+		return items
+	default:
+		return v.Interface()
+	}
+}
+
+func dumpStruct(v reflect.Value, typed bool) *dumpNode {
+	node := v.Interface().(Node)
+	out := &dumpNode{Kind: v.Elem().Type().Name(), Span: node.Span()}
+
+	if expr, ok := node.(Expression); ok && typed && expr.Type() != nil {
+		out.Type = expr.Type().String()
+	}
+
+	elem := v.Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		field := elem.Type().Field(i)
+
+		// positions are already covered by the span, and the types of
+		// resolved symbols by the typed dump
+		if field.Anonymous || !field.IsExported() || field.Type == tokenType || field.Type == symbolType {
+			continue
+		}
+
+		out.Fields = append(out.Fields, dumpField{Name: field.Name, Value: dump(elem.Field(i), typed)})
+	}
+
+	return out
+}
+
+type jsonPosition struct {
+	Offset int `json:"offset"`
+	Line int `json:"line"`
+	Col int `json:"col"`
+}
+
+type jsonSpan struct {
+	Start jsonPosition `json:"start"`
+	End jsonPosition `json:"end"`
+}
+
+func (n *dumpNode) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	writeKey := func(key string, value any) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(&buf, ",%q:%s", key, data)
+		return nil
+	}
+
+	fmt.Fprintf(&buf, "{%q:%q", "kind", n.Kind)
+
+	span := jsonSpan{Start: jsonPosition(n.Span.Start), End: jsonPosition(n.Span.End)}
+	if err := writeKey("span", span); err != nil {
+		return nil, err
+	}
+
+	if n.Type != "" {
+		if err := writeKey("type", n.Type); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range n.Fields {
+		if err := writeKey(lowerFirst(f.Name), f.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func printValue(buf *bytes.Buffer, value any, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	switch v := value.(type) {
+	case *dumpNode:
+		fmt.Fprintf(
+			buf,
+			" %s @%d:%d-%d:%d",
+			v.Kind,
+			v.Span.Start.Line,
+			v.Span.Start.Col,
+			v.Span.End.Line,
+			v.Span.End.Col,
+		)
+		if v.Type != "" {
+			fmt.Fprintf(buf, " : %s", v.Type)
+		}
+		buf.WriteByte('\n')
+
+		for _, f := range v.Fields {
+			fmt.Fprintf(buf, "%s  %s:", indent, f.Name)
+			printValue(buf, f.Value, depth+1)
+		}
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+
+		buf.WriteByte('\n')
+		for i, item := range v {
+			fmt.Fprintf(buf, "%s  %d:", indent, i)
+			printValue(buf, item, depth+1)
+		}
+	case string:
+		fmt.Fprintf(buf, " %q\n", v)
+	case nil:
+		buf.WriteString(" nil\n")
+	default:
+		fmt.Fprintf(buf, " %v\n", v)
+	}
+}
+
+func lowerFirst(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
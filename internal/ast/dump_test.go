@@ -0,0 +1,68 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"simplescript/internal/types"
+)
+
+func sampleProgram() *Program {
+	value := &IntegerLiteral{Value: 5}
+	value.SetType(types.Int)
+	value.SetSpan(Span{Start: Position{Line: 1, Col: 14}, End: Position{Line: 1, Col: 15}})
+
+	dataType := &NamedType{Name: "int"}
+	dataType.SetSpan(Span{Start: Position{Line: 1, Col: 8}, End: Position{Line: 1, Col: 11}})
+
+	decl := &VarDecl{Name: "x", DataType: dataType, Value: value}
+	decl.SetSpan(Span{Start: Position{Line: 1, Col: 1}, End: Position{Line: 1, Col: 15}})
+
+	return &Program{Statements: []Statement{decl}}
+}
+
+func TestFprintTyped(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Fprint(&buf, sampleProgram(), true); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Program @0:0-0:0
+  Statements:
+    0: VarDecl @1:1-1:15
+      IsConst: false
+      Name: "x"
+      DataType: NamedType @1:8-1:11
+        Name: "int"
+      Value: IntegerLiteral @1:14-1:15 : int
+        Value: 5
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected dump.\nExpected:\n%s\nGot:\n%s", expected, buf.String())
+	}
+}
+
+func TestFprintJSONUntyped(t *testing.T) {
+	var buf bytes.Buffer
+	if err := FprintJSON(&buf, sampleProgram(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Kind string `json:"kind"`
+		Statements []map[string]any `json:"statements"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Kind != "Program" || len(got.Statements) != 1 {
+		t.Fatalf("unexpected program dump: %s", buf.String())
+	}
+
+	value := got.Statements[0]["value"].(map[string]any)
+	if _, hasType := value["type"]; hasType || value["kind"] != "IntegerLiteral" {
+		t.Errorf("unexpected value dump: %v", value)
+	}
+}
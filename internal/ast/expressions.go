@@ -34,10 +34,12 @@ type Identifier struct{
 	baseExpr
 	Token Token
 	Value string
+	Symbol *Symbol // the declaration the name resolves to, set by the analyzer
 }
 
 type InfixExpression struct {
 	baseExpr
+	Token Token
 	Left Expression
 	Operator string
 	Right Expression
@@ -45,6 +47,7 @@ type InfixExpression struct {
 
 type PrefixExpression struct {
 	baseExpr
+	Token Token
 	Operator string
 	Right Expression
 }
@@ -55,3 +58,12 @@ type IndexExpression struct {
 	Left Expression
 	Index Expression
 }
+
+// a call of a builtin or declared function, like format("{} items", n)
+type CallExpression struct {
+	baseExpr
+	Token Token
+	Function string
+	Args []Expression
+	Symbol *Symbol // the declared function, set by the analyzer; nil for builtins
+}
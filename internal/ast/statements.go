@@ -5,8 +5,9 @@ type VarDecl struct {
 	Token Token
 	IsConst bool
 	Name string
-	DataType string
+	DataType TypeExpr // nil when the declaration has no annotation
 	Value Expression
+	Symbol *Symbol // set by the analyzer
 }
 
 type Assignment struct {
@@ -17,10 +18,13 @@ type Assignment struct {
 	Values []Expression
 }
 
+// say(args..., sep=" ", end="\n"), where Sep and End are nil when omitted
 type SayStmt struct {
 	baseStmt
 	Token Token
 	Args []Expression
+	Sep Expression
+	End Expression
 }
 
 type IfStmt struct {
@@ -35,17 +39,45 @@ type ForStmt struct {
 	baseStmt
 	Token Token
 	Iterator string
+	Symbol *Symbol // the iterator, set by the analyzer
 	Start Expression
 	End Expression
 	Body *Block
 }
 
+// return [value], where ReturnValue is nil for a bare return
 type ReturnStmt struct {
 	baseStmt
 	Token Token
 	ReturnValue Expression
 }
 
+// [export] func Name(Params...) [Result] { Body }
+type FuncDecl struct {
+	baseStmt
+	Token Token
+	Exported bool
+	Name string
+	Params []*Param
+	Result TypeExpr // nil when the function returns nothing
+	Body *Block
+	Symbol *Symbol // set by the analyzer
+}
+
+type Param struct {
+	baseNode
+	Token Token
+	Name string
+	DataType TypeExpr
+	Symbol *Symbol // set by the analyzer
+}
+
+// a call whose result, if any, is discarded
+type CallStmt struct {
+	baseStmt
+	Call *CallExpression
+}
+
 type BreakStmt struct {
 	baseStmt
 	Token Token
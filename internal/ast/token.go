@@ -5,8 +5,20 @@ type TokenType int
 type Token struct {
   Tag TokenType
   Slice string
+  File string
   Line int
   Col int
+  Offset int
+  End Position
+}
+
+// the source range of the token, including quotes around strings
+func (t Token) Span() Span {
+	return Span{
+		File: t.File,
+		Start: Position{Offset: t.Offset, Line: t.Line, Col: t.Col},
+		End: t.End,
+	}
 }
 
 const (
@@ -27,6 +39,7 @@ const (
 	TOKEN_LBRACKET
 	TOKEN_RBRACKET
 	TOKEN_RANGE
+	TOKEN_QUESTION
 
 	// Keywords
 	TOKEN_KW_VAR
@@ -68,6 +81,65 @@ const (
 	TOKEN_INVALID
 )
 
+var tokenNames = map[TokenType]string{
+	TOKEN_PLUS: "PLUS",
+	TOKEN_MINUS: "MINUS",
+	TOKEN_ASTERISK: "ASTERISK",
+	TOKEN_SLASH: "SLASH",
+	TOKEN_EQUALS: "EQUALS",
+	TOKEN_COMMA: "COMMA",
+	TOKEN_DOT: "DOT",
+	TOKEN_LPAREN: "LPAREN",
+	TOKEN_RPAREN: "RPAREN",
+	TOKEN_LBRACE: "LBRACE",
+	TOKEN_RBRACE: "RBRACE",
+	TOKEN_LBRACKET: "LBRACKET",
+	TOKEN_RBRACKET: "RBRACKET",
+	TOKEN_RANGE: "RANGE",
+	TOKEN_QUESTION: "QUESTION",
+	TOKEN_KW_VAR: "KW_VAR",
+	TOKEN_KW_CONST: "KW_CONST",
+	TOKEN_KW_FOR: "KW_FOR",
+	TOKEN_KW_IN: "KW_IN",
+	TOKEN_KW_IF: "KW_IF",
+	TOKEN_KW_ELSE: "KW_ELSE",
+	TOKEN_KW_TRUE: "KW_TRUE",
+	TOKEN_KW_FALSE: "KW_FALSE",
+	TOKEN_KW_CONTINUE: "KW_CONTINUE",
+	TOKEN_KW_FUNC: "KW_FUNC",
+	TOKEN_KW_RETURN: "KW_RETURN",
+	TOKEN_KW_BREAK: "KW_BREAK",
+	TOKEN_JSON: "JSON",
+	TOKEN_STR: "STR",
+	TOKEN_INT: "INT",
+	TOKEN_FLOAT: "FLOAT",
+	TOKEN_BOOL: "BOOL",
+	TOKEN_LIST: "LIST",
+	TOKEN_MAP: "MAP",
+	TOKEN_IDENTIFIER: "IDENTIFIER",
+	TOKEN_PLUS_EQUAL: "PLUS_EQUAL",
+	TOKEN_MINUS_EQUAL: "MINUS_EQUAL",
+	TOKEN_SLASH_EQUAL: "SLASH_EQUAL",
+	TOKEN_ASTERISK_EQUAL: "ASTERISK_EQUAL",
+	TOKEN_EQUAL_EQUAL: "EQUAL_EQUAL",
+	TOKEN_BANG_EQUAL: "BANG_EQUAL",
+	TOKEN_GREATER_EQUAL: "GREATER_EQUAL",
+	TOKEN_GREATER: "GREATER",
+	TOKEN_LESS_EQUAL: "LESS_EQUAL",
+	TOKEN_LESS: "LESS",
+	TOKEN_COLON: "COLON",
+	TOKEN_EOF: "EOF",
+	TOKEN_INVALID: "INVALID",
+}
+
+func (t TokenType) String() string {
+	if name, ok := tokenNames[t]; ok {
+		return name
+	}
+
+	return "UNKNOWN"
+}
+
 // Keywords mapping for the Lexer
 var keywords = map[string]TokenType{
 	// Basic Types
@@ -0,0 +1,61 @@
+package ast
+
+// a type annotation as written in source, resolved to a types.Type by the analyzer
+type TypeExpr interface {
+	Node
+	typeNode()
+}
+
+type baseType struct{ baseNode }
+func (b *baseType) typeNode() {}
+
+// int, float, str, bool, an untyped list or a name the analyzer rejects
+type NamedType struct {
+	baseType
+	Token Token
+	Name string
+}
+
+// list[Elem]
+type ListType struct {
+	baseType
+	Token Token
+	Elem TypeExpr
+}
+
+// map[Key]Value
+type MapType struct {
+	baseType
+	Token Token
+	Key TypeExpr
+	Value TypeExpr
+}
+
+// func(Params...) Result, where Result may be nil
+type FuncType struct {
+	baseType
+	Token Token
+	Params []TypeExpr
+	Result TypeExpr
+}
+
+// {name: Type, ...}
+type StructType struct {
+	baseType
+	Token Token
+	Fields []*FieldType
+}
+
+type FieldType struct {
+	baseType
+	Token Token
+	Name string
+	Type TypeExpr
+}
+
+// Elem?
+type OptionalType struct {
+	baseType
+	Token Token
+	Elem TypeExpr
+}
@@ -0,0 +1,400 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"simplescript/internal/ir"
+	"simplescript/internal/types"
+)
+
+// Writes the files serving <stem>.wasm in a browser next to it in dir: an
+// index.html, the ES module <stem>.js loading the program and its
+// TypeScript declarations <stem>.d.ts. TinyGo
+// modules also need the wasm_exec.js bridge of the TinyGo that built them,
+// passed as wasmExec; modules from the native backend pass "". The
+// exported functions of prog become typed methods of the loaded program.
+func WriteBundle(dir, stem, wasmExec string, prog *ir.Program) error {
+	exports := []*ir.Func{}
+	for _, fn := range prog.Funcs {
+		if fn.Exported {
+			exports = append(exports, fn)
+		}
+	}
+
+	functions := ""
+	if len(exports) > 0 {
+		functions = "\n    ...functions(instance.exports, signatures),"
+		if wasmExec != "" {
+			functions = "\n    ...functions(instance.exports, signatures, () => started),"
+		}
+	}
+
+	// the stem is quoted for each place it lands in: relative URLs in
+	// JavaScript strings, which comments can hold too, and the page's title
+	replacer := strings.NewReplacer(
+		"$WASM", jsString(url.PathEscape(stem)+".wasm"),
+		"$LOADER", jsString("./"+url.PathEscape(stem)+".js"),
+		"$TITLE", html.EscapeString(stem),
+		"$FUNCTIONS", functions,
+	)
+
+	loader := bundleNativeLoader
+	files := map[string]string{
+		"index.html": replacer.Replace(bundleIndex),
+		stem + ".d.ts": replacer.Replace(bundleDeclarations(exports, wasmExec != "")),
+	}
+
+	if wasmExec != "" {
+		loader = bundleTinyGoLoader
+		files["wasm_exec.js"] = wasmExec
+	}
+
+	header := bundleLoaderHeader
+	if len(exports) > 0 {
+		header += bundleSignatures(exports) + bundleMarshalling
+	}
+	files[stem+".js"] = replacer.Replace(header + loader)
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// How the loader lowers and lifts a value of a type: "int", "float",
+// "bool", "str", or ["list", element] where an untyped list has a null
+// element.
+func bundleType(dataType types.Type) any {
+	if types.IsList(dataType) {
+		if elem := types.ElemOf(dataType); !types.IsUnknown(elem) {
+			return []any{"list", bundleType(elem)}
+		}
+
+		return []any{"list", nil}
+	}
+
+	return dataType.String()
+}
+
+// the name, parameter types and result type of each exported function
+func bundleSignatures(exports []*ir.Func) string {
+	signatures := []any{}
+	for _, fn := range exports {
+		params := []any{}
+		for _, param := range fn.Params {
+			params = append(params, bundleType(param.Type))
+		}
+
+		var result any
+		if fn.Result != nil {
+			result = bundleType(fn.Result)
+		}
+
+		signatures = append(signatures, []any{fn.Name, params, result})
+	}
+
+	code, _ := json.Marshal(signatures)
+	return "\nconst signatures = " + string(code) + ";\n"
+}
+
+// the TypeScript type of a value of a type, as a parameter or as a result
+func tsType(dataType types.Type, param bool) string {
+	switch {
+	case types.IsList(dataType) && types.IsUnknown(types.ElemOf(dataType)): return "Value[]"
+	case types.IsList(dataType):
+		elem := tsType(types.ElemOf(dataType), param)
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[]"
+	case dataType == types.Int && param: return "bigint | number"
+	case dataType == types.Int: return "bigint"
+	case dataType == types.Float: return "number"
+	case dataType == types.Bool: return "boolean"
+	}
+
+	return "string"
+}
+
+// whether the TypeScript type of a value of a type refers to Value
+func usesValue(dataType types.Type) bool {
+	return types.IsList(dataType) && (types.IsUnknown(types.ElemOf(dataType)) || usesValue(types.ElemOf(dataType)))
+}
+
+// the declarations of the loader, with a method for each exported function
+func bundleDeclarations(exports []*ir.Func, tinygo bool) string {
+	var methods strings.Builder
+	value := false
+	for _, fn := range exports {
+		params := []string{}
+		for _, param := range fn.Params {
+			params = append(params, jsName(param.Name)+": "+tsType(param.Type, true))
+			value = value || usesValue(param.Type)
+		}
+
+		result := "void"
+		if fn.Result != nil {
+			result = tsType(fn.Result, false)
+			value = value || usesValue(fn.Result)
+		}
+
+		doc := "Calls the exported function " + fn.Name + "."
+		if tinygo {
+			doc += " Throws until run() has been called."
+		}
+
+		fmt.Fprintf(&methods, "  /** %s */\n  %s(%s): %s;\n", doc, fn.Name, strings.Join(params, ", "), result)
+	}
+
+	declarations := bundleDeclarationsHeader
+	if value {
+		declarations += bundleValue
+	}
+
+	return declarations + bundleProgramInterface + methods.String() + bundleLoad
+}
+
+// the part of the loader shared by both kinds of module; $WASM is the
+// module's URL relative to the loader
+const bundleLoaderHeader = `// Generated by SimpleScript. DO NOT EDIT.
+//
+// load() instantiates the module at $WASM and returns the program; run()
+// executes it, and the exported functions are its other methods. Each
+// line say prints is passed to options.print, console.log by default, and
+// options.module replaces fetching the module with the given bytes.
+
+const decoder = new TextDecoder();
+
+async function moduleBytes(options) {
+  if (options.module) return options.module;
+
+  const url = new URL($WASM, import.meta.url);
+  const response = await fetch(url);
+  if (!response.ok) throw new Error("cannot load " + url + ": " + response.status);
+  return response.arrayBuffer();
+}
+
+// the print function of simplescript.wit, reading a line from memory
+function printer(options, memory) {
+  const print = options.print ?? ((line) => console.log(line));
+  return (ptr, len) => print(decoder.decode(new Uint8Array(memory().buffer, ptr, len)));
+}
+`
+
+// Exported functions take and return their values in wasm memory, laid
+// out like the lists of the native backend: a string is UTF-8 packed as
+// address << 32 | length, and a list is a length followed by 16 byte cells
+// holding a tag at offset 0 and the value at offset 8.
+const bundleMarshalling = `
+const encoder = new TextEncoder();
+const tags = { int: 0, float: 1, bool: 2, str: 3, list: 4 };
+
+function kind(type) {
+  return Array.isArray(type) ? "list" : type;
+}
+
+// the type of an element of an untyped list; ints must be bigints there
+function typeOf(value) {
+  switch (typeof value) {
+    case "bigint": return "int";
+    case "number": return "float";
+    case "boolean": return "bool";
+    case "string": return "str";
+  }
+  if (Array.isArray(value)) return ["list", null];
+  throw new TypeError("cannot pass " + value + " to SimpleScript");
+}
+
+function marshaller(exports) {
+  const memory = () => exports.memory ?? exports.mem;
+  const view = () => new DataView(memory().buffer);
+  const alloc = (size) => exports.alloc(size) >>> 0;
+
+  function expect(ok, type, value) {
+    if (!ok) throw new TypeError("expected " + kind(type) + ", got " + value);
+  }
+
+  function lower(type, value) {
+    switch (kind(type)) {
+      case "int":
+        if (typeof value === "number" && Number.isInteger(value)) value = BigInt(value);
+        expect(typeof value === "bigint" && BigInt.asIntN(64, value) === value, type, value);
+        return value;
+      case "float":
+        expect(typeof value === "number", type, value);
+        return value;
+      case "bool":
+        expect(typeof value === "boolean", type, value);
+        return value ? 1 : 0;
+      case "str": {
+        expect(typeof value === "string", type, value);
+        const bytes = encoder.encode(value);
+        const ptr = alloc(bytes.length);
+        new Uint8Array(memory().buffer, ptr, bytes.length).set(bytes);
+        return BigInt.asIntN(64, BigInt(ptr) << 32n | BigInt(bytes.length));
+      }
+    }
+
+    expect(Array.isArray(value), type, value);
+    const ptr = alloc(8 + 16 * value.length);
+    view().setUint32(ptr, value.length, true);
+
+    value.forEach((element, i) => {
+      const elementType = type[1] ?? typeOf(element);
+      const lowered = lower(elementType, element);
+      // lowering may have grown the memory, detaching earlier views
+      const cell = view(), at = ptr + 8 + 16 * i;
+
+      cell.setUint32(at, tags[kind(elementType)], true);
+      switch (kind(elementType)) {
+        case "int": case "str": cell.setBigInt64(at + 8, lowered, true); break;
+        case "float": cell.setFloat64(at + 8, lowered, true); break;
+        default: cell.setUint32(at + 8, lowered, true);
+      }
+    });
+
+    return ptr;
+  }
+
+  function lift(type, value) {
+    switch (kind(type)) {
+      case "int": case "float": return value;
+      case "bool": return value !== 0;
+      case "str": {
+        const packed = BigInt.asUintN(64, value);
+        const ptr = Number(packed >> 32n), len = Number(packed & 0xffffffffn);
+        return decoder.decode(new Uint8Array(memory().buffer, ptr, len));
+      }
+    }
+
+    const ptr = value >>> 0, cell = view();
+    const list = [];
+    for (let i = 0, at = ptr + 8; i < cell.getUint32(ptr, true); i++, at += 16) {
+      switch (cell.getUint32(at, true)) {
+        case tags.int: list.push(cell.getBigInt64(at + 8, true)); break;
+        case tags.float: list.push(cell.getFloat64(at + 8, true)); break;
+        case tags.bool: list.push(cell.getUint32(at + 8, true) !== 0); break;
+        case tags.str: list.push(lift("str", cell.getBigInt64(at + 8, true))); break;
+        default: list.push(lift(["list", null], cell.getUint32(at + 8, true)));
+      }
+    }
+
+    return list;
+  }
+
+  return { lower, lift };
+}
+
+// a method for each exported function, checking and converting its
+// arguments and result; started tells whether the module can be called yet
+function functions(exports, signatures, started = () => true) {
+  const { lower, lift } = marshaller(exports);
+  const methods = {};
+
+  for (const [name, params, result] of signatures) {
+    methods[name] = (...args) => {
+      if (!started()) throw new Error("call run() before " + name + "()");
+      if (args.length !== params.length) {
+        throw new TypeError(name + "() expects " + params.length + " arguments, got " + args.length);
+      }
+
+      const value = exports[name](...params.map((type, i) => lower(type, args[i])));
+      return result === null ? undefined : lift(result, value);
+    };
+  }
+
+  return methods;
+}
+`
+
+const bundleNativeLoader = `
+export async function load(options = {}) {
+  let instance;
+  const imports = { $root: { print: printer(options, () => instance.exports.memory) } };
+
+  ({ instance } = await WebAssembly.instantiate(await moduleBytes(options), imports));
+  return {$FUNCTIONS
+    run: async () => instance.exports.run(),
+  };
+}
+`
+
+const bundleTinyGoLoader = `
+import "./wasm_exec.js";
+
+export async function load(options = {}) {
+  let instance, started = false;
+  const go = new Go();
+  // TinyGo exports the memory as "memory", Go as "mem"
+  go.importObject.$root = { print: printer(options, () => instance.exports.memory ?? instance.exports.mem) };
+
+  ({ instance } = await WebAssembly.instantiate(await moduleBytes(options), go.importObject));
+  return {$FUNCTIONS
+    run: () => {
+      started = true;
+      return go.run(instance);
+    },
+  };
+}
+`
+
+// the declarations shared by both loaders
+const bundleDeclarationsHeader = `// Generated by SimpleScript. DO NOT EDIT.
+
+export interface LoadOptions {
+  /** Receives each line say prints; console.log by default. */
+  print?: (line: string) => void;
+  /** The module's bytes, loaded instead of fetching $WASM. */
+  module?: ArrayBuffer | ArrayBufferView;
+}
+`
+
+// the elements of an untyped list; ints are always bigints there
+const bundleValue = `
+export type Value = bigint | number | boolean | string | Value[];
+`
+
+const bundleProgramInterface = `
+export interface Program {
+  /** Executes the program, settling once it finishes. */
+  run(): Promise<void>;
+`
+
+const bundleLoad = `}
+
+/** Instantiates the module at $WASM. */
+export function load(options?: LoadOptions): Promise<Program>;
+`
+
+const bundleIndex = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>$TITLE</title>
+</head>
+<body>
+  <pre id="output"></pre>
+  <script type="module">
+    import { load } from $LOADER;
+
+    const output = document.getElementById("output");
+    const program = await load({ print: (line) => { output.textContent += line + "\n"; } });
+
+    try {
+      await program.run();
+    } catch (err) {
+      console.error(err);
+    }
+  </script>
+</body>
+</html>
+`
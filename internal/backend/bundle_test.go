@@ -0,0 +1,220 @@
+package backend
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"simplescript/internal/backend/wasm"
+	"simplescript/internal/testutil"
+)
+
+const bundleProgram = "say('hello', 2.5)\nsay([1, 'a'], sep='')"
+
+// loads the bundled module in node the way index.html does, collecting the
+// printed lines
+const bundleRunner = `import { readFileSync } from "node:fs";
+import { load } from "./app.js";
+
+const lines = [];
+const program = await load({
+  module: readFileSync(new URL("app.wasm", import.meta.url)),
+  print: (line) => lines.push(line),
+});
+await program.run();
+console.log(JSON.stringify(lines));
+`
+
+func runBundle(t *testing.T, dir string) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node is not installed")
+	}
+
+	return runTestCommand(t, "node", writeTestFile(t, dir, "runner.mjs", bundleRunner))
+}
+
+func TestNativeBundle(t *testing.T) {
+	prog := testutil.Lower(t, bundleProgram)
+	module, err := wasm.Generate(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeTestFile(t, dir, "app.wasm", string(module))
+	if err := WriteBundle(dir, "app", "", prog); err != nil {
+		t.Fatal(err)
+	}
+
+	index, _ := os.ReadFile(filepath.Join(dir, "index.html"))
+	if !strings.Contains(string(index), `import { load } from "./app.js";`) {
+		t.Errorf("expected index.html to import the loader, got:\n%s", index)
+	}
+
+	declarations, _ := os.ReadFile(filepath.Join(dir, "app.d.ts"))
+	if !strings.Contains(string(declarations), "export function load(options?: LoadOptions): Promise<Program>;") {
+		t.Errorf("expected app.d.ts to declare load, got:\n%s", declarations)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "wasm_exec.js")); !os.IsNotExist(err) {
+		t.Errorf("expected no wasm_exec.js for a native module")
+	}
+
+	if out, expected := runBundle(t, dir), "[\"hello 2.5\",\"[1, \\\"a\\\"]\"]\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+// a script's name is quoted in the page and escaped in the loader's URLs
+func TestBundleQuotesTheStem(t *testing.T) {
+	prog := testutil.Lower(t, bundleProgram)
+	module, err := wasm.Generate(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, stem := t.TempDir(), `my "app" <1>`
+	writeTestFile(t, dir, stem+".wasm", string(module))
+	if err := WriteBundle(dir, stem, "", prog); err != nil {
+		t.Fatal(err)
+	}
+
+	index, _ := os.ReadFile(filepath.Join(dir, "index.html"))
+	for _, expected := range []string{`<title>my &#34;app&#34; &lt;1&gt;</title>`, `import { load } from "./my%20%22app%22%20%3C1%3E.js";`} {
+		if !strings.Contains(string(index), expected) {
+			t.Errorf("expected index.html to contain %q, got:\n%s", expected, index)
+		}
+	}
+
+	loader, _ := os.ReadFile(filepath.Join(dir, stem+".js"))
+	if !strings.Contains(string(loader), `new URL("my%20%22app%22%20%3C1%3E.wasm", import.meta.url)`) {
+		t.Errorf("expected the loader to fetch the escaped module URL, got:\n%s", loader)
+	}
+
+	// the runner imports the loader through the same escaped URL
+	runner := strings.ReplaceAll(bundleRunner, "app.", "my%20%22app%22%20%3C1%3E.")
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node is not installed")
+	}
+
+	out := runTestCommand(t, "node", writeTestFile(t, dir, "runner.mjs", runner))
+	if expected := "[\"hello 2.5\",\"[1, \\\"a\\\"]\"]\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+// TinyGo and Go share the Go class of wasm_exec.js, so a GOOS=js build
+// with Go's bridge stands in for TinyGo where it is not installed
+func TestGoBundle(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wasmExec, err := os.ReadFile(filepath.Join(strings.TrimSpace(string(goroot)), "lib", "wasm", "wasm_exec.js"))
+	if err != nil {
+		t.Skipf("wasm_exec.js not found: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := WriteGoProgram(dir, generateGo(t, bundleProgram, GoOptions{})); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "app.wasm", string(buildWasm(t, dir, "js")))
+
+	if err := WriteBundle(dir, "app", string(wasmExec), testutil.Lower(t, bundleProgram)); err != nil {
+		t.Fatal(err)
+	}
+
+	if out, expected := runBundle(t, dir), "[\"hello 2.5\",\"[1, \\\"a\\\"]\"]\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
+
+const exportsProgram = `say("loaded")
+
+export func add(a: int, b: int) int {
+	return a + b
+}
+
+export func shout(words: list[str], loud: bool) str {
+	var text: str = ""
+	for i in 0..2 {
+		text = text + words[i]
+	}
+	if loud {
+		return text + "!"
+	}
+	return text
+}
+
+export func pair(x: float) list {
+	say("pair", x, end="")
+	return [x, "x", [true]]
+}`
+
+// calls the exported functions through the typed methods of the loader
+const exportsRunner = `import { readFileSync } from "node:fs";
+import { load } from "./app.js";
+
+const lines = [];
+const program = await load({
+  module: readFileSync(new URL("app.wasm", import.meta.url)),
+  print: (line) => lines.push(line),
+});
+await program.run();
+lines.push(String(program.add(2, 40n)), program.shout(["a", "é"], true));
+lines.push(JSON.stringify(program.pair(1.5), (key, value) => typeof value === "bigint" ? value + "n" : value));
+for (const call of [() => program.add(1.5, 1), () => program.add(2n ** 63n, 1), () => program.shout(["a"], 1)]) {
+  try {
+    call();
+  } catch (err) {
+    lines.push(err.name);
+  }
+}
+console.log(JSON.stringify(lines));
+`
+
+func TestBundleExports(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node is not installed")
+	}
+
+	prog := testutil.Lower(t, exportsProgram)
+	module, err := wasm.Generate(prog)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writeTestFile(t, dir, "app.wasm", string(module))
+	if err := WriteBundle(dir, "app", "", prog); err != nil {
+		t.Fatal(err)
+	}
+
+	declarations, _ := os.ReadFile(filepath.Join(dir, "app.d.ts"))
+	for _, expected := range []string{
+		"export type Value = bigint | number | boolean | string | Value[];",
+		"  add(a: bigint | number, b: bigint | number): bigint;",
+		"  shout(words: string[], loud: boolean): string;",
+		"  pair(x: number): Value[];",
+	} {
+		if !strings.Contains(string(declarations), expected) {
+			t.Errorf("expected app.d.ts to contain %q, got:\n%s", expected, declarations)
+		}
+	}
+
+	out := runTestCommand(t, "node", writeTestFile(t, dir, "runner.mjs", exportsRunner))
+	expected := `["loaded","42","aé!","pair 1.5","[1.5,\"x\",[true]]","TypeError","TypeError","TypeError"]` + "\n"
+	if out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+}
@@ -0,0 +1,266 @@
+package backend
+
+import (
+	"fmt"
+
+	"simplescript/internal/ast"
+	"simplescript/internal/bytecode"
+	"simplescript/internal/ir"
+	"simplescript/internal/types"
+)
+
+// Compiles the IR into a bytecode chunk for the VM. Every IR local gets
+// the slot of its ID, and the blocks are laid out in order, so a jump to
+// the next block falls through and a jump back to a loop head is OP_LOOP.
+// Functions follow the script's code, each with slots of its own.
+func CompileBytecode(prog *ir.Program) (*bytecode.Chunk, error) {
+	c := NewCompiler(prog.File)
+	c.chunk.NumLocals = len(prog.Locals)
+
+	for i, fn := range prog.Funcs {
+		c.functions[fn] = i
+	}
+
+	for _, block := range prog.Blocks {
+		if err := c.compileBlock(block); err != nil {
+			return nil, err
+		}
+	}
+
+	c.inFunction = true
+	for _, fn := range prog.Funcs {
+		c.chunk.Functions = append(c.chunk.Functions, bytecode.Function{
+			Name: fn.Name,
+			Entry: len(c.chunk.Code),
+			NumParams: len(fn.Params),
+			NumLocals: len(fn.Locals),
+		})
+
+		for _, block := range fn.Blocks {
+			if err := c.compileBlock(block); err != nil {
+				return nil, fmt.Errorf("function %s: %v", fn.Name, err)
+			}
+		}
+	}
+
+	return c.chunk, nil
+}
+
+func (c *Compiler) emit(span ast.Span, op bytecode.Opcode, operands ...int) int {
+	pos := span.Start
+	return c.chunk.Emit(bytecode.Position{Line: pos.Line, Col: pos.Col}, op, operands...)
+}
+
+func (c *Compiler) compileBlock(block *ir.Block) error {
+	c.starts[block] = len(c.chunk.Code)
+	for _, jump := range c.pending[block] {
+		if err := c.chunk.PatchJump(jump, len(c.chunk.Code)); err != nil {
+			return err
+		}
+	}
+
+	for _, instr := range block.Instrs {
+		if err := c.compileInstr(instr); err != nil {
+			return err
+		}
+	}
+
+	next := block.Index + 1
+
+	switch t := block.Term.(type) {
+	case *ir.Jump:
+		if t.Target.Index != next {
+			c.jump(t, t.Target)
+		}
+
+	case *ir.Branch:
+		if err := c.push(t, t.Cond); err != nil {
+			return err
+		}
+		c.check(t, t.Cond, types.Bool)
+
+		// conditional jumps only go forward, so unless the then block comes
+		// next and the else block later, a false condition skips over an
+		// unconditional jump to then
+		if t.Then.Index == next && t.Else.Index > block.Index {
+			c.pending[t.Else] = append(c.pending[t.Else], c.emit(t.Span(), bytecode.OP_JUMP_IF_FALSE, 0))
+			break
+		}
+
+		otherwise := c.emit(t.Span(), bytecode.OP_JUMP_IF_FALSE, 0)
+		c.jump(t, t.Then)
+		if err := c.chunk.PatchJump(otherwise, len(c.chunk.Code)); err != nil {
+			return err
+		}
+
+		if t.Else.Index != next {
+			c.jump(t, t.Else)
+		}
+
+	case *ir.Return:
+		if !c.inFunction {
+			c.emit(t.Span(), bytecode.OP_HALT)
+			break
+		}
+
+		if t.Value != nil {
+			if err := c.push(t, t.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(t.Span(), bytecode.OP_RETURN)
+
+	default:
+		return fmt.Errorf("block b%d has no terminator", block.Index)
+	}
+
+	return nil
+}
+
+// emits an unconditional jump to target, backward when it was already laid out
+func (c *Compiler) jump(node ir.Terminator, target *ir.Block) {
+	if start, ok := c.starts[target]; ok {
+		distance := len(c.chunk.Code) + bytecode.OP_LOOP.Width() - start
+		c.emit(node.Span(), bytecode.OP_LOOP, distance)
+		return
+	}
+
+	c.pending[target] = append(c.pending[target], c.emit(node.Span(), bytecode.OP_JUMP, 0))
+}
+
+func (c *Compiler) compileInstr(instr ir.Instr) error {
+	span := instr.Span()
+
+	// say with a separator or ending builds the whole line as one string
+	// and prints it
+	if say, ok := instr.(*ir.Say); ok && (say.Sep != nil || say.End != nil) {
+		return c.compileSayWith(say)
+	}
+
+	for _, v := range ir.Uses(instr) {
+		if err := c.push(instr, v); err != nil {
+			return err
+		}
+	}
+
+	switch i := instr.(type) {
+	case *ir.Copy: c.check(i, i.Src, i.Dst.Type)
+	case *ir.Unary: c.emit(span, bytecode.OP_NEGATE)
+	case *ir.Binary:
+		op, ok := bytecode.BinaryOpcodes[i.Op]
+		if !ok {
+			return fmt.Errorf("unsupported operator '%s'", i.Op)
+		}
+
+		c.emit(span, op)
+	case *ir.MakeList: c.emit(span, bytecode.OP_LIST, len(i.Elems))
+	case *ir.Load: c.emit(span, bytecode.OP_INDEX)
+	case *ir.Store: c.emit(span, bytecode.OP_SET_INDEX)
+	case *ir.Display: c.emit(span, bytecode.OP_DISPLAY)
+	case *ir.Say: c.emit(span, bytecode.OP_SAY, len(i.Args))
+	case *ir.Args: c.emit(span, bytecode.OP_ARGS)
+	case *ir.Env: c.emit(span, bytecode.OP_ENV)
+	case *ir.Call:
+		index, ok := c.functions[i.Func]
+		if !ok {
+			return fmt.Errorf("call of %s, which is not part of the program", i.Func.Name)
+		}
+
+		c.emit(span, bytecode.OP_CALL, index)
+	default:
+		return fmt.Errorf("unsupported instruction %T", instr)
+	}
+
+	if dst := ir.Defines(instr); dst != nil {
+		c.emit(span, bytecode.OP_SET_LOCAL, dst.ID)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileSayWith(say *ir.Say) error {
+	span := say.Span()
+
+	text := func(v ir.Value, fallback string) error {
+		if v == nil {
+			return c.pushConstant(say, fallback)
+		}
+
+		if err := c.push(say, v); err != nil {
+			return err
+		}
+
+		c.check(say, v, types.Str)
+		return nil
+	}
+
+	if err := c.pushConstant(say, ""); err != nil {
+		return err
+	}
+
+	for i, arg := range say.Args {
+		if i > 0 {
+			if err := text(say.Sep, " "); err != nil {
+				return err
+			}
+			c.emit(span, bytecode.OP_ADD)
+		}
+
+		if err := c.push(say, arg); err != nil {
+			return err
+		}
+		c.emit(span, bytecode.OP_DISPLAY)
+		c.emit(span, bytecode.OP_ADD)
+	}
+
+	if err := text(say.End, "\n"); err != nil {
+		return err
+	}
+	c.emit(span, bytecode.OP_ADD)
+	c.emit(span, bytecode.OP_PRINT)
+
+	return nil
+}
+
+// pushes an operand, reading a local from its slot
+func (c *Compiler) push(node interface{ Span() ast.Span }, v ir.Value) error {
+	switch v := v.(type) {
+	case *ir.Local:
+		c.emit(node.Span(), bytecode.OP_GET_LOCAL, v.ID)
+		return nil
+	case *ir.Const:
+		// the VM's ints are Go ints, like the interpreter's
+		if n, ok := v.Value.(int64); ok {
+			return c.pushConstant(node, int(n))
+		}
+
+		return c.pushConstant(node, v.Value)
+	}
+
+	return fmt.Errorf("invalid operand %T", v)
+}
+
+// checks the value on top when it comes from an operand of no static type,
+// like a list element, and is used as want
+func (c *Compiler) check(node interface{ Span() ast.Span }, v ir.Value, want types.Type) {
+	if !types.IsUnknown(v.ValueType()) || types.IsUnknown(want) {
+		return
+	}
+
+	name := types.Erase(want).String()
+	for i, checked := range bytecode.CheckTypes {
+		if checked == name {
+			c.emit(node.Span(), bytecode.OP_CHECK, i)
+		}
+	}
+}
+
+func (c *Compiler) pushConstant(node interface{ Span() ast.Span }, value any) error {
+	index, err := c.chunk.AddConstant(value)
+	if err != nil {
+		return err
+	}
+
+	c.emit(node.Span(), bytecode.OP_CONSTANT, index)
+	return nil
+}
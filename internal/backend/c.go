@@ -0,0 +1,463 @@
+package backend
+
+import (
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"simplescript/internal/ast"
+	"simplescript/internal/ir"
+	"simplescript/internal/types"
+)
+
+// The header-only runtime generated C programs include. It is written
+// next to the generated file so any C99 compiler can build the pair.
+//
+//go:embed simplescript.h
+var CRuntimeHeader string
+
+const CRuntimeHeaderName = "simplescript.h"
+
+// C keywords and the macros and typedefs the runtime header pulls in
+var cReserved = map[string]bool{
+	"auto": true, "break": true, "case": true, "char": true, "const": true,
+	"continue": true, "default": true, "do": true, "double": true, "else": true,
+	"enum": true, "extern": true, "float": true, "for": true, "goto": true,
+	"if": true, "inline": true, "int": true, "long": true, "register": true,
+	"restrict": true, "return": true, "short": true, "signed": true, "sizeof": true,
+	"static": true, "struct": true, "switch": true, "typedef": true, "union": true,
+	"unsigned": true, "void": true, "volatile": true, "while": true,
+	"bool": true, "true": true, "false": true, "main": true, "errno": true,
+	"stdin": true, "stdout": true, "stderr": true, "assert": true,
+	"int64_t": true, "uint64_t": true, "size_t": true, "va_list": true,
+}
+
+// lists are pointers, declared by cDeclaration
+var cTypes = map[types.Type]string{
+	types.Int: "int64_t",
+	types.Float: "double",
+	types.Bool: "bool",
+	types.Str: "ss_str",
+	types.Unknown: "ss_value",
+}
+
+var cWriters = map[types.Type]string{
+	types.Int: "ss_write_int",
+	types.Float: "ss_write_float",
+	types.Bool: "ss_write_bool",
+	types.Str: "ss_write_str",
+	types.AnyList: "ss_write_list",
+	types.Unknown: "ss_write_value",
+}
+
+var cDisplays = map[types.Type]string{
+	types.Int: "ss_display_int",
+	types.Float: "ss_display_float",
+	types.Bool: "ss_display_bool",
+	types.Str: "ss_display_str",
+	types.AnyList: "ss_display_list",
+	types.Unknown: "ss_display_value",
+}
+
+var cIntOps = map[string]string{"+": "ss_add", "-": "ss_sub", "*": "ss_mul", "/": "ss_div"}
+
+// what every local starts as, so no path reads an uninitialized variable
+var cZeros = map[types.Type]string{
+	types.Int: "0",
+	types.Float: "0.0",
+	types.Bool: "false",
+	types.Str: `SS_STR("")`,
+	types.AnyList: "NULL",
+	types.Unknown: "{ SS_INT, { 0 } }",
+}
+
+type CGenerator struct {
+	prog *ir.Program
+	body strings.Builder
+	names map[*ir.Local]string
+	labels map[*ir.Block]bool // blocks some goto jumps to
+	consts map[*ir.Local]*ir.Const // constants initialized with a literal
+	at string // the position of the code being generated, as a C string
+}
+
+func NewCGenerator(prog *ir.Program) *CGenerator {
+	return &CGenerator{prog: prog}
+}
+
+// Transpiles the IR into a C99 translation unit defining
+// `void ss_run(void)` and, unless SS_NO_MAIN is defined, a main calling it
+// after recording the arguments args() returns. Like in the Go backend,
+// every local is declared at the top of ss_run and blocks jump to each
+// other with goto. Each function becomes a C function named ss_fn_<name>,
+// declared before ss_run and defined after it.
+func GenerateC(prog *ir.Program) (string, error) {
+	g := NewCGenerator(prog)
+
+	run, err := g.genBody(&prog.Body, nil)
+	if err != nil {
+		return "", err
+	}
+
+	prototypes, functions := "", ""
+	for _, fn := range prog.Funcs {
+		code, err := g.genBody(&fn.Body, fn)
+		if err != nil {
+			return "", fmt.Errorf("function %s: %v", fn.Name, err)
+		}
+
+		signature := g.signature(fn)
+		prototypes += signature + ";\n"
+		functions += "\n" + signature + " {\n" + code + "}\n"
+	}
+
+	if prototypes != "" {
+		prototypes += "\n"
+	}
+
+	return "/* Code generated by SimpleScript. DO NOT EDIT. */\n\n" +
+		"#include \"" + CRuntimeHeaderName + "\"\n\n" + prototypes +
+		"void ss_run(void) {\n" + run + "}\n" + functions + "\n" +
+		"#ifndef SS_NO_MAIN\nint main(int argc, char **argv) {\n\tss_argc = argc;\n\tss_argv = argv;\n\tss_run();\n\treturn 0;\n}\n#endif\n", nil
+}
+
+// the statements of a body, which belongs to fn or, when nil, to the script
+func (g *CGenerator) genBody(body *ir.Body, fn *ir.Func) (string, error) {
+	g.body.Reset()
+	g.names = localNames(body, cName, nil)
+	g.labels = jumpTargets(body)
+	g.consts = goConsts(body)
+	read := readLocals(body)
+
+	params := map[*ir.Local]bool{}
+	if fn != nil {
+		for _, param := range fn.Params {
+			params[param] = true
+		}
+	}
+
+	for _, local := range body.Locals {
+		name := g.names[local]
+
+		switch value, ok := g.consts[local]; {
+		case params[local]:
+		case ok: g.emit("%s = %s;", cDeclaration(local.Type, name, true), g.value(value, nil))
+		default: g.emit("%s = %s;", cDeclaration(local.Type, name, false), cZeros[types.Erase(local.Type)])
+		}
+
+		// -Wall warns about variables that are only ever assigned, and
+		// -Wextra about unused parameters
+		if !read[local] {
+			g.emit("(void)%s;", name)
+		}
+	}
+
+	for _, block := range body.Blocks {
+		if err := g.genBlock(block); err != nil {
+			return "", err
+		}
+	}
+
+	return g.body.String(), nil
+}
+
+// the C declaration of fn, with the names genBody gave its parameters
+func (g *CGenerator) signature(fn *ir.Func) string {
+	params := []string{}
+	for _, param := range fn.Params {
+		params = append(params, cDeclaration(param.Type, g.names[param], false))
+	}
+
+	if len(params) == 0 {
+		params = append(params, "void")
+	}
+
+	declarator := cFuncName(fn) + "(" + strings.Join(params, ", ") + ")"
+	if fn.Result == nil {
+		return "void " + declarator
+	}
+
+	return cDeclaration(fn.Result, declarator, false)
+}
+
+func cFuncName(fn *ir.Func) string {
+	return "ss_fn_" + fn.Name
+}
+
+func (g *CGenerator) emit(format string, args ...any) {
+	g.body.WriteByte('\t')
+	fmt.Fprintf(&g.body, format, args...)
+	g.body.WriteByte('\n')
+}
+
+// every block ends in a statement, so a label always has one to go on
+func (g *CGenerator) genBlock(block *ir.Block) error {
+	if g.labels[block] {
+		fmt.Fprintf(&g.body, "%s:\n", label(block))
+	}
+
+	for _, instr := range block.Instrs {
+		if copy, ok := instr.(*ir.Copy); ok && g.consts[copy.Dst] != nil {
+			continue
+		}
+
+		g.at = g.pos(instr.Span())
+		if err := g.genInstr(instr); err != nil {
+			return err
+		}
+	}
+
+	next := block.Index + 1
+
+	switch t := block.Term.(type) {
+	case *ir.Jump:
+		if t.Target.Index != next {
+			g.emit("goto %s;", label(t.Target))
+		}
+
+	case *ir.Branch:
+		g.at = g.pos(t.Span())
+		cond := g.value(t.Cond, types.Bool)
+
+		switch {
+		case t.Then.Index == next:
+			g.emit("if (!(%s)) goto %s;", cond, label(t.Else))
+		default:
+			g.emit("if (%s) goto %s;", cond, label(t.Then))
+			if t.Else.Index != next {
+				g.emit("goto %s;", label(t.Else))
+			}
+		}
+
+	case *ir.Return:
+		if t.Value != nil {
+			g.emit("return %s;", g.value(t.Value, nil))
+		} else {
+			g.emit("return;")
+		}
+	}
+
+	return nil
+}
+
+func (g *CGenerator) genInstr(instr ir.Instr) error {
+	switch i := instr.(type) {
+	case *ir.Copy:
+		g.assign(i.Dst, g.value(i.Src, i.Dst.Type))
+
+	case *ir.Unary:
+		x := g.value(i.X, nil)
+
+		switch types.Erase(i.X.ValueType()) {
+		case types.Unknown: g.assign(i.Dst, "ss_negate("+x+", "+g.at+")")
+		case types.Int: g.assign(i.Dst, "ss_neg("+x+", "+g.at+")")
+		default: g.assign(i.Dst, "-"+x)
+		}
+
+	case *ir.Binary:
+		code, err := g.binary(i)
+		if err != nil {
+			return err
+		}
+
+		g.assign(i.Dst, code)
+
+	case *ir.MakeList:
+		elements := []string{strconv.Itoa(len(i.Elems))}
+		for _, el := range i.Elems {
+			elements = append(elements, g.value(el, types.Unknown))
+		}
+
+		g.assign(i.Dst, "ss_list_of("+strings.Join(elements, ", ")+")")
+
+	case *ir.Load:
+		g.assign(i.Dst, fmt.Sprintf("*ss_at(%s, %s, %s)", g.value(i.List, types.AnyList), g.value(i.Index, types.Int), g.at))
+
+	case *ir.Store:
+		g.emit("*ss_at(%s, %s, %s) = %s;", g.value(i.List, types.AnyList), g.value(i.Index, types.Int), g.at, g.value(i.Value, types.Unknown))
+
+	case *ir.Say:
+		sep, end := "ss_write_sep();", "ss_write_end();"
+		if i.Sep != nil {
+			sep = fmt.Sprintf("ss_write_str(%s);", g.value(i.Sep, types.Str))
+		}
+		if i.End != nil {
+			end = fmt.Sprintf("ss_write_str(%s);", g.value(i.End, types.Str))
+		}
+
+		parts := []string{}
+		for n, arg := range i.Args {
+			if n > 0 {
+				parts = append(parts, sep)
+			}
+
+			parts = append(parts, fmt.Sprintf("%s(%s);", cWriters[types.Erase(arg.ValueType())], g.value(arg, nil)))
+		}
+
+		g.emit("%s", strings.Join(append(parts, end), " "))
+
+	case *ir.Display:
+		g.assign(i.Dst, fmt.Sprintf("%s(%s)", cDisplays[types.Erase(i.X.ValueType())], g.value(i.X, nil)))
+
+	case *ir.Args:
+		g.assign(i.Dst, "ss_args()")
+
+	case *ir.Env:
+		g.assign(i.Dst, "ss_env("+g.value(i.Name, types.Str)+")")
+
+	case *ir.Call:
+		args := []string{}
+		for n, arg := range i.Args {
+			args = append(args, g.value(arg, i.Func.Params[n].Type))
+		}
+
+		call := cFuncName(i.Func) + "(" + strings.Join(args, ", ") + ")"
+		if i.Dst == nil {
+			g.emit("%s;", call)
+		} else {
+			g.assign(i.Dst, call)
+		}
+
+	default:
+		return fmt.Errorf("unsupported instruction %T", instr)
+	}
+
+	return nil
+}
+
+func (g *CGenerator) assign(dst *ir.Local, code string) {
+	g.emit("%s = %s;", g.names[dst], code)
+}
+
+func (g *CGenerator) binary(i *ir.Binary) (string, error) {
+	x, y := g.value(i.X, nil), g.value(i.Y, nil)
+	xType, yType := types.Erase(i.X.ValueType()), types.Erase(i.Y.ValueType())
+
+	// operators on list elements are resolved by the runtime
+	if xType == types.Unknown || yType == types.Unknown {
+		x, y = boxC(x, xType), boxC(y, yType)
+
+		if ir.IsComparison(i.Op) {
+			return fmt.Sprintf("ss_compare_values(%s, %s, %s, %s)", cString(i.Op), x, y, g.at), nil
+		}
+
+		return fmt.Sprintf("ss_binary(%s, %s, %s, %s)", cString(i.Op), x, y, g.at), nil
+	}
+
+	switch xType {
+	case types.Int:
+		if fn, ok := cIntOps[i.Op]; ok {
+			return fmt.Sprintf("%s(%s, %s, %s)", fn, x, y, g.at), nil
+		}
+	case types.Str:
+		if i.Op == "+" {
+			return fmt.Sprintf("ss_concat(%s, %s)", x, y), nil
+		}
+
+		return fmt.Sprintf("ss_compare(%s, %s) %s 0", x, y, i.Op), nil
+	case types.AnyList:
+		return "", fmt.Errorf("invalid operation: operator '%s' not defined on lists", i.Op)
+	}
+
+	return fmt.Sprintf("%s %s %s", x, i.Op, y), nil
+}
+
+// Renders an operand. A list element used where want is a static type is
+// converted by the runtime, and a static value stored as a list element
+// is wrapped in its tag.
+func (g *CGenerator) value(v ir.Value, want types.Type) string {
+	var code string
+
+	switch v := v.(type) {
+	case *ir.Local:
+		code = g.names[v]
+	case *ir.Const:
+		switch value := v.Value.(type) {
+		case int64: code = strconv.FormatInt(value, 10)
+		case float64: code = cFloat(value)
+		case string: code = "SS_STR(" + cString(value) + ")"
+		case bool: code = strconv.FormatBool(value)
+		}
+	}
+
+	have := types.Erase(v.ValueType())
+	want = types.Erase(want)
+
+	switch {
+	case want == nil || have == want: return code
+	case have == types.Unknown: return "ss_as_" + want.String() + "(" + code + ", " + g.at + ")"
+	case want == types.Unknown: return boxC(code, have)
+	}
+
+	return code
+}
+
+// where a runtime error in the code of node is reported
+func (g *CGenerator) pos(span ast.Span) string {
+	return cString(fmt.Sprintf("%s:%d:%d", g.prog.File, span.Start.Line, span.Start.Col))
+}
+
+// wraps a statically typed value into a tagged ss_value
+func boxC(code string, dataType types.Type) string {
+	if dataType == types.Unknown {
+		return code
+	}
+
+	return "ss_of_" + dataType.String() + "(" + code + ")"
+}
+
+func cDeclaration(dataType types.Type, name string, isConst bool) string {
+	dataType = types.Erase(dataType)
+	cType := cTypes[dataType]
+
+	switch {
+	case dataType == types.AnyList && isConst: return "ss_list *const " + name
+	case dataType == types.AnyList: return "ss_list *" + name
+	case isConst: return "const " + cType + " " + name
+	default: return cType + " " + name
+	}
+}
+
+// renames identifiers that would collide with C or with the runtime
+func cName(name string) string {
+	if cReserved[name] || strings.HasPrefix(name, "ss_") || strings.HasPrefix(name, "SS_") ||
+		strings.HasPrefix(name, "_") || strings.ToUpper(name) == name {
+		return name + "_"
+	}
+
+	return name
+}
+
+// C doubles need a '.' or exponent to not be read as integers
+func cFloat(value float64) string {
+	code := strconv.FormatFloat(value, 'g', -1, 64)
+	if !strings.ContainsAny(code, ".e") {
+		code += ".0"
+	}
+
+	return code
+}
+
+// escapes with fixed-width octal so no escape can swallow the next
+// character, and '?' so no trigraph can form
+func cString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '"' || c == '\\' || c == '?':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7F:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
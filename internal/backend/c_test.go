@@ -0,0 +1,62 @@
+package backend
+
+import (
+	"strings"
+	"testing"
+
+	"simplescript/internal/testutil"
+)
+
+func TestGenerateC(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+		expected []string
+	}{
+		{"declarations", `var a: int = 1 const b: str = "x"`, []string{"int64_t a = 0;", "a = 1;", `const ss_str b = SS_STR("x");`}},
+		{"int arithmetic is checked", `var a: int = 7 say(a / 2, -a) a *= 2`, []string{`t_1 = ss_div(a, 2, "test.ss:1:20");`, `t_2 = ss_neg(a, "test.ss:1:27");`, "ss_write_int(t_1);", `a = ss_mul(a, 2, "test.ss:1:31");`}},
+		{"floats keep a decimal point", `var f: float = 2.0 f /= 4.0`, []string{"double f = 0.0;", "f = 2.0;", "f = f / 4.0;"}},
+		{"strings", `var s: str = "a" s += "b" say(s < "c")`, []string{"s = ss_concat(s, SS_STR(\"b\"));", `t_1 = ss_compare(s, SS_STR("c")) < 0;`}},
+		{
+			"lists",
+			`var xs: list = [1, 2.5] xs[0] = "a" say(xs[1] == 2.5)`,
+			[]string{
+				"t_1 = ss_list_of(2, ss_of_int(1), ss_of_float(2.5));",
+				`*ss_at(xs, 0, "test.ss:1:25") = ss_of_str(SS_STR("a"));`,
+				`t_2 = *ss_at(xs, 1, "test.ss:1:41");`,
+				`t_3 = ss_compare_values("==", t_2, ss_of_float(2.5), "test.ss:1:41");`,
+			},
+		},
+		{
+			"list elements",
+			`var xs: list = [1] var a: int = xs[0] xs[0] += 1`,
+			[]string{`a = ss_as_int(t_2, "test.ss:1:20");`, `t_4 = ss_binary("+", t_3, ss_of_int(1), "test.ss:1:39");`, `*ss_at(xs, 0, "test.ss:1:39") = t_4;`},
+		},
+		{"swap", `var a: int = 1 var b: int = 2 a, b = b, a`, []string{"t_1 = b;", "t_2 = a;", "a = t_1;", "b = t_2;"}},
+		{"self reference", `var x: int = 1 { var x: int = x + 1 }`, []string{`t_1 = ss_add(x, 1, "test.ss:1:31");`, "x_1 = t_1;"}},
+		{"reserved names", `var double: int = 1 var ss_run: int = 2 var MAX: int = 3 say(double, ss_run, MAX)`, []string{"int64_t double_ = 0;", "int64_t ss_run_ = 0;", "int64_t MAX_ = 0;"}},
+		{"strings are escaped", `say('<"??">')`, []string{`SS_STR("<\"\?\?\">")`}},
+		{"say options and format", `var n: int = 1 say(format("{}!", n), n, sep=", ")`, []string{`ss_write_str(SS_STR(", ")); ss_write_int(n); ss_write_end();`}},
+		{"else if", `var a: int = 1 if a == 1 { say(1) } else if a == 2 { say(2) } else { say(3) }`, []string{"if (!(t_1)) goto b2;", "goto b5;", "b2:", "if (!(t_2)) goto b4;", "b5:\n\treturn;"}},
+		{
+			"functions",
+			`say(add(1, 2)) log("a") func add(a: int, b: int) int { return a + b } func log(s: str) { if s == "" { return } say(s) }`,
+			[]string{"int64_t ss_fn_add(int64_t a, int64_t b);\nvoid ss_fn_log(ss_str s);\n\nvoid ss_run(void) {", "t_1 = ss_fn_add(1, 2);", `ss_fn_log(SS_STR("a"));`, "int64_t ss_fn_add(int64_t a, int64_t b) {", "\treturn t_1;\n}", "if (!(t_1)) goto b2;\n\treturn;\nb2:"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := GenerateC(testutil.Lower(t, tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, line := range tt.expected {
+				if !strings.Contains(code, line) {
+					t.Errorf("expected generated code to contain %q, got:\n%s", line, code)
+				}
+			}
+		})
+	}
+}
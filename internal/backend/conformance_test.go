@@ -0,0 +1,245 @@
+package backend
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"simplescript/internal/backend/wasm"
+	"simplescript/internal/interpreter"
+	"simplescript/internal/testutil"
+	"simplescript/internal/vm"
+)
+
+// A backend runs a program in dir and returns what it printed and the
+// runtime error it stopped with, or "" when it ran to the end.
+type conformanceBackend struct {
+	name string
+	tool string // executable the backend needs, if any
+	traps bool // runtime errors trap without a message
+	run func(t *testing.T, source string, dir string) (string, string)
+}
+
+var conformanceBackends = []conformanceBackend{
+	{"interpreter", "", false, func(t *testing.T, source string, dir string) (string, string) {
+		var out bytes.Buffer
+		err := interpreter.NewInterpreter(&out).Run(testutil.Analyze(t, source))
+		return out.String(), errorMessage(err)
+	}},
+	{"vm", "", false, func(t *testing.T, source string, dir string) (string, string) {
+		chunk, err := CompileBytecode(testutil.Lower(t, source))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		err = vm.NewVM(chunk, &out).Run()
+		return out.String(), errorMessage(err)
+	}},
+	{"go", "go", false, func(t *testing.T, source string, dir string) (string, string) {
+		return runGoProgram(t, source, dir, GoOptions{})
+	}},
+	{"c", "cc", false, func(t *testing.T, source string, dir string) (string, string) {
+		code, err := GenerateC(testutil.Lower(t, source))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		writeTestFile(t, dir, CRuntimeHeaderName, CRuntimeHeader)
+		path := writeTestFile(t, dir, "main.c", code)
+		binary := filepath.Join(dir, "main")
+
+		runTestCommand(t, "cc", "-std=c99", "-pedantic", "-Wall", "-Wextra", "-Werror", "-o", binary, path, "-lm")
+		return runTestProgram(t, binary)
+	}},
+	{"js", "node", false, func(t *testing.T, source string, dir string) (string, string) {
+		code, _, err := GenerateJS(testutil.Lower(t, source), "main.mjs", "main.ss")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return runTestProgram(t, "node", writeTestFile(t, dir, "main.mjs", code))
+	}},
+	{"native", "", true, func(t *testing.T, source string, dir string) (string, string) {
+		binary, err := wasm.Generate(testutil.Lower(t, source))
+		// simplescript.wit gives the module no arguments or environment to read
+		if err != nil && strings.Contains(err.Error(), "is not supported by the native backend") {
+			t.Skip(err)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := testutil.RunNative(t, binary)
+		return out, errorMessage(err)
+	}},
+}
+
+// Every program in testdata/conformance must print its .out file on every
+// backend, and every example what the interpreter prints. A program with
+// an .err file must then stop with that runtime error; backends that trap
+// only need to trap after printing part of the output. Backends whose
+// toolchain is not installed are skipped.
+func TestConformance(t *testing.T) {
+	files, _ := filepath.Glob("testdata/conformance/*.ss")
+	if len(files) == 0 {
+		t.Fatal("no conformance programs found")
+	}
+
+	examples, _ := filepath.Glob("../../examples/*.ss")
+
+	for _, file := range append(files, examples...) {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var expected, expectedErr string
+		if out, err := os.ReadFile(strings.TrimSuffix(file, ".ss") + ".out"); err == nil {
+			expected = string(out)
+		} else if os.IsNotExist(err) {
+			expected, _ = conformanceBackends[0].run(t, string(source), "")
+		} else {
+			t.Fatal(err)
+		}
+
+		if out, err := os.ReadFile(strings.TrimSuffix(file, ".ss") + ".err"); err == nil {
+			expectedErr = strings.TrimSpace(string(out))
+		} else if !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+
+		for _, backend := range conformanceBackends {
+			t.Run(filepath.Base(file)+"/"+backend.name, func(t *testing.T) {
+				if backend.tool != "" {
+					if _, err := exec.LookPath(backend.tool); err != nil {
+						t.Skipf("%s is not installed", backend.tool)
+					}
+				}
+
+				out, runErr := backend.run(t, string(source), t.TempDir())
+
+				switch {
+				case backend.traps && expectedErr != "":
+					if runErr == "" || !strings.HasPrefix(expected, out) {
+						t.Errorf("expected a trap after a prefix of %q, got %q and error %q", expected, out, runErr)
+					}
+				case out != expected || runErr != expectedErr:
+					t.Errorf("expected %q and error %q, got %q and error %q", expected, expectedErr, out, runErr)
+				}
+			})
+		}
+	}
+}
+
+// Programs built with --unchecked wrap on integer overflow and stop with
+// Go's own runtime errors, so the programs in testdata/unchecked print their
+// .out and .err files only from the Go backend without checks.
+func TestUncheckedConformance(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	files, _ := filepath.Glob("testdata/unchecked/*.ss")
+	if len(files) == 0 {
+		t.Fatal("no unchecked programs found")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected, err := os.ReadFile(strings.TrimSuffix(file, ".ss") + ".out")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var expectedErr string
+			if out, err := os.ReadFile(strings.TrimSuffix(file, ".ss") + ".err"); err == nil {
+				expectedErr = strings.TrimSpace(string(out))
+			} else if !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+
+			out, runErr := runGoProgram(t, string(source), t.TempDir(), GoOptions{Unchecked: true})
+			if out != string(expected) || runErr != expectedErr {
+				t.Errorf("expected %q and error %q, got %q and error %q", expected, expectedErr, out, runErr)
+			}
+		})
+	}
+}
+
+// builds source with the Go backend in dir and runs it
+func runGoProgram(t *testing.T, source string, dir string, opts GoOptions) (string, string) {
+	t.Helper()
+
+	if err := WriteGoProgram(dir, MustGenerate(testutil.Lower(t, source), opts)); err != nil {
+		t.Fatal(err)
+	}
+
+	binary := filepath.Join(dir, "main")
+	runTestCommand(t, "go", "-C", dir, "build", "-o", binary, ".")
+	return runTestProgram(t, binary)
+}
+
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// runs a compiled program, returning its output and the last line it wrote
+// to stderr when it failed
+func runTestProgram(t *testing.T, name string, args ...string) (string, string) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); ok {
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		return stdout.String(), lines[len(lines)-1]
+	}
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+
+	return stdout.String(), ""
+}
+
+func runTestCommand(t *testing.T, name string, args ...string) string {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("%s failed: %v\n%s", name, err, stderr.String())
+	}
+
+	return stdout.String()
+}
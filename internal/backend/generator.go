@@ -3,136 +3,776 @@ package backend
 import (
 	"fmt"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/dave/jennifer/jen"
 	"simplescript/internal/ast"
+	"simplescript/internal/ir"
+	"simplescript/internal/types"
 )
 
+// Maps SimpleScript types to Go native types for generation
+var goTypes = map[types.Type]string{
+	types.Int: "int",
+	types.Float: "float64",
+	types.Bool: "bool",
+	types.Str: "string",
+	types.AnyList: "[]interface{}",
+	types.Unknown: "interface{}",
+}
+
+// Go keywords, the predeclared identifiers, the runtime package the output
+// imports and its own package-level names; a variable or function with one
+// of these names gets a trailing underscore
+var goReserved = map[string]bool{
+	"break": true, "case": true, "chan": true, "const": true, "continue": true,
+	"default": true, "defer": true, "else": true, "fallthrough": true, "for": true,
+	"func": true, "go": true, "goto": true, "if": true, "import": true,
+	"interface": true, "map": true, "package": true, "range": true, "return": true,
+	"select": true, "struct": true, "switch": true, "type": true, "var": true,
+
+	"any": true, "bool": true, "byte": true, "comparable": true, "complex64": true,
+	"complex128": true, "error": true, "float32": true, "float64": true, "int": true,
+	"int8": true, "int16": true, "int32": true, "int64": true, "rune": true,
+	"string": true, "uint": true, "uint8": true, "uint16": true, "uint32": true,
+	"uint64": true, "uintptr": true, "true": true, "false": true, "iota": true,
+	"nil": true, "append": true, "cap": true, "clear": true, "close": true,
+	"complex": true, "copy": true, "delete": true, "imag": true, "len": true,
+	"make": true, "max": true, "min": true, "new": true, "panic": true,
+	"print": true, "println": true, "real": true, "recover": true,
+
+	"ssrt": true, "main": true, "init": true, "ssColumns": true, "_": true,
+}
+
+// GoOptions tune the generated Go program
+type GoOptions struct {
+	// use the raw Go operators instead of the checked runtime helpers, so
+	// errors surface as Go panics and int arithmetic wraps around
+	Unchecked bool
+	// export the script's exported functions from the wasm module, through
+	// wrappers passing values the way the native wasm backend does
+	Exports bool
+}
+
 type Generator struct {
 	file *jen.File
-	compiler *Compiler
+	prog *ir.Program
+	opts GoOptions
+	funcs map[*ir.Func]string
+	taken map[string]bool // the package-level names locals must not shadow
+	// the body being generated: the script's or a function's
+	body *ir.Body
+	fn *ir.Func // nil for the script
+	names map[*ir.Local]string
+	labels map[*ir.Block]bool // blocks some goto jumps to
+	consts map[*ir.Local]*ir.Const // constants initialized with a literal
+	spills [2]string // the variables constant operands are copied into
+	line ast.Position // the position of the last //line directive
+	columns map[int]int // the column of the first statement on each line
+	at string // the position of the instruction being generated
 }
 
-func NewGenerator(c *Compiler) *Generator {
+// gofmt indents comments, but Go only honors a //line directive that
+// starts its line
+var lineDirective = regexp.MustCompile(`(?m)^[ \t]+//line `)
+
+func NewGenerator(prog *ir.Program, opts GoOptions) *Generator {
 	f := jen.NewFile("main")
 	f.HeaderComment("Code generated by SimpleScript. DO NOT EDIT.")
-	return &Generator{file: f, compiler: c}
+	f.ImportName(GoRuntimePath, "ssrt")
+	g := &Generator{
+		file: f,
+		prog: prog,
+		opts: opts,
+		funcs: map[*ir.Func]string{},
+		taken: map[string]bool{},
+		columns: map[int]int{},
+	}
+
+	for _, fn := range prog.Funcs {
+		g.funcs[fn] = uniqueName(goName(fn.Name), g.taken)
+	}
+
+	return g
+}
+
+// switches to generating body, which belongs to fn or, when nil, to the script
+func (g *Generator) enter(body *ir.Body, fn *ir.Func) {
+	g.body, g.fn = body, fn
+	g.names = localNames(body, goName, g.taken)
+	g.labels = jumpTargets(body)
+	g.consts = goConsts(body)
+	g.line = ast.Position{}
+
+	taken := map[string]bool{}
+	for name := range g.taken {
+		taken[name] = true
+	}
+	for _, name := range g.names {
+		taken[name] = true
+	}
+	g.spills = [2]string{uniqueName("x", taken), uniqueName("y", taken)}
 }
 
-// Transpiles the SimpleScript AST into valid Go code
-func MustGenerate(compiler *Compiler, prog *ast.Program) string {
-	g := NewGenerator(compiler)
-	code, err := g.generate(prog)
+// Transpiles the IR into valid Go code
+func Generate(prog *ir.Program, opts GoOptions) (string, error) {
+	return NewGenerator(prog, opts).generate()
+}
+
+// Generate for the command line, exiting on failure
+func MustGenerate(prog *ir.Program, opts GoOptions) string {
+	code, err := Generate(prog, opts)
 
 	if err != nil {
-		fmt.Printf("Generation Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Generation Error: %v\n", err)
 		os.Exit(1)
 	}
 
 	return code
 }
 
-func (g *Generator) generate(prog *ast.Program) (string, error) {
-	g.file.Func().Id("main").Params().BlockFunc(func(b *jen.Group) {
-		for _, stmt := range prog.Statements {
-			g.genStatement(b, stmt)
-		}
+// Every local is declared up front, so the gotos between blocks never
+// jump over a declaration. A constant initialized with a literal becomes a
+// Go const; any other constant is a var the IR never assigns twice.
+// Statements carry //line directives pointing back at the script, and the
+// runtime library reports Go runtime panics at those positions. Unless the
+// options say otherwise, indexing, int arithmetic and list elements used
+// as a static type go through checked runtime functions that fail with a
+// RuntimeError before Go would panic. Functions become Go functions of the
+// same name, declared after main.
+func (g *Generator) generate() (string, error) {
+	if g.opts.Exports {
+		g.genExports()
+	}
+
+	code := []jen.Code{}
+
+	g.enter(&g.prog.Body, nil)
+	main, err := g.genBody(jen.Func().Id("main").Params(), func(b *jen.Group) {
+		b.Defer().Qual(GoRuntimePath, "Recover").Call(jen.Lit(g.prog.File), jen.Id("ssColumns"))
 	})
+	if err != nil {
+		return "", err
+	}
+	code = append(code, main)
+
+	for _, fn := range g.prog.Funcs {
+		g.enter(&fn.Body, fn)
 
-	return fmt.Sprintf("%#v", g.file), nil
+		params := []jen.Code{}
+		for _, param := range fn.Params {
+			params = append(params, jen.Id(g.names[param]).Id(goTypes[types.Erase(param.Type)]))
+		}
+
+		signature := jen.Func().Id(g.funcs[fn]).Params(params...)
+		if fn.Result != nil {
+			signature.Id(goTypes[types.Erase(fn.Result)])
+		}
+
+		decl, err := g.genBody(signature, nil)
+		if err != nil {
+			return "", fmt.Errorf("function %s: %v", fn.Name, err)
+		}
+		code = append(code, decl)
+	}
+
+	// the column the runtime reports a panic on each line at, since it
+	// only knows the line of the failing frame
+	columns := jen.Dict{}
+	for line, col := range g.columns {
+		columns[jen.Lit(line)] = jen.Lit(col)
+	}
+
+	g.file.Var().Id("ssColumns").Op("=").Map(jen.Int()).Int().Values(columns)
+
+	// main and the functions come last so the directives in them cover
+	// nothing else
+	for _, decl := range code {
+		g.file.Line().Add(decl)
+	}
+
+	return lineDirective.ReplaceAllString(fmt.Sprintf("%#v", g.file), "//line "), nil
 }
 
-func (g *Generator) genStatement(group *jen.Group, stmt ast.Statement) {
-	switch s := stmt.(type) {
-	case *ast.VarDecl:
-		goType := "interface{}"
-		if info, ok := g.compiler.LookupVariable(s.Name); ok {
-			goType = g.compiler.GetGoType(info.Type)
+// the Go types the wrappers of exported functions pass each type as
+var exportTypes = map[types.Type]string{
+	types.Int: "int64",
+	types.Float: "float64",
+	types.Bool: "int32",
+	types.Str: "uint64",
+	types.AnyList: "uint32",
+}
+
+// Declares a wrapper exporting each exported function from the wasm module
+// under its own name, and the alloc export the host writes str and list
+// arguments with. The runtime keeps those arguments until the call returns
+// and the returned values until the next call, so the host can read them.
+func (g *Generator) genExports() {
+	exported := false
+
+	for _, fn := range g.prog.Funcs {
+		if !fn.Exported {
+			continue
 		}
+		exported = true
 
-		var stmtBuilder *jen.Statement
-		if s.IsConst {
-			stmtBuilder = jen.Const()
+		params, args := []jen.Code{}, []jen.Code{}
+		for i, param := range fn.Params {
+			name := "p" + strconv.Itoa(i)
+			dataType := types.Erase(param.Type)
+			params = append(params, jen.Id(name).Id(exportTypes[dataType]))
+
+			switch dataType {
+			case types.Int: args = append(args, jen.Int().Call(jen.Id(name)))
+			case types.Bool: args = append(args, jen.Qual(GoRuntimePath, "ImportBool").Call(jen.Id(name)))
+			case types.Str: args = append(args, jen.Qual(GoRuntimePath, "ImportStr").Call(jen.Id(name)))
+			case types.AnyList: args = append(args, jen.Qual(GoRuntimePath, "ImportList").Call(jen.Id(name)))
+			default: args = append(args, jen.Id(name))
+			}
+		}
+
+		wrapper := jen.Func().Id(uniqueName("export_"+g.funcs[fn], g.taken)).Params(params...)
+		body := []jen.Code{
+			jen.Qual(GoRuntimePath, "BeginCall").Call(),
+			jen.Defer().Qual(GoRuntimePath, "EndCall").Call(),
+		}
+
+		call := jen.Id(g.funcs[fn]).Call(args...)
+		if fn.Result == nil {
+			body = append(body, call)
 		} else {
-			stmtBuilder = jen.Var()
+			dataType := types.Erase(fn.Result)
+			wrapper.Id(exportTypes[dataType])
+
+			switch dataType {
+			case types.Int: call = jen.Int64().Call(call)
+			case types.Bool: call = jen.Qual(GoRuntimePath, "ExportBool").Call(call)
+			case types.Str: call = jen.Qual(GoRuntimePath, "ExportStr").Call(call)
+			case types.AnyList: call = jen.Qual(GoRuntimePath, "ExportList").Call(call)
+			}
+			body = append(body, jen.Return(call))
+		}
+
+		g.file.Comment("//export " + fn.Name).Line().Add(wrapper.Block(body...))
+	}
+
+	if exported {
+		alloc := jen.Func().Id(uniqueName("alloc", g.taken)).Params(jen.Id("size").Uint32()).Uint32()
+		g.file.Comment("//export alloc").Line().Add(alloc.Block(
+			jen.Return(jen.Qual(GoRuntimePath, "Alloc").Call(jen.Id("size"))),
+		))
+	}
+}
+
+// Completes the declaration of the current body with its locals, after
+// whatever prologue adds, and its blocks. Parameters are declared by the
+// signature.
+func (g *Generator) genBody(signature *jen.Statement, prologue func(b *jen.Group)) (*jen.Statement, error) {
+	read := readLocals(g.body)
+	params := map[*ir.Local]bool{}
+	if g.fn != nil {
+		for _, param := range g.fn.Params {
+			params[param] = true
 		}
+	}
+
+	var genErr error
+	decl := signature.BlockFunc(func(b *jen.Group) {
+		if prologue != nil {
+			prologue(b)
+		}
+
+		for _, local := range g.body.Locals {
+			if params[local] {
+				continue
+			}
+
+			goType := jen.Id(goTypes[types.Erase(local.Type)])
+
+			if value, ok := g.consts[local]; ok {
+				b.Const().Id(g.names[local]).Add(goType).Op("=").Add(g.genValue(value, nil))
+				continue
+			}
+
+			b.Var().Id(g.names[local]).Add(goType)
+
+			// Go rejects variables that are assigned but never read
+			if !read[local] {
+				b.Id("_").Op("=").Id(g.names[local])
+			}
+		}
+
+		for _, block := range g.body.Blocks {
+			if err := g.genBlock(b, block); err != nil && genErr == nil {
+				genErr = err
+			}
+		}
+	})
+
+	return decl, genErr
+}
 
-		group.Add(stmtBuilder.Id(s.Name).Id(goType).Op("=").Add(g.genExpression(s.Value)))
+// points the following Go code at the script position the IR was lowered
+// from; panics tells whether the code can fail at runtime
+func (g *Generator) position(group *jen.Group, span ast.Span, panics bool) {
+	pos := span.Start
+	if pos.Line == 0 {
+		return
+	}
+
+	if col, ok := g.columns[pos.Line]; panics && (!ok || pos.Col < col) {
+		g.columns[pos.Line] = pos.Col
+	}
+
+	if pos == g.line {
+		return
+	}
+
+	group.Comment(fmt.Sprintf("//line %s:%d:%d", g.prog.File, pos.Line, pos.Col))
+	g.line = pos
+}
 
-	case *ast.Assignment:
-		target := s.Targets[0]
-		val := g.genExpression(s.Values[0])
-		group.Add(g.genExpression(target)).Op(s.Operator).Add(val)
 
-	case *ast.SayStmt:
+func (g *Generator) genBlock(group *jen.Group, block *ir.Block) error {
+	if g.labels[block] {
+		group.Id(label(block)).Op(":")
+	}
+
+	for _, instr := range block.Instrs {
+		if copy, ok := instr.(*ir.Copy); ok && g.consts[copy.Dst] != nil {
+			continue
+		}
+
+		g.at = g.pos(instr.Span())
+		code, err := g.genInstr(instr)
+		if err != nil {
+			return err
+		}
+
+		g.position(group, instr.Span(), g.mayPanic(instr))
+		group.Add(code)
+	}
+
+	next := block.Index + 1
+
+	switch t := block.Term.(type) {
+	case *ir.Jump:
+		if t.Target.Index != next {
+			group.Goto().Id(label(t.Target))
+		}
+
+	case *ir.Branch:
+		g.at = g.pos(t.Span())
+		g.position(group, t.Span(), g.opts.Unchecked && types.IsUnknown(t.Cond.ValueType()))
+		cond := g.genValue(t.Cond, types.Bool)
+
+		switch {
+		case t.Then.Index == next:
+			group.If(jen.Op("!").Parens(cond)).Block(jen.Goto().Id(label(t.Else)))
+		default:
+			group.If(cond).Block(jen.Goto().Id(label(t.Then)))
+			if t.Else.Index != next {
+				group.Goto().Id(label(t.Else))
+			}
+		}
+
+	case *ir.Return:
+		switch {
+		case t.Value != nil:
+			group.Return(g.genValue(t.Value, nil))
+		case next < len(g.body.Blocks):
+			group.Return()
+		}
+	}
+
+	return nil
+}
+
+func (g *Generator) genInstr(instr ir.Instr) (jen.Code, error) {
+	switch i := instr.(type) {
+	case *ir.Copy:
+		return g.local(i.Dst).Op("=").Add(g.genValue(i.Src, i.Dst.Type)), nil
+
+	case *ir.Unary:
+		x := g.genValue(i.X, nil)
+
+		switch {
+		case types.IsUnknown(i.X.ValueType()):
+			return g.local(i.Dst).Op("=").Add(g.runtime("Negate", x)), nil
+		case g.checked(i.X):
+			return g.local(i.Dst).Op("=").Add(g.runtime("Neg", x)), nil
+		}
+
+		operands, decls := g.spill(i.X)
+		return g.declaring(i, decls, g.local(i.Dst).Op("=").Op(i.Op).Add(operands[0])), nil
+
+	case *ir.Binary:
+		x, y := g.genValue(i.X, nil), g.genValue(i.Y, nil)
+		name, arithmetic := goCheckedOps[i.Op]
+
+		// operators on list elements are resolved by the runtime
+		if types.IsUnknown(i.X.ValueType()) || types.IsUnknown(i.Y.ValueType()) {
+			if arithmetic {
+				return g.local(i.Dst).Op("=").Add(g.runtime("Binary", jen.Lit(i.Op), x, y)), nil
+			}
+
+			return g.local(i.Dst).Op("=").Add(g.runtime("Compare", jen.Lit(i.Op), x, y)), nil
+		}
+
+		if arithmetic && g.checked(i.X) && g.checked(i.Y) {
+			return g.local(i.Dst).Op("=").Add(g.runtime(name, x, y)), nil
+		}
+
+		// Go also rejects a division by the constant zero, even for floats
+		operands, decls := []jen.Code{x, y}, []jen.Code(nil)
+		if divisor := g.constant(i.Y); divisor != nil && (g.constant(i.X) != nil || divides(i.Op, divisor)) {
+			operands, decls = g.spill(i.X, i.Y)
+		}
+
+		return g.declaring(i, decls, g.local(i.Dst).Op("=").Add(operands[0]).Op(i.Op).Add(operands[1])), nil
+
+	case *ir.MakeList:
+		elements := []jen.Code{}
+		for _, el := range i.Elems {
+			elements = append(elements, g.genValue(el, nil))
+		}
+
+		return g.local(i.Dst).Op("=").Index().Interface().Values(elements...), nil
+
+	case *ir.Load:
+		return g.local(i.Dst).Op("=").Add(g.genValue(i.List, types.AnyList)).Index(g.index(i, i.List, i.Index)), nil
+
+	case *ir.Store:
+		return jen.Add(g.genValue(i.List, types.AnyList)).Index(g.index(i, i.List, i.Index)).Op("=").Add(g.genValue(i.Value, nil)), nil
+
+	case *ir.Say:
 		args := []jen.Code{}
+		for _, arg := range i.Args {
+			args = append(args, g.genValue(arg, nil))
+		}
+
+		if i.Sep == nil && i.End == nil {
+			return jen.Qual(GoRuntimePath, "Say").Call(args...), nil
+		}
 
-		for _, arg := range s.Args {
-			args = append(args, g.genExpression(arg))
+		options := []jen.Code{jen.Lit(" "), jen.Lit("\n")}
+		if i.Sep != nil {
+			options[0] = g.genValue(i.Sep, types.Str)
+		}
+		if i.End != nil {
+			options[1] = g.genValue(i.End, types.Str)
 		}
 
-		group.Add(jen.Qual("fmt", "Println").Call(args...))
+		return jen.Qual(GoRuntimePath, "SayWith").Call(append(options, args...)...), nil
 
-	case *ast.IfStmt:
-		group.Add(g.genIf(s))
+	case *ir.Display:
+		return g.local(i.Dst).Op("=").Qual(GoRuntimePath, "Display").Call(g.genValue(i.X, nil)), nil
 
-	case *ast.ForStmt:
-		start := g.genExpression(s.Start)
-		end := g.genExpression(s.End)
+	case *ir.Args:
+		return g.local(i.Dst).Op("=").Qual(GoRuntimePath, "Args").Call(), nil
 
-		group.Add(jen.For(
-			jen.Id(s.Iterator).Op(":=").Add(start),
-			jen.Id(s.Iterator).Op("<").Add(end),
-			jen.Id(s.Iterator).Op("++"),
-		).BlockFunc(func(b *jen.Group) {
-			g.genStatement(b, s.Body)
-		}))
+	case *ir.Env:
+		return g.local(i.Dst).Op("=").Qual(GoRuntimePath, "Env").Call(g.genValue(i.Name, types.Str)), nil
 
-	case *ast.Block:
-		for _, bStmt := range s.Statements {
-			g.genStatement(group, bStmt)
+	case *ir.Call:
+		args := []jen.Code{}
+		for n, arg := range i.Args {
+			args = append(args, g.genValue(arg, i.Func.Params[n].Type))
 		}
+
+		call := jen.Id(g.funcs[i.Func]).Call(args...)
+		if i.Dst == nil {
+			return call, nil
+		}
+
+		return g.local(i.Dst).Op("=").Add(call), nil
+	}
+
+	return nil, fmt.Errorf("unsupported instruction %T", instr)
+}
+
+// the index operand of a Load or Store, bounds-checked unless unchecked
+func (g *Generator) index(instr ir.Instr, list, index ir.Value) jen.Code {
+	if g.opts.Unchecked {
+		return g.genValue(index, types.Int)
 	}
+
+	return g.runtime("Index", g.genValue(list, types.AnyList), g.genValue(index, types.Int))
+}
+
+// reports whether an operand gets checked int arithmetic
+func (g *Generator) checked(v ir.Value) bool {
+	return !g.opts.Unchecked && v.ValueType() == types.Int
+}
+
+// calls a runtime function with the position of the current instruction
+// as the last argument
+func (g *Generator) runtime(name string, args ...jen.Code) *jen.Statement {
+	return jen.Qual(GoRuntimePath, name).Call(append(args, jen.Lit(g.at))...)
+}
+
+// the position a runtime error at span is reported at
+func (g *Generator) pos(span ast.Span) string {
+	return fmt.Sprintf("%s:%d:%d", g.prog.File, span.Start.Line, span.Start.Col)
 }
 
-func (g *Generator) genIf(s *ast.IfStmt) *jen.Statement {
-	cond := g.genExpression(s.Condition)
+// Renders an operand. A list element used where want is a static type is
+// converted by the runtime, or by a type assertion in unchecked code.
+func (g *Generator) genValue(v ir.Value, want types.Type) *jen.Statement {
+	var code *jen.Statement
 
-	ifStmt := jen.If(cond).BlockFunc(func(b *jen.Group) {
-		g.genStatement(b, s.Consequence)
+	switch v := v.(type) {
+	case *ir.Local:
+		code = g.local(v)
+	case *ir.Const:
+		switch value := v.Value.(type) {
+		case int64: code = jen.Lit(int(value))
+		default: code = jen.Lit(value)
+		}
+	}
+
+	if types.IsUnknown(v.ValueType()) && !types.IsUnknown(want) {
+		if g.opts.Unchecked {
+			return code.Assert(jen.Id(goTypes[types.Erase(want)]))
+		}
+
+		return g.runtime(goConversions[types.Erase(want).String()], code)
+	}
+
+	return code
+}
+
+// the literal v holds, or nil when it is not a constant: a literal or a
+// local emitted as a Go const
+func (g *Generator) constant(v ir.Value) *ir.Const {
+	switch v := v.(type) {
+	case *ir.Const: return v
+	case *ir.Local: return g.consts[v]
+	}
+
+	return nil
+}
+
+// reports whether op is a division by c, a constant zero
+func divides(op string, c *ir.Const) bool {
+	return op == "/" && (c.Value == int64(0) || c.Value == 0.0)
+}
+
+// Go evaluates an operation on constants exactly at compile time, where
+// SimpleScript rounds every float result and unchecked ints wrap, and
+// rejects one that overflows or divides by zero, so the constant operands
+// of an operation are copied into typed variables first. Returns the operands and
+// the declarations of those variables.
+func (g *Generator) spill(values ...ir.Value) ([]jen.Code, []jen.Code) {
+	operands, decls := []jen.Code{}, []jen.Code{}
+
+	for n, v := range values {
+		if g.constant(v) == nil {
+			operands = append(operands, g.genValue(v, nil))
+			continue
+		}
+
+		goType := jen.Id(goTypes[types.Erase(v.ValueType())])
+		decls = append(decls, jen.Var().Id(g.spills[n]).Add(goType).Op("=").Add(g.genValue(v, nil)))
+		operands = append(operands, jen.Id(g.spills[n]))
+	}
+
+	return operands, decls
+}
+
+// the code for instr in a block with decls, if there are any; the block
+// repeats the position of instr, which the declarations moved off its line
+func (g *Generator) declaring(instr ir.Instr, decls []jen.Code, code *jen.Statement) jen.Code {
+	if len(decls) == 0 {
+		return code
+	}
+
+	return jen.BlockFunc(func(b *jen.Group) {
+		for _, decl := range decls {
+			b.Add(decl)
+		}
+		g.line = ast.Position{}
+		g.position(b, instr.Span(), g.mayPanic(instr))
+		b.Add(code)
 	})
+}
 
-	if s.Alternative != nil {
-		if altIf, ok := s.Alternative.(*ast.IfStmt); ok {
-			ifStmt.Else().Add(g.genIf(altIf))
-		} else {
-			ifStmt.Else().BlockFunc(func(b *jen.Group) {
-				g.genStatement(b, s.Alternative)
-			})
+// Reports whether the Go code for instr can panic rather than fail through
+// the runtime, which only unchecked code does: indexing, integer division
+// and list elements asserted to a static type
+func (g *Generator) mayPanic(instr ir.Instr) bool {
+	if !g.opts.Unchecked {
+		return false
+	}
+
+	switch i := instr.(type) {
+	case *ir.Load, *ir.Store: return true
+	case *ir.Say, *ir.MakeList, *ir.Display, *ir.Args, *ir.Call: return false
+	case *ir.Binary:
+		if i.Op == "/" {
+			return true
 		}
 	}
 
-	return ifStmt
+	for _, v := range ir.Uses(instr) {
+		if types.IsUnknown(v.ValueType()) {
+			return true
+		}
+	}
+
+	return false
 }
 
-func (g *Generator) genExpression(expr ast.Expression) jen.Code {
-	switch e := expr.(type) {
-	case *ast.IntegerLiteral: return jen.Lit(int(e.Value))
-	case *ast.FloatLiteral: return jen.Lit(e.Value)
-	case *ast.StringLiteral: return jen.Lit(e.Value)
-	case *ast.BooleanLiteral: return jen.Lit(e.Value)
-	case *ast.ListLiteral:
-		elements := []jen.Code{}
+func (g *Generator) local(l *ir.Local) *jen.Statement {
+	return jen.Id(g.names[l])
+}
+
+func label(block *ir.Block) string {
+	return "b" + strconv.Itoa(block.Index)
+}
+
+// the blocks some jump goes to instead of reaching them by falling through
+// from the block laid out before them, which need a label
+func jumpTargets(body *ir.Body) map[*ir.Block]bool {
+	targets := map[*ir.Block]bool{}
+
+	for _, block := range body.Blocks {
+		next := block.Index + 1
+
+		switch t := block.Term.(type) {
+		case *ir.Jump:
+			targets[t.Target] = targets[t.Target] || t.Target.Index != next
+		case *ir.Branch:
+			if t.Then.Index == next {
+				targets[t.Else] = true
+			} else {
+				targets[t.Then] = true
+				targets[t.Else] = targets[t.Else] || t.Else.Index != next
+			}
+		}
+	}
+
+	return targets
+}
+
+// Variables keep their name, as mangled for the target language, when it
+// is unique and not one of the reserved names, like the functions' in the
+// same scope; shadowing declarations and temporaries get a numbered suffix
+func localNames(body *ir.Body, mangle func(string) string, reserved map[string]bool) map[*ir.Local]string {
+	names := map[*ir.Local]string{}
+	taken := map[string]bool{}
+	for name := range reserved {
+		taken[name] = true
+	}
+
+	for _, local := range body.Locals {
+		if name := mangle(local.Name); !local.IsTemp() && !taken[name] {
+			names[local] = name
+			taken[name] = true
+		}
+	}
+
+	for _, local := range body.Locals {
+		if _, ok := names[local]; ok {
+			continue
+		}
+
+		base := mangle(local.Name)
+		if local.IsTemp() {
+			base = "t"
+		}
+
+		names[local] = uniqueName(base+"_1", taken)
+	}
+
+	return names
+}
+
+// name, or name with its numbered suffix increased until it is not taken;
+// the result is marked as taken
+func uniqueName(name string, taken map[string]bool) string {
+	base, n := name, 1
+	if i := strings.LastIndex(name, "_"); i >= 0 {
+		if suffix, err := strconv.Atoi(name[i+1:]); err == nil {
+			base, n = name[:i], suffix
+		}
+	}
+
+	for taken[name] {
+		n++
+		name = base + "_" + strconv.Itoa(n)
+	}
+
+	taken[name] = true
+	return name
+}
+
+func goName(name string) string {
+	if goReserved[name] {
+		return name + "_"
+	}
+
+	return name
+}
+
+// the constants whose only definition copies a literal into them
+func goConsts(body *ir.Body) map[*ir.Local]*ir.Const {
+	defs := map[*ir.Local]int{}
+	consts := map[*ir.Local]*ir.Const{}
 
-		for _, el := range e.Elements {
-			elements = append(elements, g.genExpression(el))
+	for _, block := range body.Blocks {
+		for _, instr := range block.Instrs {
+			dst := ir.Defines(instr)
+			if dst == nil {
+				continue
+			}
+
+			defs[dst]++
+
+			if copy, ok := instr.(*ir.Copy); ok && dst.Const {
+				if value, ok := copy.Src.(*ir.Const); ok {
+					consts[dst] = value
+				}
+			}
 		}
+	}
 
-		return jen.Index().Interface().Values(elements...)
-	case *ast.Identifier: return jen.Id(e.Value)
-	case *ast.PrefixExpression: return jen.Op(e.Operator).Add(g.genExpression(e.Right))
-	case *ast.InfixExpression: return jen.Parens(jen.Add(g.genExpression(e.Left)).Op(e.Operator).Add(g.genExpression(e.Right)))
-	case *ast.IndexExpression: return jen.Add(g.genExpression(e.Left)).Index(g.genExpression(e.Index))
-	default: return jen.Null()
+	for local := range consts {
+		if defs[local] != 1 {
+			delete(consts, local)
+		}
+	}
+
+	return consts
+}
+
+// the locals some instruction, branch or return reads
+func readLocals(body *ir.Body) map[*ir.Local]bool {
+	read := map[*ir.Local]bool{}
+
+	mark := func(v ir.Value) {
+		if local, ok := v.(*ir.Local); ok {
+			read[local] = true
+		}
 	}
+
+	for _, block := range body.Blocks {
+		for _, instr := range block.Instrs {
+			for _, v := range ir.Uses(instr) {
+				mark(v)
+			}
+		}
+
+		switch t := block.Term.(type) {
+		case *ir.Branch: mark(t.Cond)
+		case *ir.Return:
+			if t.Value != nil {
+				mark(t.Value)
+			}
+		}
+	}
+
+	return read
 }
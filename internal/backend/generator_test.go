@@ -0,0 +1,267 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"simplescript/internal/backend/ssrt"
+	"simplescript/internal/testutil"
+)
+
+func generateGo(t *testing.T, input string, opts GoOptions) string {
+	t.Helper()
+
+	code, err := NewGenerator(testutil.Lower(t, input), opts).generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return code
+}
+
+func TestGenerateGo(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+		opts GoOptions
+		expected []string
+	}{
+		{"keywords are mangled", `var type: int = 1 var go: str = "x" say(type, go)`, GoOptions{}, []string{"var type_ int", "var go_ string", "ssrt.Say(type_, go_)"}},
+		{"builtins and imports are mangled", `var ssrt: int = 1 var string: float = 2.0 say(ssrt, string)`, GoOptions{}, []string{"var ssrt_ int", "var string_ float64", "ssrt.Say(ssrt_, string_)"}},
+		{"mangled names stay unique", `var type: int = 1 var type_: int = 2 say(type, type_)`, GoOptions{}, []string{"var type_ int", "var type__1 int"}},
+		{"unused variables are discarded", `var a: int = 1 var b: int = 2 say(b)`, GoOptions{}, []string{"var a int\n\t_ = a\n\tvar b int\n//line"}},
+		{"literal constants are go constants", `const n: int = 3 const s: str = "x" say(n, s)`, GoOptions{}, []string{"const n int = 3", `const s string = "x"`}},
+		{"line directives", "var a: int = 1\nsay(a)", GoOptions{}, []string{"\n//line test.ss:1:1\n\ta = 1", "\n//line test.ss:2:1\n\tssrt.Say(a)"}},
+		{"checked operations", `var xs: list = [1] var i: int = 0 i = -i * 2 say(xs[i], 7 / i)`, GoOptions{}, []string{
+			`t_2 = ssrt.Neg(i, "test.ss:1:39")`,
+			`t_3 = ssrt.Mul(t_2, 2, "test.ss:1:39")`,
+			`t_4 = xs[ssrt.Index(xs, i, "test.ss:1:50")]`,
+			`t_5 = ssrt.Div(7, i, "test.ss:1:57")`,
+		}},
+		{"list element operations", `var xs: list = [1, 2] var n: int = xs[0] say(xs[0] + 1 == xs[1], -xs[0])`, GoOptions{}, []string{
+			`n = ssrt.Int(t_2, "test.ss:1:23")`,
+			`t_4 = ssrt.Binary("+", t_3, 1, "test.ss:1:46")`,
+			`t_6 = ssrt.Compare("==", t_4, t_5, "test.ss:1:46")`,
+			`t_8 = ssrt.Negate(t_7, "test.ss:1:66")`,
+		}},
+		{"unchecked operations", `var xs: list = [1] var i: int = 0 i = -i * 2 say(xs[i], 7 / i)`, GoOptions{Unchecked: true}, []string{"t_2 = -i", "t_3 = t_2 * 2", "t_4 = xs[i]", "t_5 = 7 / i"}},
+		{"unchecked operations on constants", `const m: int = 9223372036854775807 var n: int = 7 say(m + 1, n / 0)`, GoOptions{Unchecked: true}, []string{"var x int = m\n\t\tvar y int = 1", "t_1 = x + y", "var y int = 0", "t_2 = n / y"}},
+		{"say options and format", `var n: int = 1 say(format("n={}", n), sep=", ", end="")`, GoOptions{}, []string{"t_1 = ssrt.Display(n)", `t_2 = "n=" + t_1`, `ssrt.SayWith(", ", "", t_2)`}},
+		{"constant operands are variables", `say(0.1 + 0.2, -0.0)`, GoOptions{}, []string{"var x float64 = 0.1\n\t\tvar y float64 = 0.2", "t_1 = x + y", "var x float64 = 0.0", "t_2 = -x"}},
+		{"operations on literal constants", `const h: float = 0.5 const z: float = 0.0 var one: float = 1.0 say(h * 0.2, -h, one / z)`, GoOptions{}, []string{"var x float64 = h\n\t\tvar y float64 = 0.2", "t_1 = x * y", "var x float64 = h", "t_2 = -x", "var y float64 = z", "t_3 = one / y"}},
+		{"other constants are variables", `const xs: list = [1] const d: int = 1 + 1 say(xs, d)`, GoOptions{}, []string{"var xs []interface{}", "var d int"}},
+		{"functions", `say(add(1, 2)) func add(a: int, b: int) int { return a + b }`, GoOptions{}, []string{"t_1 = add(1, 2)", "func add(a int, b int) int {", "\treturn t_1\n}"}},
+		{"exported functions", `export func tag(s: str, xs: list, on: bool) list { return xs }`, GoOptions{Exports: true}, []string{
+			"//export tag\nfunc export_tag(p0 uint64, p1 uint32, p2 int32) uint32 {",
+			"return ssrt.ExportList(tag(ssrt.ImportStr(p0), ssrt.ImportList(p1), ssrt.ImportBool(p2)))",
+			"//export alloc\nfunc alloc(size uint32) uint32 {",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := generateGo(t, tt.input, tt.opts)
+
+			for _, line := range tt.expected {
+				if !strings.Contains(code, line) {
+					t.Errorf("expected generated code to contain %q, got:\n%s", line, code)
+				}
+			}
+		})
+	}
+}
+
+// Go runtime panics are reported like the interpreter reports them
+func TestGoRuntimeErrors(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	tests := []struct {
+		input string
+		opts GoOptions
+		expected string
+	}{
+		{"var z: int = 0\nsay(1 / z)", GoOptions{}, "RuntimeError: integer divide by zero at test.ss:2:5\n"},
+		{"var xs: list = [1, 2, 3]\nsay(xs[5])", GoOptions{}, "RuntimeError: index 5 out of range for list of length 3 at test.ss:2:5\n"},
+		{"var n: int = 9223372036854775807\nsay(n + 1)", GoOptions{}, "RuntimeError: integer overflow at test.ss:2:5\n"},
+		{"var xs: list = [\"a\"]\nvar n: int = 0\nn = xs[0]", GoOptions{}, "RuntimeError: type mismatch: cannot use str as int at test.ss:3:1\n"},
+		{"var xs: list = [1, \"a\"]\nsay(xs[0] + xs[1])", GoOptions{}, "RuntimeError: invalid operation 'int + str' at test.ss:2:5\n"},
+		{"var xs: list = [1, 2, 3]\nsay(xs[5])", GoOptions{Unchecked: true}, "RuntimeError: index out of range [5] with length 3 at test.ss:2:5\n"},
+		{"var xs: list = [\"a\"]\nvar n: int = 0\nn = xs[0]", GoOptions{Unchecked: true}, "RuntimeError: interface conversion: interface {} is string, not int at test.ss:3:1\n"},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := WriteGoProgram(dir, generateGo(t, tt.input, tt.opts)); err != nil {
+			t.Fatal(err)
+		}
+
+		var stderr bytes.Buffer
+		cmd := exec.Command("go", "run", ".")
+		cmd.Dir = dir
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err == nil {
+			t.Errorf("expected %q to fail", tt.input)
+		}
+
+		if !strings.HasPrefix(stderr.String(), tt.expected) {
+			t.Errorf("expected %q, got %q", tt.expected, stderr.String())
+		}
+	}
+}
+
+func TestWriteGoProgram(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteGoProgram(dir, "package main\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(goMod), "require "+GoRuntimePath+" "+ssrt.Version) {
+		t.Errorf("expected go.mod to require the runtime at %s, got:\n%s", ssrt.Version, goMod)
+	}
+
+	for _, name := range []string{"main.go", "ssrt/go.mod", "ssrt/ssrt.go", "ssrt/print.go"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "ssrt", "ssrt_test.go")); !os.IsNotExist(err) {
+		t.Errorf("expected the runtime tests to be left out")
+	}
+}
+
+// builds the module in dir for wasm under goos, js or wasip1, with the
+// standard Go toolchain, since TinyGo may not be installed where the tests run
+func buildWasm(t *testing.T, dir, goos string) []byte {
+	t.Helper()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("go", "build", "-o", "program.wasm", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH=wasm")
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("wasm build failed: %v\n%s", err, stderr.String())
+	}
+
+	module, err := os.ReadFile(filepath.Join(dir, "program.wasm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return module
+}
+
+// Browser wasm builds format values with the runtime's own printer and
+// hand each line to the print import of simplescript.wit, so fmt is never
+// linked
+func TestGoWasmPrinting(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	dir := t.TempDir()
+	code := generateGo(t, "say(1, 2.5, 'text', [true, 'a'])\nsay('no newline', end='')", GoOptions{})
+	if err := WriteGoProgram(dir, code); err != nil {
+		t.Fatal(err)
+	}
+	module := buildWasm(t, dir, "js")
+
+	// the same line printed through fmt
+	baseline := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module baseline\n\ngo 1.18\n",
+		"main.go": "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(1, 2.5, \"text\", []interface{}{true, \"a\"})\n}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(baseline, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	withFmt := buildWasm(t, baseline, "js")
+
+	t.Logf(
+		"wasm size: %d bytes with the ssrt printer, %d bytes with fmt (%d bytes, %.1f%% smaller)",
+		len(module), len(withFmt), len(withFmt)-len(module), 100*float64(len(withFmt)-len(module))/float64(len(withFmt)),
+	)
+	if len(module) >= len(withFmt) {
+		t.Errorf("expected the module to be smaller than the fmt build")
+	}
+
+	// running it needs the wasm_exec.js bridge, so only the import is checked
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	compiled, err := r.CompileModule(ctx, module)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imported := false
+	for _, fn := range compiled.ImportedFunctions() {
+		module, name, _ := fn.Import()
+		imported = imported || (module == "$root" && name == "print")
+	}
+	if !imported {
+		t.Errorf("expected the module to import print from $root")
+	}
+}
+
+// WASI builds print to stdout and read their arguments and environment
+// from the host, so they run outside the browser
+func TestGoWasi(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not installed")
+	}
+
+	dir := t.TempDir()
+	code := generateGo(t, "say(args(), env('SS_NAME'), end='')\nsay('!')\nvar xs: list = []\nsay(xs[0])", GoOptions{})
+	if err := WriteGoProgram(dir, code); err != nil {
+		t.Fatal(err)
+	}
+	module := buildWasm(t, dir, "wasip1")
+
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	wasi_snapshot_preview1.MustInstantiate(ctx, r)
+
+	var stdout, stderr bytes.Buffer
+	config := wazero.NewModuleConfig().
+		WithArgs("program", "one", "two").
+		WithEnv("SS_NAME", "wasi").
+		WithStdout(&stdout).
+		WithStderr(&stderr)
+
+	_, err := r.InstantiateWithConfig(ctx, module, config)
+	if exit, ok := err.(*sys.ExitError); !ok || exit.ExitCode() != 1 {
+		t.Fatalf("expected the runtime error to exit with 1, got %v", err)
+	}
+
+	if expected := "[\"one\", \"two\"] wasi!\n"; stdout.String() != expected {
+		t.Errorf("expected %q on stdout, got %q", expected, stdout.String())
+	}
+
+	if !strings.Contains(stderr.String(), "RuntimeError: index 0 out of range for list of length 0 at test.ss:4:5") {
+		t.Errorf("expected the runtime error on stderr, got %q", stderr.String())
+	}
+}
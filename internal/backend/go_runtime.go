@@ -0,0 +1,74 @@
+package backend
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"simplescript/internal/backend/ssrt"
+)
+
+// the import path generated programs use for the runtime library
+const GoRuntimePath = "simplescript/ssrt"
+
+// The ssrt sources, shipped inside the compiler so generated programs build
+// without network access. Its tests are embedded too but never written.
+//
+//go:embed ssrt/*.go
+var goRuntime embed.FS
+
+// the checked runtime function replacing each int operator
+var goCheckedOps = map[string]string{"+": "Add", "-": "Sub", "*": "Mul", "/": "Div"}
+
+// the runtime conversion from a value only known at runtime to each type
+var goConversions = map[string]string{"int": "Int", "float": "Float", "str": "Str", "bool": "Bool", "list": "List"}
+
+// Writes a generated program to dir as a module of its own: main.go, a
+// go.mod requiring the runtime at ssrt.Version, and the runtime sources,
+// which the go.mod points the requirement at. dir can then be built with
+// `go build` or `tinygo build` and no network access.
+func WriteGoProgram(dir, code string) error {
+	runtimeDir := filepath.Join(dir, "ssrt")
+	if err := os.MkdirAll(runtimeDir, 0755); err != nil {
+		return err
+	}
+
+	goMod := fmt.Sprintf(
+		"module program\n\ngo 1.18\n\nrequire %s %s\n\nreplace %s %s => ./ssrt\n",
+		GoRuntimePath, ssrt.Version, GoRuntimePath, ssrt.Version,
+	)
+
+	files := map[string]string{
+		filepath.Join(dir, "go.mod"): goMod,
+		filepath.Join(dir, "main.go"): code,
+		filepath.Join(runtimeDir, "go.mod"): fmt.Sprintf("module %s\n\ngo 1.18\n", GoRuntimePath),
+	}
+
+	entries, err := goRuntime.ReadDir("ssrt")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		source, err := goRuntime.ReadFile("ssrt/" + entry.Name())
+		if err != nil {
+			return err
+		}
+
+		files[filepath.Join(runtimeDir, entry.Name())] = string(source)
+	}
+
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
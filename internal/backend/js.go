@@ -0,0 +1,461 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"simplescript/internal/ast"
+	"simplescript/internal/ir"
+	"simplescript/internal/types"
+)
+
+// words that cannot name a binding in strict-mode module code
+var jsReserved = map[string]bool{
+	"arguments": true, "await": true, "break": true, "case": true, "catch": true,
+	"class": true, "const": true, "continue": true, "debugger": true, "default": true,
+	"delete": true, "do": true, "else": true, "enum": true, "eval": true,
+	"export": true, "extends": true, "false": true, "finally": true, "for": true,
+	"function": true, "if": true, "implements": true, "import": true, "in": true,
+	"instanceof": true, "interface": true, "let": true, "new": true, "null": true,
+	"package": true, "private": true, "protected": true, "public": true, "return": true,
+	"static": true, "super": true, "switch": true, "this": true, "throw": true,
+	"true": true, "try": true, "typeof": true, "var": true, "void": true,
+	"while": true, "with": true, "yield": true,
+}
+
+type JSGenerator struct {
+	prog *ir.Program
+	body strings.Builder
+	line int
+	indent int
+	helpers map[string]bool
+	mappings []sourceMapping
+	funcs map[*ir.Func]string
+	names map[*ir.Local]string
+	cases map[*ir.Block]bool // blocks the dispatch loop can jump to
+	at string // the position of the code being generated, as a JS string
+}
+
+func NewJSGenerator(prog *ir.Program) *JSGenerator {
+	// the prefix keeps functions from shadowing the globals the helpers use
+	funcs := map[*ir.Func]string{}
+	for _, fn := range prog.Funcs {
+		funcs[fn] = "$fn_" + fn.Name
+	}
+
+	return &JSGenerator{
+		prog: prog,
+		helpers: map[string]bool{},
+		funcs: funcs,
+	}
+}
+
+// Transpiles the IR into an ES module that runs the program on import, and
+// a source map pointing back at the script. JavaScript has no goto, so a
+// program with more than one block runs them in a dispatch loop, like the
+// native wasm backend: a switch on the index of the next block, whose
+// cases fall through to the block laid out next. Functions become module
+// functions, and the exported ones are exported from the module too.
+func GenerateJS(prog *ir.Program, outputName, sourceName string) (string, string, error) {
+	g := NewJSGenerator(prog)
+
+	if err := g.genBody(&prog.Body, nil); err != nil {
+		return "", "", err
+	}
+
+	// text printed without a final newline is still pending
+	if g.helpers["write"] {
+		g.emit(nil, "$flush();")
+	}
+
+	g.indent = 0
+	g.emit(nil, "}")
+
+	exports := []string{}
+	for _, fn := range prog.Funcs {
+		g.emit(nil, "")
+		if err := g.genBody(&fn.Body, fn); err != nil {
+			return "", "", fmt.Errorf("function %s: %v", fn.Name, err)
+		}
+		g.indent = 0
+		g.emit(nil, "}")
+
+		if fn.Exported {
+			exports = append(exports, g.funcs[fn]+" as "+fn.Name)
+		}
+	}
+
+	if len(exports) > 0 {
+		g.emit(nil, "")
+		g.emit(nil, "export { %s };", strings.Join(exports, ", "))
+	}
+
+	var head strings.Builder
+	head.WriteString("// Code generated by SimpleScript. DO NOT EDIT.\n\n")
+	for _, name := range jsHelperOrder {
+		if g.helpers[name] {
+			head.WriteString(jsHelpers[name])
+			head.WriteString("\n\n")
+		}
+	}
+	head.WriteString("export function run() {\n")
+
+	// the body was generated first so only the helpers it needs are included
+	offset := strings.Count(head.String(), "\n")
+	for i := range g.mappings {
+		g.mappings[i].genLine += offset
+	}
+
+	code := head.String() + g.body.String() +
+		"\nrun();\n\n//# sourceMappingURL=" + outputName + ".map\n"
+
+	sourceMap, err := encodeSourceMap(outputName, sourceName, g.mappings)
+	if err != nil {
+		return "", "", err
+	}
+
+	return code, sourceMap, nil
+}
+
+// the statements of a body, which belongs to fn or, when nil, to the script
+func (g *JSGenerator) genBody(body *ir.Body, fn *ir.Func) error {
+	g.names = localNames(body, jsName, nil)
+	g.cases = jumpTargets(body)
+	g.cases[body.Blocks[0]] = true
+	consts := goConsts(body)
+
+	params := map[*ir.Local]bool{}
+	if fn != nil {
+		names := []string{}
+		for _, param := range fn.Params {
+			params[param] = true
+			names = append(names, g.names[param])
+		}
+
+		g.emit(nil, "function %s(%s) {", g.funcs[fn], strings.Join(names, ", "))
+	}
+
+	g.indent = 1
+	for _, local := range body.Locals {
+		switch value, ok := consts[local]; {
+		case params[local]:
+		case ok: g.emit(nil, "const %s = %s;", g.names[local], g.value(value, nil))
+		default: g.emit(nil, "let %s;", g.names[local])
+		}
+	}
+
+	dispatch := len(body.Blocks) > 1
+	if dispatch {
+		g.emit(nil, "let $block = 0;")
+		g.emit(nil, "$run: for (;;) {")
+		g.emit(nil, "  switch ($block) {")
+		g.indent += 2
+	}
+
+	for _, block := range body.Blocks {
+		if dispatch && g.cases[block] {
+			g.indent--
+			g.emit(nil, "case %d:", block.Index)
+			g.indent++
+		}
+
+		for _, instr := range block.Instrs {
+			if copy, ok := instr.(*ir.Copy); ok && consts[copy.Dst] != nil {
+				continue
+			}
+
+			g.at = g.pos(instr.Span())
+			if err := g.genInstr(instr); err != nil {
+				return err
+			}
+		}
+
+		g.genTerm(block, fn, dispatch)
+	}
+
+	if dispatch {
+		g.indent -= 2
+		g.emit(nil, "  }")
+		g.emit(nil, "}")
+	}
+
+	return nil
+}
+
+func (g *JSGenerator) use(helper string) {
+	if g.helpers[helper] {
+		return
+	}
+
+	g.helpers[helper] = true
+	for _, dep := range jsHelperDeps[helper] {
+		g.use(dep)
+	}
+}
+
+// writes one line of the body, mapped to the start of node
+func (g *JSGenerator) emit(node interface{ Span() ast.Span }, format string, args ...any) {
+	prefix := strings.Repeat("  ", g.indent)
+
+	if node != nil {
+		start := node.Span().Start
+		g.mappings = append(g.mappings, sourceMapping{
+			genLine: g.line,
+			genCol: len(prefix),
+			srcLine: max(start.Line-1, 0),
+			srcCol: max(start.Col-1, 0),
+		})
+	}
+
+	g.body.WriteString(prefix)
+	fmt.Fprintf(&g.body, format, args...)
+	g.body.WriteByte('\n')
+	g.line++
+}
+
+// Jumps set the next block and restart the dispatch loop, unless the
+// target is laid out next and the case falls through to it. Outside the
+// loop there is a single block, which returns by ending. The script
+// leaves the loop to flush its output, while functions return directly.
+func (g *JSGenerator) genTerm(block *ir.Block, fn *ir.Func, dispatch bool) {
+	next := block.Index + 1
+
+	switch t := block.Term.(type) {
+	case *ir.Jump:
+		if t.Target.Index != next {
+			g.emit(t, "$block = %d;", t.Target.Index)
+			g.emit(nil, "continue;")
+		}
+
+	case *ir.Branch:
+		g.at = g.pos(t.Span())
+		cond := g.value(t.Cond, types.Bool)
+
+		switch {
+		case t.Then.Index == next:
+			g.emit(t, "if (!%s) { $block = %d; continue; }", cond, t.Else.Index)
+		default:
+			g.emit(t, "if (%s) { $block = %d; continue; }", cond, t.Then.Index)
+			if t.Else.Index != next {
+				g.emit(nil, "$block = %d;", t.Else.Index)
+				g.emit(nil, "continue;")
+			}
+		}
+
+	case *ir.Return:
+		switch {
+		case t.Value != nil:
+			g.at = g.pos(t.Span())
+			g.emit(t, "return %s;", g.value(t.Value, fn.Result))
+		case fn != nil && dispatch: g.emit(t, "return;")
+		case fn == nil && dispatch: g.emit(t, "break $run;")
+		}
+	}
+}
+
+func (g *JSGenerator) genInstr(instr ir.Instr) error {
+	switch i := instr.(type) {
+	case *ir.Copy:
+		g.assign(i, i.Dst, g.value(i.Src, i.Dst.Type))
+
+	case *ir.Unary:
+		if types.IsUnknown(i.X.ValueType()) {
+			g.use("negate")
+			g.assign(i, i.Dst, "$negate("+g.value(i.X, nil)+", "+g.at+")")
+			break
+		}
+
+		if i.X.ValueType() == types.Int {
+			g.use("int")
+			g.assign(i, i.Dst, "$int(-"+g.value(i.X, nil)+", "+g.at+")")
+			break
+		}
+
+		g.assign(i, i.Dst, "-"+g.value(i.X, nil))
+
+	case *ir.Binary:
+		g.assign(i, i.Dst, g.binary(i))
+
+	case *ir.MakeList:
+		elements := []string{}
+		for _, el := range i.Elems {
+			elements = append(elements, g.value(el, types.Unknown))
+		}
+
+		g.assign(i, i.Dst, "["+strings.Join(elements, ", ")+"]")
+
+	case *ir.Load:
+		g.use("load")
+		g.assign(i, i.Dst, fmt.Sprintf("$load(%s, %s, %s)", g.value(i.List, types.AnyList), g.value(i.Index, types.Int), g.at))
+
+	case *ir.Store:
+		g.use("store")
+		g.emit(i, "$store(%s, %s, %s, %s);", g.value(i.List, types.AnyList), g.value(i.Index, types.Int), g.value(i.Value, types.Unknown), g.at)
+
+	case *ir.Say:
+		args := []string{}
+		for _, arg := range i.Args {
+			args = append(args, g.display(arg))
+		}
+
+		if i.Sep == nil && i.End == nil {
+			g.use("say")
+			g.emit(i, "$say(%s);", strings.Join(args, ", "))
+			break
+		}
+
+		g.use("sayWith")
+		options := []string{`" "`, `"\n"`}
+		if i.Sep != nil {
+			options[0] = g.value(i.Sep, types.Str)
+		}
+		if i.End != nil {
+			options[1] = g.value(i.End, types.Str)
+		}
+
+		g.emit(i, "$sayWith(%s);", strings.Join(append(options, args...), ", "))
+
+	case *ir.Display:
+		switch i.X.ValueType() {
+		case types.Str: g.assign(i, i.Dst, g.value(i.X, nil))
+		case types.Int, types.Bool: g.assign(i, i.Dst, "String("+g.value(i.X, nil)+")")
+		default: g.assign(i, i.Dst, g.display(i.X))
+		}
+
+	case *ir.Args:
+		g.use("args")
+		g.assign(i, i.Dst, "$args()")
+
+	case *ir.Env:
+		g.use("env")
+		g.assign(i, i.Dst, "$env("+g.value(i.Name, types.Str)+")")
+
+	case *ir.Call:
+		args := []string{}
+		for n, arg := range i.Args {
+			args = append(args, g.value(arg, i.Func.Params[n].Type))
+		}
+
+		call := g.funcs[i.Func] + "(" + strings.Join(args, ", ") + ")"
+		if i.Dst == nil {
+			g.emit(i, "%s;", call)
+		} else {
+			g.assign(i, i.Dst, call)
+		}
+
+	default:
+		return fmt.Errorf("unsupported instruction %T", instr)
+	}
+
+	return nil
+}
+
+func (g *JSGenerator) assign(instr ir.Instr, dst *ir.Local, code string) {
+	g.emit(instr, "%s = %s;", g.names[dst], code)
+}
+
+func (g *JSGenerator) binary(i *ir.Binary) string {
+	x, y := g.value(i.X, nil), g.value(i.Y, nil)
+
+	// operators on list elements are resolved by the runtime
+	if types.IsUnknown(i.X.ValueType()) || types.IsUnknown(i.Y.ValueType()) {
+		x, y = g.value(i.X, types.Unknown), g.value(i.Y, types.Unknown)
+
+		if ir.IsComparison(i.Op) {
+			g.use("compare")
+			return fmt.Sprintf("$compare(%s, %s, %s, %s)", jsString(i.Op), x, y, g.at)
+		}
+
+		g.use("binary")
+		return fmt.Sprintf("$binary(%s, %s, %s, %s)", jsString(i.Op), x, y, g.at)
+	}
+
+	switch {
+	case i.Op == "/" && i.X.ValueType() == types.Int:
+		g.use("idiv")
+		return fmt.Sprintf("$idiv(%s, %s, %s)", x, y, g.at)
+	case i.X.ValueType() == types.Int && !ir.IsComparison(i.Op):
+		g.use("int")
+		return fmt.Sprintf("$int(%s %s %s, %s)", x, i.Op, y, g.at)
+	case i.Op == "==" || i.Op == "!=":
+		return fmt.Sprintf("%s %s= %s", x, i.Op, y)
+	}
+
+	return fmt.Sprintf("%s %s %s", x, i.Op, y)
+}
+
+// renders a value for `say` and format in the canonical display format
+func (g *JSGenerator) display(v ir.Value) string {
+	code := g.value(v, nil)
+
+	switch types.Erase(v.ValueType()) {
+	case types.Float:
+		g.use("float")
+		return "$float(" + code + ")"
+	case types.AnyList:
+		g.use("list")
+		return "$list(" + code + ")"
+	case types.Unknown:
+		g.use("any")
+		return "$any(" + code + ")"
+	}
+
+	return code
+}
+
+// Renders an operand. Ints are BigInts, so they keep all 64 bits and stay
+// apart from floats in lists, and a list element used where want is a
+// static type is checked by the runtime.
+func (g *JSGenerator) value(v ir.Value, want types.Type) string {
+	var code string
+
+	switch v := v.(type) {
+	case *ir.Local:
+		code = g.names[v]
+	case *ir.Const:
+		switch value := v.Value.(type) {
+		case int64: code = strconv.FormatInt(value, 10) + "n"
+		case float64: code = strconv.FormatFloat(value, 'g', -1, 64)
+		case string: code = jsString(value)
+		case bool: code = strconv.FormatBool(value)
+		}
+	}
+
+	have := types.Erase(v.ValueType())
+	want = types.Erase(want)
+
+	switch {
+	case want == nil || have == want: return code
+	case have == types.Unknown:
+		g.use("as")
+		return fmt.Sprintf("$as(%s, %s, %s)", code, jsString(want.String()), g.at)
+	}
+
+	return code
+}
+
+// where a runtime error in the code of node is reported
+func (g *JSGenerator) pos(span ast.Span) string {
+	return jsString(fmt.Sprintf("%s:%d:%d", g.prog.File, span.Start.Line, span.Start.Col))
+}
+
+func jsName(name string) string {
+	if jsReserved[name] {
+		return name + "_"
+	}
+
+	return name
+}
+
+// JSON string literals are valid JavaScript; HTML escaping only hurts readability
+func jsString(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,203 @@
+package backend
+
+// Helpers prepended to generated ES modules when used, in this order.
+// Their `$` prefix cannot clash with SimpleScript identifiers.
+var jsHelperOrder = []string{
+	"float", "quote", "any", "list", "write", "say", "sayWith", "fail", "int", "idiv", "type",
+	"as", "load", "store", "negate", "binary", "compare", "args", "env",
+}
+
+var jsHelperDeps = map[string][]string{
+	"any": {"float", "list"},
+	"list": {"any", "quote"},
+	"say": {"write"},
+	"sayWith": {"write"},
+	"fail": {"write"},
+	"int": {"fail"},
+	"idiv": {"int", "fail"},
+	"as": {"type", "fail"},
+	"load": {"fail"},
+	"store": {"fail"},
+	"negate": {"type", "int", "fail"},
+	"binary": {"type", "int", "idiv", "fail"},
+	"compare": {"type", "fail"},
+}
+
+var jsHelpers = map[string]string{
+	// the canonical display: shortest digits, exponent form below 1e-4 and
+	// from 1e+16, and a ".0" on floats without a fraction
+	"float": `function $float(value) {
+  if (Number.isNaN(value)) return "NaN";
+  if (value === Infinity) return "+Inf";
+  if (value === -Infinity) return "-Inf";
+  if (value === 0) return Object.is(value, -0) ? "-0.0" : "0.0";
+
+  const sign = value < 0 ? "-" : "";
+  const [mantissa, exponent] = Math.abs(value).toExponential().split("e");
+  const digits = mantissa.replace(".", "");
+  const exp = Number(exponent);
+
+  if (exp < -4 || exp >= 16) {
+    const fraction = digits.length > 1 ? "." + digits.slice(1) : "";
+    const expSign = exp < 0 ? "-" : "+";
+    return sign + digits[0] + fraction + "e" + expSign + String(Math.abs(exp)).padStart(2, "0");
+  }
+
+  if (exp < 0) return sign + "0." + "0".repeat(-exp - 1) + digits;
+  if (digits.length <= exp + 1) return sign + digits + "0".repeat(exp + 1 - digits.length) + ".0";
+  return sign + digits.slice(0, exp + 1) + "." + digits.slice(exp + 1);
+}`,
+
+	// strings inside lists are quoted, escaping only what Go's display does
+	"quote": `function $quote(text) {
+  const escapes = { "\n": "\\n", "\r": "\\r", "\t": "\\t" };
+  return '"' + text.replace(/["\\\n\r\t]/g, (c) => escapes[c] || "\\" + c) + '"';
+}`,
+
+	"any": `function $any(value) {
+  if (typeof value === "number") return $float(value);
+  if (Array.isArray(value)) return $list(value);
+  return String(value);
+}`,
+
+	"list": `function $list(list) {
+  return "[" + list.map((v) => typeof v === "string" ? $quote(v) : $any(v)).join(", ") + "]";
+}`,
+
+	// console.log always ends a line, so text after the last newline waits
+	// for the next write or the end of the program. Node can write it as is;
+	// the browser console can only log it as a line of its own.
+	"write": `let $pending = "";
+
+function $write(text) {
+  const lines = ($pending + text).split("\n");
+  $pending = lines.pop();
+  for (const line of lines) console.log(line);
+}
+
+function $flush() {
+  if ($pending !== "" && globalThis.process) process.stdout.write($pending);
+  else if ($pending !== "") console.log($pending);
+  $pending = "";
+}`,
+
+	"say": `function $say(...parts) {
+  $write(parts.join(" ") + "\n");
+}`,
+
+	"sayWith": `function $sayWith(sep, end, ...parts) {
+  $write(parts.join(sep) + end);
+}`,
+
+	// ints are BigInts, so every result is exact and one that does not fit
+	// in 64 bits is an overflow rather than a silently rounded number
+	"int": `function $int(value, pos) {
+  if (BigInt.asIntN(64, value) !== value) $fail(pos, "integer overflow");
+  return value;
+}`,
+
+	// BigInt division truncates toward zero like Go's
+	"idiv": `function $idiv(a, b, pos) {
+  if (b === 0n) $fail(pos, "integer divide by zero");
+  return $int(a / b, pos);
+}`,
+
+	// Reports a runtime error at pos, a "file.ss:line:col" position, after
+	// the output printed so far. Node exits like the other backends; in the
+	// browser, where there is no process, it becomes an exception.
+	"fail": `function $fail(pos, message) {
+  const error = "RuntimeError: " + message + " at " + pos;
+  $flush();
+  if (!globalThis.process) throw new Error(error);
+  console.error(error);
+  process.exit(1);
+}`,
+
+	// the SimpleScript name of a value's type; ints are BigInts and floats
+	// are numbers
+	"type": `function $type(value) {
+  if (Array.isArray(value)) return "list";
+  if (typeof value === "bigint") return "int";
+  if (typeof value === "number") return "float";
+  if (typeof value === "string") return "str";
+  return "bool";
+}`,
+
+	// a list element used as a static type
+	"as": `function $as(value, type, pos) {
+  if ($type(value) !== type) $fail(pos, "type mismatch: cannot use " + $type(value) + " as " + type);
+  return value;
+}`,
+
+	"load": `function $load(list, index, pos) {
+  if (index < 0n || index >= list.length) {
+    $fail(pos, "index " + index + " out of range for list of length " + list.length);
+  }
+  return list[Number(index)];
+}`,
+
+	"store": `function $store(list, index, value, pos) {
+  if (index < 0n || index >= list.length) {
+    $fail(pos, "index " + index + " out of range for list of length " + list.length);
+  }
+  list[Number(index)] = value;
+}`,
+
+	"negate": `function $negate(value, pos) {
+  if ($type(value) === "float") return -value;
+  if ($type(value) === "int") return $int(-value, pos);
+  $fail(pos, "invalid operation: cannot use '-' on " + $type(value));
+}`,
+
+	// arithmetic on list elements: ints, floats, and strings for "+"
+	"binary": `function $binary(op, a, b, pos) {
+  const type = $type(a);
+  if (type === $type(b) && type === "int") {
+    switch (op) {
+      case "+": return $int(a + b, pos);
+      case "-": return $int(a - b, pos);
+      case "*": return $int(a * b, pos);
+      default: return $idiv(a, b, pos);
+    }
+  }
+  if (type === $type(b) && type === "float") {
+    switch (op) {
+      case "+": return a + b;
+      case "-": return a - b;
+      case "*": return a * b;
+      default: return a / b;
+    }
+  }
+  if (type === "str" && $type(b) === "str" && op === "+") return a + b;
+  $fail(pos, "invalid operation '" + type + " " + op + " " + $type(b) + "'");
+}`,
+
+	// comparisons of list elements: values of different types are never
+	// equal, bools only have equality and lists cannot be compared at all
+	"compare": `function $compare(op, a, b, pos) {
+  const type = $type(a);
+  if (type === $type(b) && type !== "bool" && type !== "list") {
+    switch (op) {
+      case "==": return a === b;
+      case "!=": return a !== b;
+      case "<": return a < b;
+      case "<=": return a <= b;
+      case ">": return a > b;
+      default: return a >= b;
+    }
+  }
+  if ((op === "==" || op === "!=") && type !== "list" && $type(b) !== "list") {
+    return (type === $type(b) && a === b) === (op === "==");
+  }
+  $fail(pos, "invalid operation '" + type + " " + op + " " + $type(b) + "'");
+}`,
+
+	// browsers have no process, so their programs see no arguments or variables
+	"args": `function $args() {
+  return globalThis.process ? process.argv.slice(2) : [];
+}`,
+
+	"env": `function $env(name) {
+  return globalThis.process?.env[name] ?? "";
+}`,
+}
@@ -0,0 +1,153 @@
+package backend
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"simplescript/internal/testutil"
+)
+
+func TestGenerateJS(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+		expected []string
+	}{
+		{"declarations", `var a: int = 1 const b: str = "x"`, []string{"let a;", "a = 1n;", `const b = "x";`}},
+		{"integer division truncates", `var a: int = 7 say(a / 2) a /= 2`, []string{`t_1 = $idiv(a, 2n, "test.ss:1:20");`, "$say(t_1);", `a = $idiv(a, 2n, "test.ss:1:27");`}},
+		{"float division", `var f: float = 7.0 say(f / 2.0) f /= 2.0`, []string{"t_1 = f / 2;", "$say($float(t_1));", "f = f / 2;"}},
+		{"swap", `var a: int = 1 var b: int = 2 a, b = b, a`, []string{"t_1 = b;", "t_2 = a;", "a = t_1;", "b = t_2;"}},
+		{
+			"lists",
+			`var xs: list = [1, 2.5] xs[0] = 0.5 say(xs, xs[1])`,
+			[]string{
+				"t_1 = [1n, 2.5];",
+				`$store(xs, 0n, 0.5, "test.ss:1:25");`,
+				`t_2 = $load(xs, 1n, "test.ss:1:45");`,
+				"$say($list(xs), $any(t_2));",
+			},
+		},
+		{
+			"list elements",
+			`var xs: list = [1] var a: int = xs[0] say(xs[0] == 1) xs[0] += 1`,
+			[]string{`a = $as(t_2, "int", "test.ss:1:20");`, `t_4 = $compare("==", t_3, 1n, "test.ss:1:43");`, `t_6 = $binary("+", t_5, 1n, "test.ss:1:55");`},
+		},
+		{
+			"ints are BigInts",
+			`var a: int = 9223372036854775807 say(a - 1, -a, a * 2 > a)`,
+			[]string{"a = 9223372036854775807n;", `t_1 = $int(a - 1n, "test.ss:1:38");`, `t_2 = $int(-a, "test.ss:1:45");`, `t_3 = $int(a * 2n, "test.ss:1:49");`, "t_4 = t_3 > a;"},
+		},
+		{"equality", `var s: str = "a" say(s == "a", s != "b")`, []string{`t_1 = s === "a";`, `t_2 = s !== "b";`}},
+		{"reserved names", `var new: int = 1 say(new)`, []string{"let new_;", "$say(new_);"}},
+		{"strings are escaped", `say('<"tab">')`, []string{`$say("<\"tab\">");`}},
+		{"say options and format", `var f: float = 2.0 say(format("f={}!", f), end="")`, []string{"t_1 = $float(f);", `$sayWith(" ", "", t_3);`, "$flush();"}},
+		{
+			"functions",
+			`say(add(1, 2)) log("a") export func add(a: int, b: int) int { return a + b } func log(s: str) { if s == "" { return } say(s) }`,
+			[]string{"t_1 = $fn_add(1n, 2n);", `$fn_log("a");`, "function $fn_add(a, b) {", "  return t_1;\n}", "      return;\n    case 2:", "export { $fn_add as add };"},
+		},
+		{
+			"control flow",
+			`for i in 0..3 { if i == 1 { continue } else if i == 2 { break } else { say(i) } }`,
+			[]string{
+				"  let $block = 0;\n  $run: for (;;) {\n    switch ($block) {\n    case 0:\n      i = 0n;\n    case 1:",
+				"      if (!t_1) { $block = 9; continue; }",
+				"      $block = 8;\n      continue;",
+				"    case 9:\n      break $run;",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, err := GenerateJS(testutil.Lower(t, tt.input), "test.js", "test.ss")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, line := range tt.expected {
+				if !strings.Contains(code, line) {
+					t.Errorf("expected generated code to contain %q, got:\n%s", line, code)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateJSOnlyIncludesUsedHelpers(t *testing.T) {
+	code, _, err := GenerateJS(testutil.Lower(t, `say("hi")`), "test.js", "test.ss")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(code, "function $say(") || strings.Contains(code, "$float") {
+		t.Errorf("unexpected helpers in:\n%s", code)
+	}
+}
+
+func TestJSSourceMap(t *testing.T) {
+	code, sourceMap, err := GenerateJS(testutil.Lower(t, "var a: int = 1\n\n  say(a)"), "test.js", "test.ss")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(code, "//# sourceMappingURL=test.js.map\n") {
+		t.Errorf("missing source map reference:\n%s", code)
+	}
+
+	var parsed struct {
+		Version int
+		File string
+		Sources []string
+		Mappings string
+	}
+
+	if err := json.Unmarshal([]byte(sourceMap), &parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.Version != 3 || parsed.File != "test.js" || len(parsed.Sources) != 1 || parsed.Sources[0] != "test.ss" {
+		t.Errorf("unexpected source map header: %+v", parsed)
+	}
+
+	// `a = 1n` maps to 1:1 and `$say(a)` on the next line to 3:3
+	lines := strings.Split(code, "\n")
+	groups := strings.Split(parsed.Mappings, ";")
+	for i, line := range lines {
+		if strings.Contains(line, "a = 1n;") {
+			if groups[i] != "EAAA" || groups[i+1] != "EAEE" {
+				t.Errorf("unexpected mappings %q at line %d", parsed.Mappings, i)
+			}
+			return
+		}
+	}
+
+	t.Fatalf("assignment not found in:\n%s", code)
+}
+
+// ints that leave 64 bits fail instead of losing precision as numbers
+func TestJSIntegerOverflow(t *testing.T) {
+	if _, err := exec.LookPath("node"); err != nil {
+		t.Skip("node is not installed")
+	}
+
+	for _, input := range []string{
+		`var a: int = 9223372036854775807 say(a + 1)`,
+		`var a: int = -9223372036854775807 say(a - 2)`,
+		`var a: int = 4611686018427387904 say(a * 2)`,
+		`var a: int = -9223372036854775807 - 1 say(-a)`,
+		`var xs: list = [-9223372036854775807 - 1] say(xs[0] / -1)`,
+	} {
+		code, _, err := GenerateJS(testutil.Lower(t, input), "main.mjs", "main.ss")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		out, err := exec.Command("node", writeTestFile(t, t.TempDir(), "main.mjs", code)).CombinedOutput()
+		if err == nil || !strings.Contains(string(out), "integer overflow") {
+			t.Errorf("expected %q to fail with an integer overflow, got %v:\n%s", input, err, out)
+		}
+	}
+}
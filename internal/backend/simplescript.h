@@ -0,0 +1,447 @@
+/*
+ * SimpleScript C runtime: strings, lists, printing and the checked integer
+ * semantics and runtime errors of the Go backend. Header-only, so a generated program is a single C99
+ * translation unit: cc -std=c99 program.c -lm
+ */
+#ifndef SIMPLESCRIPT_H
+#define SIMPLESCRIPT_H
+
+#include <stdarg.h>
+#include <stdbool.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <math.h>
+
+/* strings are immutable byte slices; concatenation leaks like TinyGo's -gc=leaking */
+typedef struct {
+	const char *ptr;
+	size_t len;
+} ss_str;
+
+#define SS_STR(literal) ((ss_str){ (literal), sizeof(literal) - 1 })
+
+typedef struct ss_list ss_list;
+
+typedef enum { SS_INT, SS_FLOAT, SS_BOOL, SS_STR_TAG, SS_LIST } ss_tag;
+
+/* a list element, the equivalent of Go's interface{} */
+typedef struct {
+	ss_tag tag;
+	union {
+		int64_t i;
+		double f;
+		bool b;
+		ss_str s;
+		ss_list *l;
+	} as;
+} ss_value;
+
+struct ss_list {
+	size_t len;
+	ss_value *items;
+};
+
+/*
+ * Reports a runtime error at pos, a "file.ss:line:col" position, after the
+ * output printed so far, and stops the program like the Go backend's
+ * runtime. Failures without a position in the script, like running out of
+ * memory, pass NULL.
+ */
+static inline void ss_fail(const char *pos, const char *message) {
+	fflush(stdout);
+	if (pos == NULL) fprintf(stderr, "RuntimeError: %s\n", message);
+	else fprintf(stderr, "RuntimeError: %s at %s\n", message, pos);
+	exit(1);
+}
+
+/* int arithmetic fails on overflow, checked before it could be undefined */
+static inline int64_t ss_add(int64_t a, int64_t b, const char *pos) {
+	if ((b > 0 && a > INT64_MAX - b) || (b < 0 && a < INT64_MIN - b)) ss_fail(pos, "integer overflow");
+	return a + b;
+}
+
+static inline int64_t ss_sub(int64_t a, int64_t b, const char *pos) {
+	if ((b < 0 && a > INT64_MAX + b) || (b > 0 && a < INT64_MIN + b)) ss_fail(pos, "integer overflow");
+	return a - b;
+}
+
+static inline int64_t ss_mul(int64_t a, int64_t b, const char *pos) {
+	bool overflow = a > 0
+		? (b > 0 ? a > INT64_MAX / b : b < INT64_MIN / a)
+		: (b > 0 ? a < INT64_MIN / b : a != 0 && b < INT64_MAX / a);
+	if (overflow) ss_fail(pos, "integer overflow");
+	return a * b;
+}
+
+static inline int64_t ss_neg(int64_t a, const char *pos) {
+	if (a == INT64_MIN) ss_fail(pos, "integer overflow");
+	return -a;
+}
+
+static inline int64_t ss_div(int64_t a, int64_t b, const char *pos) {
+	if (b == 0) ss_fail(pos, "integer divide by zero");
+	if (a == INT64_MIN && b == -1) ss_fail(pos, "integer overflow");
+	return a / b;
+}
+
+static inline ss_str ss_concat(ss_str a, ss_str b) {
+	char *ptr = malloc(a.len + b.len + 1);
+	if (ptr == NULL) ss_fail(NULL, "out of memory");
+
+	memcpy(ptr, a.ptr, a.len);
+	memcpy(ptr + a.len, b.ptr, b.len);
+	ptr[a.len + b.len] = '\0';
+
+	return (ss_str){ ptr, a.len + b.len };
+}
+
+/* byte-wise ordering, returning -1, 0 or 1 */
+static inline int ss_compare(ss_str a, ss_str b) {
+	size_t n = a.len < b.len ? a.len : b.len;
+	int c = n > 0 ? memcmp(a.ptr, b.ptr, n) : 0;
+
+	if (c != 0) return c < 0 ? -1 : 1;
+	return (a.len > b.len) - (a.len < b.len);
+}
+
+static inline ss_value ss_of_int(int64_t v) { ss_value x; x.tag = SS_INT; x.as.i = v; return x; }
+static inline ss_value ss_of_float(double v) { ss_value x; x.tag = SS_FLOAT; x.as.f = v; return x; }
+static inline ss_value ss_of_bool(bool v) { ss_value x; x.tag = SS_BOOL; x.as.b = v; return x; }
+static inline ss_value ss_of_str(ss_str v) { ss_value x; x.tag = SS_STR_TAG; x.as.s = v; return x; }
+static inline ss_value ss_of_list(ss_list *v) { ss_value x; x.tag = SS_LIST; x.as.l = v; return x; }
+
+/* builds a list from len ss_value arguments */
+static inline ss_list *ss_list_of(size_t len, ...) {
+	ss_list *list = malloc(sizeof(ss_list));
+	if (list == NULL) ss_fail(NULL, "out of memory");
+
+	list->len = len;
+	list->items = malloc(len > 0 ? len * sizeof(ss_value) : 1);
+	if (list->items == NULL) ss_fail(NULL, "out of memory");
+
+	va_list args;
+	va_start(args, len);
+	for (size_t i = 0; i < len; i++) {
+		list->items[i] = va_arg(args, ss_value);
+	}
+	va_end(args);
+
+	return list;
+}
+
+static inline ss_value *ss_at(ss_list *list, int64_t index, const char *pos) {
+	if (index < 0 || (uint64_t)index >= list->len) {
+		char message[96];
+		snprintf(message, sizeof message, "index %lld out of range for list of length %lu",
+			(long long)index, (unsigned long)list->len);
+		ss_fail(pos, message);
+	}
+
+	return &list->items[index];
+}
+
+/* the command-line arguments args() returns, set by the generated main */
+static int ss_argc;
+static char **ss_argv;
+
+static inline ss_list *ss_args(void) {
+	size_t len = ss_argc > 1 ? (size_t)ss_argc - 1 : 0;
+	ss_list *list = malloc(sizeof(ss_list));
+	if (list == NULL) ss_fail(NULL, "out of memory");
+
+	list->len = len;
+	list->items = malloc(len > 0 ? len * sizeof(ss_value) : 1);
+	if (list->items == NULL) ss_fail(NULL, "out of memory");
+
+	for (size_t i = 0; i < len; i++) {
+		list->items[i] = ss_of_str((ss_str){ ss_argv[i + 1], strlen(ss_argv[i + 1]) });
+	}
+
+	return list;
+}
+
+/* getenv needs a terminated name; an unset variable is "" */
+static inline ss_str ss_env(ss_str name) {
+	char *key = malloc(name.len + 1);
+	if (key == NULL) ss_fail(NULL, "out of memory");
+
+	memcpy(key, name.ptr, name.len);
+	key[name.len] = '\0';
+
+	const char *v = getenv(key);
+	free(key);
+
+	return v == NULL ? SS_STR("") : (ss_str){ v, strlen(v) };
+}
+
+/* the SimpleScript name of a list element's type */
+static inline const char *ss_type_name(ss_value v) {
+	switch (v.tag) {
+	case SS_INT: return "int";
+	case SS_FLOAT: return "float";
+	case SS_BOOL: return "bool";
+	case SS_STR_TAG: return "str";
+	default: return "list";
+	}
+}
+
+/*
+ * The conversions of a list element to the static type the program uses it
+ * as, failing with the SimpleScript type names on any other type.
+ */
+static inline void ss_mismatch(ss_value v, const char *want, const char *pos) {
+	char message[64];
+	snprintf(message, sizeof message, "type mismatch: cannot use %s as %s", ss_type_name(v), want);
+	ss_fail(pos, message);
+}
+
+static inline int64_t ss_as_int(ss_value v, const char *pos) { if (v.tag != SS_INT) ss_mismatch(v, "int", pos); return v.as.i; }
+static inline double ss_as_float(ss_value v, const char *pos) { if (v.tag != SS_FLOAT) ss_mismatch(v, "float", pos); return v.as.f; }
+static inline bool ss_as_bool(ss_value v, const char *pos) { if (v.tag != SS_BOOL) ss_mismatch(v, "bool", pos); return v.as.b; }
+static inline ss_str ss_as_str(ss_value v, const char *pos) { if (v.tag != SS_STR_TAG) ss_mismatch(v, "str", pos); return v.as.s; }
+static inline ss_list *ss_as_list(ss_value v, const char *pos) { if (v.tag != SS_LIST) ss_mismatch(v, "list", pos); return v.as.l; }
+
+static inline void ss_invalid(const char *op, ss_value a, ss_value b, const char *pos) {
+	char message[64];
+	snprintf(message, sizeof message, "invalid operation '%s %s %s'", ss_type_name(a), op, ss_type_name(b));
+	ss_fail(pos, message);
+}
+
+static inline ss_value ss_negate(ss_value a, const char *pos) {
+	if (a.tag == SS_INT) return ss_of_int(ss_neg(a.as.i, pos));
+	if (a.tag == SS_FLOAT) return ss_of_float(-a.as.f);
+
+	char message[64];
+	snprintf(message, sizeof message, "invalid operation: cannot use '-' on %s", ss_type_name(a));
+	ss_fail(pos, message);
+	return a;
+}
+
+/* arithmetic on list elements: ints, floats, and strings for "+" */
+static inline ss_value ss_binary(const char *op, ss_value a, ss_value b, const char *pos) {
+	if (a.tag == SS_INT && b.tag == SS_INT) {
+		switch (op[0]) {
+		case '+': return ss_of_int(ss_add(a.as.i, b.as.i, pos));
+		case '-': return ss_of_int(ss_sub(a.as.i, b.as.i, pos));
+		case '*': return ss_of_int(ss_mul(a.as.i, b.as.i, pos));
+		case '/': return ss_of_int(ss_div(a.as.i, b.as.i, pos));
+		}
+	}
+
+	if (a.tag == SS_FLOAT && b.tag == SS_FLOAT) {
+		switch (op[0]) {
+		case '+': return ss_of_float(a.as.f + b.as.f);
+		case '-': return ss_of_float(a.as.f - b.as.f);
+		case '*': return ss_of_float(a.as.f * b.as.f);
+		case '/': return ss_of_float(a.as.f / b.as.f);
+		}
+	}
+
+	if (a.tag == SS_STR_TAG && b.tag == SS_STR_TAG && op[0] == '+') {
+		return ss_of_str(ss_concat(a.as.s, b.as.s));
+	}
+
+	ss_invalid(op, a, b, pos);
+	return a;
+}
+
+/* applies a comparison operator to an ordering: -1, 0 or 1 */
+static inline bool ss_ordered(const char *op, int c) {
+	if (strcmp(op, "==") == 0) return c == 0;
+	if (strcmp(op, "!=") == 0) return c != 0;
+	if (strcmp(op, "<") == 0) return c < 0;
+	if (strcmp(op, "<=") == 0) return c <= 0;
+	if (strcmp(op, ">") == 0) return c > 0;
+	return c >= 0;
+}
+
+/*
+ * Comparisons of list elements. Values of different types are never equal;
+ * bools only have equality, and lists cannot be compared at all.
+ */
+static inline bool ss_compare_values(const char *op, ss_value a, ss_value b, const char *pos) {
+	bool equality = strcmp(op, "==") == 0 || strcmp(op, "!=") == 0;
+
+	if (a.tag == SS_INT && b.tag == SS_INT) return ss_ordered(op, (a.as.i > b.as.i) - (a.as.i < b.as.i));
+	if (a.tag == SS_STR_TAG && b.tag == SS_STR_TAG) return ss_ordered(op, ss_compare(a.as.s, b.as.s));
+
+	/* NaN is unordered, so floats are compared with the operators themselves */
+	if (a.tag == SS_FLOAT && b.tag == SS_FLOAT) {
+		double x = a.as.f, y = b.as.f;
+		if (strcmp(op, "==") == 0) return x == y;
+		if (strcmp(op, "!=") == 0) return x != y;
+		if (strcmp(op, "<") == 0) return x < y;
+		if (strcmp(op, "<=") == 0) return x <= y;
+		if (strcmp(op, ">") == 0) return x > y;
+		return x >= y;
+	}
+
+	if (equality && a.tag != SS_LIST && b.tag != SS_LIST) {
+		bool equal = a.tag == b.tag && a.as.b == b.as.b;
+		return equal == (op[0] == '=');
+	}
+
+	ss_invalid(op, a, b, pos);
+	return false;
+}
+
+/*
+ * The canonical display of a float: the shortest digits that parse back to
+ * v, in %e form when the exponent is below -4 or at least 16, and with a
+ * ".0" when written out in full without a fraction. out needs 32 bytes.
+ */
+static inline void ss_format_float(char *out, double v) {
+	if (isnan(v)) { strcpy(out, "NaN"); return; }
+	if (isinf(v)) { strcpy(out, v > 0 ? "+Inf" : "-Inf"); return; }
+
+	char buf[40];
+	for (int precision = 1; precision <= 17; precision++) {
+		snprintf(buf, sizeof buf, "%.*e", precision - 1, v);
+		if (strtod(buf, NULL) == v) break;
+	}
+
+	const char *p = buf;
+	char *o = out;
+	if (*p == '-') *o++ = *p++;
+
+	char digits[24];
+	int nd = 0;
+	for (; *p != 'e'; p++) {
+		if (*p != '.') digits[nd++] = *p;
+	}
+
+	int exp = atoi(p + 1);
+	while (nd > 1 && digits[nd - 1] == '0') nd--;
+
+	if (exp < -4 || exp >= 16) {
+		*o++ = digits[0];
+		if (nd > 1) {
+			*o++ = '.';
+			memcpy(o, digits + 1, nd - 1);
+			o += nd - 1;
+		}
+		sprintf(o, "e%c%02d", exp < 0 ? '-' : '+', exp < 0 ? -exp : exp);
+		return;
+	}
+
+	if (exp < 0) {
+		*o++ = '0';
+		*o++ = '.';
+		for (int i = 0; i < -exp - 1; i++) *o++ = '0';
+		memcpy(o, digits, nd);
+		o += nd;
+	} else if (nd <= exp + 1) {
+		memcpy(o, digits, nd);
+		o += nd;
+		for (int i = nd; i <= exp; i++) *o++ = '0';
+		*o++ = '.';
+		*o++ = '0';
+	} else {
+		memcpy(o, digits, exp + 1);
+		o += exp + 1;
+		*o++ = '.';
+		memcpy(o, digits + exp + 1, nd - exp - 1);
+		o += nd - exp - 1;
+	}
+
+	*o = '\0';
+}
+
+/* where display text goes: a growing string, or stdout when NULL */
+typedef struct {
+	char *ptr;
+	size_t len, cap;
+} ss_buf;
+
+static inline void ss_put(ss_buf *b, const char *p, size_t n) {
+	if (b == NULL) {
+		fwrite(p, 1, n, stdout);
+		return;
+	}
+
+	if (b->len + n + 1 > b->cap) {
+		b->cap = (b->len + n + 1) * 2;
+		b->ptr = realloc(b->ptr, b->cap);
+		if (b->ptr == NULL) ss_fail(NULL, "out of memory");
+	}
+
+	memcpy(b->ptr + b->len, p, n);
+	b->len += n;
+	b->ptr[b->len] = '\0';
+}
+
+static inline void ss_show_int(ss_buf *b, int64_t v) {
+	char buf[24];
+	ss_put(b, buf, (size_t)snprintf(buf, sizeof buf, "%lld", (long long)v));
+}
+
+static inline void ss_show_float(ss_buf *b, double v) {
+	char buf[32];
+	ss_format_float(buf, v);
+	ss_put(b, buf, strlen(buf));
+}
+
+static inline void ss_show_bool(ss_buf *b, bool v) { ss_put(b, v ? "true" : "false", v ? 4 : 5); }
+
+/* strings inside lists are quoted, escaping quotes, backslashes, \n, \r and \t */
+static inline void ss_show_quoted(ss_buf *b, ss_str v) {
+	ss_put(b, "\"", 1);
+	for (size_t i = 0; i < v.len; i++) {
+		switch (v.ptr[i]) {
+		case '"': ss_put(b, "\\\"", 2); break;
+		case '\\': ss_put(b, "\\\\", 2); break;
+		case '\n': ss_put(b, "\\n", 2); break;
+		case '\r': ss_put(b, "\\r", 2); break;
+		case '\t': ss_put(b, "\\t", 2); break;
+		default: ss_put(b, v.ptr + i, 1);
+		}
+	}
+	ss_put(b, "\"", 1);
+}
+
+static inline void ss_show_list(ss_buf *b, const ss_list *list);
+
+static inline void ss_show_value(ss_buf *b, ss_value v, bool quoted) {
+	switch (v.tag) {
+	case SS_INT: ss_show_int(b, v.as.i); break;
+	case SS_FLOAT: ss_show_float(b, v.as.f); break;
+	case SS_BOOL: ss_show_bool(b, v.as.b); break;
+	case SS_STR_TAG:
+		if (quoted) ss_show_quoted(b, v.as.s);
+		else ss_put(b, v.as.s.ptr, v.as.s.len);
+		break;
+	case SS_LIST: ss_show_list(b, v.as.l); break;
+	}
+}
+
+static inline void ss_show_list(ss_buf *b, const ss_list *list) {
+	ss_put(b, "[", 1);
+	for (size_t i = 0; i < list->len; i++) {
+		if (i > 0) ss_put(b, ", ", 2);
+		ss_show_value(b, list->items[i], true);
+	}
+	ss_put(b, "]", 1);
+}
+
+static inline void ss_write_int(int64_t v) { ss_show_int(NULL, v); }
+static inline void ss_write_float(double v) { ss_show_float(NULL, v); }
+static inline void ss_write_bool(bool v) { ss_show_bool(NULL, v); }
+static inline void ss_write_str(ss_str v) { ss_put(NULL, v.ptr, v.len); }
+static inline void ss_write_list(const ss_list *list) { ss_show_list(NULL, list); }
+static inline void ss_write_value(ss_value v) { ss_show_value(NULL, v, false); }
+static inline void ss_write_sep(void) { putchar(' '); }
+static inline void ss_write_end(void) { putchar('\n'); }
+
+/* the display text as a string, for format() */
+static inline ss_str ss_shown(ss_buf b) { return b.ptr == NULL ? SS_STR("") : (ss_str){ b.ptr, b.len }; }
+
+static inline ss_str ss_display_int(int64_t v) { ss_buf b = { NULL, 0, 0 }; ss_show_int(&b, v); return ss_shown(b); }
+static inline ss_str ss_display_float(double v) { ss_buf b = { NULL, 0, 0 }; ss_show_float(&b, v); return ss_shown(b); }
+static inline ss_str ss_display_bool(bool v) { return v ? SS_STR("true") : SS_STR("false"); }
+static inline ss_str ss_display_str(ss_str v) { return v; }
+static inline ss_str ss_display_list(const ss_list *list) { ss_buf b = { NULL, 0, 0 }; ss_show_list(&b, list); return ss_shown(b); }
+static inline ss_str ss_display_value(ss_value v) { ss_buf b = { NULL, 0, 0 }; ss_show_value(&b, v, false); return ss_shown(b); }
+
+#endif
@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// links a zero-based position in the generated file to one in the source
+type sourceMapping struct {
+	genLine, genCol int
+	srcLine, srcCol int
+}
+
+// encodes a version 3 source map for a single source file
+func encodeSourceMap(file, source string, mappings []sourceMapping) (string, error) {
+	var b strings.Builder
+
+	line := 0
+	prevGenCol, prevSrcLine, prevSrcCol := 0, 0, 0
+	first := true
+
+	for _, m := range mappings {
+		for line < m.genLine {
+			b.WriteByte(';')
+			line++
+			prevGenCol = 0
+			first = true
+		}
+
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+
+		// fields: generated column, source index, source line, source column
+		writeVLQ(&b, m.genCol-prevGenCol)
+		writeVLQ(&b, 0)
+		writeVLQ(&b, m.srcLine-prevSrcLine)
+		writeVLQ(&b, m.srcCol-prevSrcCol)
+
+		prevGenCol, prevSrcLine, prevSrcCol = m.genCol, m.srcLine, m.srcCol
+	}
+
+	data, err := json.Marshal(struct {
+		Version int `json:"version"`
+		File string `json:"file"`
+		Sources []string `json:"sources"`
+		Names []string `json:"names"`
+		Mappings string `json:"mappings"`
+	}{3, file, []string{source}, []string{}, b.String()})
+
+	return string(data), err
+}
+
+const base64Digits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// base64 VLQ with the sign in the lowest bit
+func writeVLQ(b *strings.Builder, value int) {
+	v := value << 1
+	if value < 0 {
+		v = (-value << 1) | 1
+	}
+
+	for {
+		digit := v & 0x1F
+		v >>= 5
+
+		if v > 0 {
+			digit |= 0x20
+		}
+
+		b.WriteByte(base64Digits[digit])
+
+		if v == 0 {
+			return
+		}
+	}
+}
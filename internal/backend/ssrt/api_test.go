@@ -0,0 +1,103 @@
+package ssrt
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// the exported functions of the runtime released as apiVersion; generated
+// programs call them, so changing any of them needs a new Version
+const apiVersion = "v1.4.0"
+
+const api = `
+func Add(a, b int, pos string) int
+func Alloc(size uint32) uint32
+func Args() []interface{}
+func BeginCall()
+func Binary(op string, a, b interface{}, pos string) interface{}
+func Bool(v interface{}, pos string) bool
+func Compare(op string, a, b interface{}, pos string) bool
+func Display(v interface{}) string
+func Div(a, b int, pos string) int
+func EndCall()
+func Env(name string) string
+func ExportBool(v bool) int32
+func ExportList(list []interface{}) uint32
+func ExportStr(s string) uint64
+func Fail(pos string, message string)
+func Float(v interface{}, pos string) float64
+func FormatFloat(f float64) string
+func ImportBool(v int32) bool
+func ImportList(addr uint32) []interface{}
+func ImportStr(packed uint64) string
+func Index(list []interface{}, index int, pos string) int
+func Int(v interface{}, pos string) int
+func List(v interface{}, pos string) []interface{}
+func Mul(a, b int, pos string) int
+func Neg(a int, pos string) int
+func Negate(a interface{}, pos string) interface{}
+func Recover(file string, columns map[int]int)
+func Say(args ...interface{})
+func SayWith(sep, end string, args ...interface{})
+func Str(v interface{}, pos string) string
+func Sub(a, b int, pos string) int
+func TypeName(v interface{}) string
+`
+
+// the signatures of the exported functions in every source of the package,
+// whatever their build tags
+func exportedSignatures(t *testing.T) string {
+	t.Helper()
+
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fset := token.NewFileSet()
+	signatures := []string{}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".go") || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, entry.Name(), nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || !fn.Name.IsExported() {
+				continue
+			}
+
+			var b bytes.Buffer
+			printer.Fprint(&b, fset, &ast.FuncDecl{Name: fn.Name, Type: fn.Type})
+			if !slices.Contains(signatures, b.String()) {
+				signatures = append(signatures, b.String())
+			}
+		}
+	}
+
+	slices.Sort(signatures)
+	return "\n" + strings.Join(signatures, "\n") + "\n"
+}
+
+func TestVersionCoversTheAPI(t *testing.T) {
+	if Version != apiVersion {
+		t.Fatalf("Version is %s but the recorded API is %s's: record the API of the new version", Version, apiVersion)
+	}
+
+	if got := exportedSignatures(t); got != api {
+		t.Errorf("the exported functions changed since %s: bump Version and record them\ngot:%s", Version, got)
+	}
+}
@@ -0,0 +1,61 @@
+package ssrt
+
+// The conversions of a value only known at runtime, like a list element,
+// to the static type the program uses it as. A value of another type fails
+// with the SimpleScript type names instead of a Go interface conversion.
+
+func Int(v interface{}, pos string) int {
+	n, ok := v.(int)
+	if !ok {
+		mismatch(v, "int", pos)
+	}
+	return n
+}
+
+func Float(v interface{}, pos string) float64 {
+	f, ok := v.(float64)
+	if !ok {
+		mismatch(v, "float", pos)
+	}
+	return f
+}
+
+func Str(v interface{}, pos string) string {
+	s, ok := v.(string)
+	if !ok {
+		mismatch(v, "str", pos)
+	}
+	return s
+}
+
+func Bool(v interface{}, pos string) bool {
+	b, ok := v.(bool)
+	if !ok {
+		mismatch(v, "bool", pos)
+	}
+	return b
+}
+
+func List(v interface{}, pos string) []interface{} {
+	l, ok := v.([]interface{})
+	if !ok {
+		mismatch(v, "list", pos)
+	}
+	return l
+}
+
+// the SimpleScript name of a runtime value's type
+func TypeName(v interface{}) string {
+	switch v.(type) {
+	case int: return "int"
+	case float64: return "float"
+	case string: return "str"
+	case bool: return "bool"
+	case []interface{}: return "list"
+	default: return "unknown"
+	}
+}
+
+func mismatch(v interface{}, want, pos string) {
+	Fail(pos, "type mismatch: cannot use "+TypeName(v)+" as "+want)
+}
@@ -0,0 +1,19 @@
+package ssrt
+
+import "os"
+
+// Args returns the command-line arguments of the program, without its name
+func Args() []interface{} {
+	args := []interface{}{}
+	if len(os.Args) > 1 {
+		for _, arg := range os.Args[1:] {
+			args = append(args, arg)
+		}
+	}
+	return args
+}
+
+// Env returns the environment variable name, or "" when it is not set
+func Env(name string) string {
+	return os.Getenv(name)
+}
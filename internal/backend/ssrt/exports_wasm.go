@@ -0,0 +1,147 @@
+//go:build wasm
+
+package ssrt
+
+import (
+	"encoding/binary"
+	"math"
+	"unsafe"
+)
+
+// The wrappers generated for exported functions pass values the way
+// modules from the native wasm backend do, so one loader serves both: an
+// int or float as itself, a bool as an i32, a str as its address and
+// length packed into an i64 as address<<32 | length, and a list as the
+// address of its length, followed 8 bytes in by one 16 byte cell per
+// element holding a type tag and, 8 bytes into the cell, the element.
+const (
+	listHeader = 8
+	cellSize = 16
+	cellPayload = 8
+)
+
+const (
+	tagInt = iota
+	tagFloat
+	tagBool
+	tagStr
+	tagList
+)
+
+// the buffers shared with the host: the arguments it wrote for the call in
+// progress, and the results of the last call, which it reads after the
+// call returns
+var arguments, results [][]byte
+
+// Reserves size bytes for the host to write an argument to; they are
+// released once the call using them returns
+func Alloc(size uint32) uint32 {
+	buf := make([]byte, size)
+	arguments = append(arguments, buf)
+	return address(buf)
+}
+
+// Starts a call of an exported function, releasing the previous results
+func BeginCall() {
+	results = nil
+}
+
+// Ends the call: the arguments have been copied into Go values, and the
+// output is flushed so the host has all of it when the call returns
+func EndCall() {
+	arguments = nil
+	flush()
+}
+
+func ImportBool(v int32) bool {
+	return v != 0
+}
+
+func ExportBool(v bool) int32 {
+	if v {
+		return 1
+	}
+
+	return 0
+}
+
+func ImportStr(packed uint64) string {
+	return string(memory(uint32(packed>>32), uint32(packed)))
+}
+
+func ExportStr(s string) uint64 {
+	buf := []byte(s)
+	results = append(results, buf)
+	return uint64(address(buf))<<32 | uint64(len(s))
+}
+
+func ImportList(addr uint32) []interface{} {
+	n := binary.LittleEndian.Uint32(memory(addr, 4))
+	cells := memory(addr+listHeader, n*cellSize)
+	list := make([]interface{}, n)
+
+	for i := range list {
+		cell := cells[i*cellSize:]
+		payload := cell[cellPayload:]
+
+		switch binary.LittleEndian.Uint32(cell) {
+		case tagInt: list[i] = int(int64(binary.LittleEndian.Uint64(payload)))
+		case tagFloat: list[i] = math.Float64frombits(binary.LittleEndian.Uint64(payload))
+		case tagBool: list[i] = binary.LittleEndian.Uint32(payload) != 0
+		case tagStr: list[i] = ImportStr(binary.LittleEndian.Uint64(payload))
+		case tagList: list[i] = ImportList(binary.LittleEndian.Uint32(payload))
+		}
+	}
+
+	return list
+}
+
+func ExportList(list []interface{}) uint32 {
+	buf := make([]byte, listHeader+cellSize*len(list))
+	binary.LittleEndian.PutUint32(buf, uint32(len(list)))
+
+	for i, v := range list {
+		cell := buf[listHeader+i*cellSize:]
+		payload := cell[cellPayload:]
+
+		switch v := v.(type) {
+		case int:
+			binary.LittleEndian.PutUint32(cell, tagInt)
+			binary.LittleEndian.PutUint64(payload, uint64(v))
+		case float64:
+			binary.LittleEndian.PutUint32(cell, tagFloat)
+			binary.LittleEndian.PutUint64(payload, math.Float64bits(v))
+		case bool:
+			binary.LittleEndian.PutUint32(cell, tagBool)
+			binary.LittleEndian.PutUint32(payload, uint32(ExportBool(v)))
+		case string:
+			binary.LittleEndian.PutUint32(cell, tagStr)
+			binary.LittleEndian.PutUint64(payload, ExportStr(v))
+		case []interface{}:
+			binary.LittleEndian.PutUint32(cell, tagList)
+			binary.LittleEndian.PutUint32(payload, ExportList(v))
+		}
+	}
+
+	results = append(results, buf)
+	return address(buf)
+}
+
+// the address of the buffer in linear memory; 0 for an empty one, which
+// the host never reads from
+func address(buf []byte) uint32 {
+	if len(buf) == 0 {
+		return 0
+	}
+
+	return uint32(uintptr(unsafe.Pointer(&buf[0])))
+}
+
+// the size bytes at addr in linear memory, where pointers are addresses
+func memory(addr, size uint32) []byte {
+	if size == 0 {
+		return nil
+	}
+
+	return unsafe.Slice((*byte)(unsafe.Add(nil, addr)), size)
+}
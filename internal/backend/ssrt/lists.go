@@ -0,0 +1,11 @@
+package ssrt
+
+import "strconv"
+
+// checks that index is within the bounds of list and returns it
+func Index(list []interface{}, index int, pos string) int {
+	if index < 0 || index >= len(list) {
+		Fail(pos, "index "+strconv.Itoa(index)+" out of range for list of length "+strconv.Itoa(len(list)))
+	}
+	return index
+}
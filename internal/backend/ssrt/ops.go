@@ -0,0 +1,132 @@
+package ssrt
+
+import "math"
+
+// The checked int operators. Each fails instead of wrapping around or
+// panicking, at the position of the expression as the last argument.
+
+func Add(a, b int, pos string) int {
+	c := a + b
+	if (b > 0 && c < a) || (b < 0 && c > a) {
+		Fail(pos, "integer overflow")
+	}
+	return c
+}
+
+func Sub(a, b int, pos string) int {
+	c := a - b
+	if (b > 0 && c > a) || (b < 0 && c < a) {
+		Fail(pos, "integer overflow")
+	}
+	return c
+}
+
+// a product overflowed when dividing it back does not give the operand;
+// -1 * MinInt wraps to MinInt, which that test cannot tell
+func Mul(a, b int, pos string) int {
+	c := a * b
+	if a != 0 && (c/a != b || (a == -1 && b == math.MinInt)) {
+		Fail(pos, "integer overflow")
+	}
+	return c
+}
+
+func Div(a, b int, pos string) int {
+	if b == 0 {
+		Fail(pos, "integer divide by zero")
+	}
+	if a == math.MinInt && b == -1 {
+		Fail(pos, "integer overflow")
+	}
+	return a / b
+}
+
+func Neg(a int, pos string) int {
+	if a == math.MinInt {
+		Fail(pos, "integer overflow")
+	}
+	return -a
+}
+
+// Applies an arithmetic operator to operands only known at runtime, like
+// list elements: ints, floats, and strings for "+".
+func Binary(op string, a, b interface{}, pos string) interface{} {
+	switch a := a.(type) {
+	case int:
+		if b, ok := b.(int); ok {
+			switch op {
+			case "+": return Add(a, b, pos)
+			case "-": return Sub(a, b, pos)
+			case "*": return Mul(a, b, pos)
+			case "/": return Div(a, b, pos)
+			}
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch op {
+			case "+": return a + b
+			case "-": return a - b
+			case "*": return a * b
+			case "/": return a / b
+			}
+		}
+	case string:
+		if b, ok := b.(string); ok && op == "+" {
+			return a + b
+		}
+	}
+
+	Fail(pos, "invalid operation '"+TypeName(a)+" "+op+" "+TypeName(b)+"'")
+	return nil
+}
+
+// Applies a comparison operator to operands only known at runtime. Values
+// of different types are never equal; lists cannot be compared at all.
+func Compare(op string, a, b interface{}, pos string) bool {
+	switch a := a.(type) {
+	case int:
+		if b, ok := b.(int); ok {
+			return compare(op, a, b)
+		}
+	case float64:
+		if b, ok := b.(float64); ok {
+			return compare(op, a, b)
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return compare(op, a, b)
+		}
+	}
+
+	_, aIsList := a.([]interface{})
+	_, bIsList := b.([]interface{})
+
+	if (op == "==" || op == "!=") && !aIsList && !bIsList {
+		return (a == b) == (op == "==")
+	}
+
+	Fail(pos, "invalid operation '"+TypeName(a)+" "+op+" "+TypeName(b)+"'")
+	return false
+}
+
+func Negate(a interface{}, pos string) interface{} {
+	switch a := a.(type) {
+	case int: return Neg(a, pos)
+	case float64: return -a
+	}
+
+	Fail(pos, "invalid operation: cannot use '-' on "+TypeName(a))
+	return nil
+}
+
+func compare[T int | float64 | string](op string, a, b T) bool {
+	switch op {
+	case "==": return a == b
+	case "!=": return a != b
+	case "<": return a < b
+	case "<=": return a <= b
+	case ">": return a > b
+	}
+
+	return a >= b
+}
@@ -0,0 +1,17 @@
+//go:build !wasm || wasip1
+
+package ssrt
+
+import "os"
+
+// native programs and WASI modules write straight to the standard streams
+func write(text string) {
+	os.Stdout.WriteString(text)
+}
+
+func flush() {}
+
+func fail(message string) {
+	os.Stderr.WriteString(message + "\n")
+	os.Exit(1)
+}
@@ -0,0 +1,54 @@
+//go:build wasm && !wasip1
+
+package ssrt
+
+import "unsafe"
+
+// The print function of the simplescript world in simplescript.wit, which
+// receives one line of output, without its newline, as UTF-8 bytes in the
+// module's memory. Native programs and modules from the native wasm backend
+// print the same lines.
+//
+//go:wasmimport $root print
+func hostPrint(ptr unsafe.Pointer, length uint32)
+
+// output after the last newline, handed to the host once the line ends
+var pending []byte
+
+func write(text string) {
+	pending = append(pending, text...)
+
+	start := 0
+	for i, c := range pending {
+		if c == '\n' {
+			printLine(pending[start:i])
+			start = i + 1
+		}
+	}
+
+	pending = pending[:copy(pending, pending[start:])]
+}
+
+func flush() {
+	if len(pending) > 0 {
+		printLine(pending)
+		pending = pending[:0]
+	}
+}
+
+func printLine(line []byte) {
+	if len(line) == 0 {
+		hostPrint(nil, 0)
+		return
+	}
+
+	hostPrint(unsafe.Pointer(&line[0]), uint32(len(line)))
+}
+
+// there is no stderr or exit status to report to, so the error is printed
+// like any other line before the module traps
+func fail(message string) {
+	flush()
+	write(message + "\n")
+	panic(message)
+}
@@ -0,0 +1,96 @@
+package ssrt
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// prints its arguments separated by spaces and a newline
+func Say(args ...interface{}) {
+	SayWith(" ", "\n", args...)
+}
+
+// prints its arguments separated by sep and followed by end
+func SayWith(sep, end string, args ...interface{}) {
+	texts := make([]string, len(args))
+	for i, arg := range args {
+		texts[i] = Display(arg)
+	}
+
+	write(strings.Join(texts, sep) + end)
+}
+
+// The canonical text of a value, what say prints and format inserts. It
+// matches value.Display in the compiler, which the conformance tests check
+// every backend against. Only the digits of numbers come from strconv, so
+// printing pulls in neither fmt nor reflect.
+func Display(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	var b strings.Builder
+	writeRepr(&b, v)
+	return b.String()
+}
+
+func writeRepr(b *strings.Builder, v interface{}) {
+	switch v := v.(type) {
+	case int: b.WriteString(strconv.Itoa(v))
+	case float64: b.WriteString(FormatFloat(v))
+	case bool: b.WriteString(strconv.FormatBool(v))
+	case string: b.WriteString(quote(v))
+	case []interface{}:
+		b.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeRepr(b, element)
+		}
+		b.WriteByte(']')
+	}
+}
+
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\': b.WriteByte('\\'); b.WriteByte(c)
+		case '\n': b.WriteString(`\n`)
+		case '\r': b.WriteString(`\r`)
+		case '\t': b.WriteString(`\t`)
+		default: b.WriteByte(c)
+		}
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
+
+// the shortest digits that parse back to f, written out in full for
+// exponents from -4 to 15 and with a ".0" when they have no fraction
+func FormatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f): return "NaN"
+	case math.IsInf(f, 1): return "+Inf"
+	case math.IsInf(f, -1): return "-Inf"
+	}
+
+	exponent := strconv.FormatFloat(f, 'e', -1, 64)
+	exp, _ := strconv.Atoi(exponent[strings.IndexByte(exponent, 'e')+1:])
+
+	if exp < -4 || exp >= 16 {
+		return exponent
+	}
+
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+
+	return s
+}
@@ -0,0 +1,62 @@
+// Package ssrt is the runtime library imported by the Go programs the
+// SimpleScript compiler generates. Its sources are embedded into the
+// compiler and written next to every generated program as a module of
+// their own, so builds work offline with both Go and TinyGo; it may only
+// depend on the standard library.
+package ssrt
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// the module version generated programs require; bump it whenever an
+// exported function changes, since a program only builds against the
+// runtime it was generated for
+const Version = "v1.4.0"
+
+// Reports a runtime error at pos, a "file.ss:line:col" position, and stops
+// the program. It writes the message itself instead of panicking, since
+// TinyGo builds trap on panics without printing them. Messages are built
+// by concatenation: the runtime never imports fmt, which would dominate
+// the size of wasm builds.
+func Fail(pos string, message string) {
+	fail("RuntimeError: " + message + " at " + pos)
+}
+
+// Deferred by main to report a Go runtime panic the checks did not catch,
+// like one in a program built with --unchecked, and to flush output still
+// pending at the end of the program. The generated code carries
+// //line directives, but the runtime only knows the line of the failing
+// frame, so columns holds the column to report for each line of file.
+func Recover(file string, columns map[int]int) {
+	flush()
+
+	r := recover()
+	err, ok := r.(runtime.Error)
+	if !ok {
+		if r != nil {
+			panic(r)
+		}
+		return
+	}
+
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(0, pcs)])
+	line := 0
+	panicked := false
+	for {
+		frame, more := frames.Next()
+		// the failing frame is the first of the generated code below the
+		// panic, which is main's or a function's
+		if panicked && strings.HasPrefix(frame.Function, "main.") || !more {
+			line = frame.Line
+			break
+		}
+		panicked = panicked || frame.Function == "runtime.gopanic"
+	}
+
+	message := strings.TrimPrefix(err.Error(), "runtime error: ")
+	Fail(file+":"+strconv.Itoa(line)+":"+strconv.Itoa(columns[line]), message)
+}
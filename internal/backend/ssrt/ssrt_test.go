@@ -0,0 +1,82 @@
+package ssrt
+
+import (
+	"math"
+	"testing"
+)
+
+func TestIntOperators(t *testing.T) {
+	tests := []struct {
+		got, expected int
+	}{
+		{Add(2, 3, ""), 5},
+		{Add(math.MaxInt, -1, ""), math.MaxInt - 1},
+		{Sub(math.MinInt, -1, ""), math.MinInt + 1},
+		{Mul(-1, math.MaxInt, ""), -math.MaxInt},
+		{Mul(0, math.MinInt, ""), 0},
+		{Div(-7, 2, ""), -3},
+		{Neg(math.MaxInt, ""), -math.MaxInt},
+	}
+
+	for i, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%d: expected %d, got %d", i, tt.expected, tt.got)
+		}
+	}
+}
+
+func TestDynamicOperators(t *testing.T) {
+	tests := []struct {
+		got, expected interface{}
+	}{
+		{Binary("+", 1, 2, ""), 3},
+		{Binary("/", 1.0, 4.0, ""), 0.25},
+		{Binary("+", "a", "b", ""), "ab"},
+		{Negate(2.5, ""), -2.5},
+		{Compare("<", "a", "b", ""), true},
+		{Compare("==", 1, 1.0, ""), false},
+		{Compare("!=", "a", true, ""), true},
+		{Compare(">=", 2.0, 2.0, ""), true},
+	}
+
+	for i, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%d: expected %v, got %v", i, tt.expected, tt.got)
+		}
+	}
+}
+
+func TestConversions(t *testing.T) {
+	var element interface{} = []interface{}{1, "a"}
+
+	list := List(element, "")
+	if len(list) != 2 || Int(list[0], "") != 1 || Str(list[1], "") != "a" {
+		t.Errorf("unexpected conversion of %v", element)
+	}
+
+	if name := TypeName(list); name != "list" {
+		t.Errorf("expected list, got %s", name)
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		expected string
+	}{
+		{"plain", "plain"},
+		{2.0, "2.0"},
+		{1e6, "1000000.0"},
+		{1e16, "1e+16"},
+		{0.0001, "0.0001"},
+		{0.00001, "1e-05"},
+		{math.Inf(-1), "-Inf"},
+		{[]interface{}{1, "a\"b", 2.5, []interface{}{true}}, `[1, "a\"b", 2.5, [true]]`},
+	}
+
+	for _, tt := range tests {
+		if got := Display(tt.value); got != tt.expected {
+			t.Errorf("Display(%#v): expected %q, got %q", tt.value, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package wasm
+
+import "bytes"
+
+// instruction opcodes used by the generator and the runtime helpers
+const (
+	opUnreachable byte = 0x00
+	opBlock byte = 0x02
+	opLoop byte = 0x03
+	opIf byte = 0x04
+	opElse byte = 0x05
+	opEnd byte = 0x0B
+	opBr byte = 0x0C
+	opBrIf byte = 0x0D
+	opBrTable byte = 0x0E
+	opReturn byte = 0x0F
+	opCall byte = 0x10
+	opSelect byte = 0x1B
+
+	opLocalGet byte = 0x20
+	opLocalSet byte = 0x21
+	opLocalTee byte = 0x22
+	opGlobalGet byte = 0x23
+	opGlobalSet byte = 0x24
+
+	opI32Load byte = 0x28
+	opI64Load byte = 0x29
+	opF64Load byte = 0x2B
+	opI32Load8U byte = 0x2D
+	opI32Store byte = 0x36
+	opI64Store byte = 0x37
+	opF64Store byte = 0x39
+	opI32Store8 byte = 0x3A
+	opMemorySize byte = 0x3F
+	opMemoryGrow byte = 0x40
+
+	opI32Const byte = 0x41
+	opI64Const byte = 0x42
+	opF64Const byte = 0x44
+
+	opI32Eqz byte = 0x45
+	opI32Eq byte = 0x46
+	opI32Ne byte = 0x47
+	opI32LtS byte = 0x48
+	opI32LtU byte = 0x49
+	opI32GtS byte = 0x4A
+	opI32LeS byte = 0x4C
+	opI32LeU byte = 0x4D
+	opI32GeS byte = 0x4E
+	opI32GeU byte = 0x4F
+
+	opI64Eqz byte = 0x50
+	opI64Eq byte = 0x51
+	opI64Ne byte = 0x52
+	opI64LtS byte = 0x53
+	opI64GtS byte = 0x55
+	opI64LeS byte = 0x57
+	opI64GeS byte = 0x59
+
+	opF64Eq byte = 0x61
+	opF64Ne byte = 0x62
+	opF64Lt byte = 0x63
+	opF64Gt byte = 0x64
+	opF64Le byte = 0x65
+	opF64Ge byte = 0x66
+
+	opI32Add byte = 0x6A
+	opI32Sub byte = 0x6B
+	opI32Mul byte = 0x6C
+	opI32And byte = 0x71
+	opI32Or byte = 0x72
+	opI32Shl byte = 0x74
+	opI32ShrU byte = 0x76
+
+	opI64Add byte = 0x7C
+	opI64Sub byte = 0x7D
+	opI64Mul byte = 0x7E
+	opI64DivS byte = 0x7F
+	opI64DivU byte = 0x80
+	opI64RemU byte = 0x82
+	opI64And byte = 0x83
+	opI64Or byte = 0x84
+	opI64Xor byte = 0x85
+	opI64Shl byte = 0x86
+	opI64ShrU byte = 0x88
+
+	opF64Abs byte = 0x99
+	opF64Neg byte = 0x9A
+	opF64Trunc byte = 0x9D
+	opF64Nearest byte = 0x9E
+	opF64Add byte = 0xA0
+	opF64Sub byte = 0xA1
+	opF64Mul byte = 0xA2
+	opF64Div byte = 0xA3
+
+	opI32WrapI64 byte = 0xA7
+	opI64ExtendI32U byte = 0xAD
+	opI64TruncF64S byte = 0xB0
+	opI64TruncF64U byte = 0xB1
+	opF64ConvertI64S byte = 0xB9
+	opF64ConvertI64U byte = 0xBA
+	opI64ReinterpretF64 byte = 0xBD
+	opF64ReinterpretI64 byte = 0xBF
+)
+
+// a function body under construction
+type function struct {
+	typeIndex uint32
+	params uint32
+	locals []ValType
+	code bytes.Buffer
+}
+
+// declares a new local and returns its index
+func (f *function) local(t ValType) uint32 {
+	f.locals = append(f.locals, t)
+	return f.params + uint32(len(f.locals)-1)
+}
+
+func (f *function) op(ops ...byte) {
+	f.code.Write(ops)
+}
+
+// emits an instruction with a single unsigned immediate
+func (f *function) opU32(op byte, immediate uint32) {
+	f.code.WriteByte(op)
+	writeU32(&f.code, immediate)
+}
+
+// emits a load or store with its alignment hint and static offset
+func (f *function) memory(op byte, offset uint32) {
+	align := uint32(3)
+	switch op {
+	case opI32Load, opI32Store:
+		align = 2
+	case opI32Load8U, opI32Store8:
+		align = 0
+	}
+
+	f.code.WriteByte(op)
+	writeU32(&f.code, align)
+	writeU32(&f.code, offset)
+}
+
+// branches to the label picked by the i32 on the stack, or to fallback when it is out of range
+func (f *function) brTable(labels []uint32, fallback uint32) {
+	f.code.WriteByte(opBrTable)
+	writeU32(&f.code, uint32(len(labels)))
+	for _, label := range labels {
+		writeU32(&f.code, label)
+	}
+	writeU32(&f.code, fallback)
+}
+
+func (f *function) i32Const(v int32) {
+	f.code.WriteByte(opI32Const)
+	writeS64(&f.code, int64(v))
+}
+
+func (f *function) i64Const(v int64) {
+	f.code.WriteByte(opI64Const)
+	writeS64(&f.code, v)
+}
+
+func (f *function) f64Const(v float64) {
+	f.code.WriteByte(opF64Const)
+	writeF64(&f.code, v)
+}
+
+func (f *function) get(local uint32) { f.opU32(opLocalGet, local) }
+func (f *function) set(local uint32) { f.opU32(opLocalSet, local) }
+func (f *function) call(index uint32) { f.opU32(opCall, index) }
+
+// locals are run-length encoded by type
+func (f *function) encode() []byte {
+	var b bytes.Buffer
+
+	type run struct {
+		count uint32
+		t ValType
+	}
+
+	runs := []run{}
+	for _, t := range f.locals {
+		if len(runs) > 0 && runs[len(runs)-1].t == t {
+			runs[len(runs)-1].count++
+			continue
+		}
+
+		runs = append(runs, run{1, t})
+	}
+
+	writeU32(&b, uint32(len(runs)))
+	for _, r := range runs {
+		writeU32(&b, r.count)
+		b.WriteByte(byte(r.t))
+	}
+
+	b.Write(f.code.Bytes())
+	b.WriteByte(opEnd)
+	return b.Bytes()
+}
@@ -0,0 +1,643 @@
+// Package wasm lowers a program in IR form straight to a WebAssembly module
+// that implements the simplescript world: it imports `print` with the
+// canonical ABI lowering of a string (address and length in linear memory)
+// and exports `run` and its memory. Exported functions are exported under
+// their own name, with `alloc` for the host to place their arguments.
+package wasm
+
+import (
+	"fmt"
+
+	"simplescript/internal/ir"
+	"simplescript/internal/types"
+)
+
+// module name the component tooling uses for imports of the world itself
+const importModule = "$root"
+
+type generator struct {
+	mod *module
+	code *function // the function being generated
+	print uint32
+	helpers map[string]uint32
+	strings map[string]int64
+	pow10Addr uint32
+	pow10NegAddr uint32
+	operandAddr uint32
+
+	heap, outPtr, outLen, outCap uint32
+	// low half of the last double-double result
+	low uint32
+
+	prog *ir.Program
+	funcs map[*ir.Func]uint32 // the wasm function of each IR function
+	fn *ir.Func // the IR function being generated, nil for the script
+	locals []uint32 // the wasm local of each IR local
+	pc uint32 // index of the IR block to run next
+	scratch uint32
+	// inside the code of a block, the number of labels between it and the dispatch loop
+	depth uint32
+}
+
+// lowers the program into a binary WebAssembly module
+func Generate(prog *ir.Program) ([]byte, error) {
+	g := &generator{
+		mod: &module{},
+		helpers: map[string]uint32{},
+		strings: map[string]int64{},
+		prog: prog,
+	}
+
+	g.print = g.mod.importFunc(importModule, "print", []ValType{I32, I32}, nil)
+	g.layoutMemory()
+
+	g.heap = g.mod.global(I32, 0)
+	g.outPtr = g.mod.global(I32, 0)
+	g.outLen = g.mod.global(I32, 0)
+	g.outCap = g.mod.global(I32, 0)
+	g.low = g.mod.global(F64, 0)
+
+	run, runIndex := g.mod.newFunction(nil, nil)
+	g.mod.exports = append(g.mod.exports,
+		export{name: "run", kind: exportFunc, index: runIndex},
+		export{name: "memory", kind: exportMemory, index: 0},
+	)
+
+	// every function is declared first, so calls can refer to later ones
+	g.funcs = map[*ir.Func]uint32{}
+	codes := []*function{}
+	for _, fn := range prog.Funcs {
+		params, results, err := signature(fn)
+		if err != nil {
+			return nil, fmt.Errorf("function %s: %v", fn.Name, err)
+		}
+
+		f, index := g.mod.newFunction(params, results)
+		g.funcs[fn] = index
+		codes = append(codes, f)
+	}
+
+	if err := g.body(run, &prog.Body, nil); err != nil {
+		return nil, err
+	}
+
+	for n, fn := range prog.Funcs {
+		if err := g.body(codes[n], &fn.Body, fn); err != nil {
+			return nil, fmt.Errorf("function %s: %v", fn.Name, err)
+		}
+
+		if fn.Exported {
+			g.export(fn)
+		}
+	}
+
+	// the heap starts after every interned string
+	g.mod.globals[g.heap].initial = int32(g.mod.dataEnd())
+
+	return g.mod.encode(), nil
+}
+
+// the type an operand has at runtime
+func typeOf(v ir.Value) types.Type { return types.Erase(v.ValueType()) }
+
+// list elements are held in a private cell, referenced by its address
+func valType(dataType types.Type) (ValType, error) {
+	switch types.Erase(dataType) {
+	case types.Int, types.Str: return I64, nil
+	case types.Float: return F64, nil
+	case types.Bool, types.AnyList, types.Unknown: return I32, nil
+	}
+
+	return 0, fmt.Errorf("unsupported type '%s'", dataType)
+}
+
+// the wasm parameters and results of a function, which are also how the
+// host passes and receives the values of an exported one
+func signature(fn *ir.Func) ([]ValType, []ValType, error) {
+	params := []ValType{}
+	for _, param := range fn.Params {
+		t, err := valType(param.Type)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		params = append(params, t)
+	}
+
+	if fn.Result == nil {
+		return params, nil, nil
+	}
+
+	result, err := valType(fn.Result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return params, []ValType{result}, nil
+}
+
+// Exports a wrapper that calls fn and then flushes the text it printed
+// without a final newline, like the end of run does. The host allocates
+// strings and lists for its arguments through the exported allocator.
+func (g *generator) export(fn *ir.Func) {
+	params, results, _ := signature(fn)
+	f, index := g.mod.newFunction(params, results)
+
+	for n := range params {
+		f.get(uint32(n))
+	}
+	f.call(g.funcs[fn])
+
+	f.opU32(opGlobalGet, g.outLen)
+	f.op(opIf, blockEmpty)
+	f.call(g.helper("flush"))
+	f.op(opEnd)
+
+	if !g.exported("alloc") {
+		g.mod.exports = append(g.mod.exports, export{name: "alloc", kind: exportFunc, index: g.helper("alloc")})
+	}
+
+	g.mod.exports = append(g.mod.exports, export{name: fn.Name, kind: exportFunc, index: index})
+}
+
+func (g *generator) exported(name string) bool {
+	for _, e := range g.mod.exports {
+		if e.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Wasm only has structured control flow, so the blocks are laid out in a
+// dispatch loop: a br_table on the next block's index jumps into the
+// block's code, and a jump stores the target in pc and restarts the loop.
+// Jumps to the block laid out next simply fall through. The parameters of
+// a function are its first locals, in both the IR and wasm.
+func (g *generator) body(f *function, body *ir.Body, fn *ir.Func) error {
+	g.code, g.fn = f, fn
+	g.locals = nil
+
+	for _, local := range body.Locals {
+		if fn != nil && len(g.locals) < len(fn.Params) {
+			g.locals = append(g.locals, uint32(len(g.locals)))
+			continue
+		}
+
+		t, err := valType(local.Type)
+		if err != nil {
+			return err
+		}
+
+		g.locals = append(g.locals, f.local(t))
+	}
+
+	g.pc = f.local(I32)
+	g.scratch = f.local(I32)
+
+	// every list element local owns a cell, so copies never alias a list
+	for _, local := range body.Locals {
+		if types.IsUnknown(local.Type) {
+			f.i32Const(cellSize)
+			f.call(g.helper("alloc"))
+			f.set(g.locals[local.ID])
+		}
+	}
+
+	n := uint32(len(body.Blocks))
+
+	f.op(opLoop, blockEmpty)
+	for range body.Blocks {
+		f.op(opBlock, blockEmpty)
+	}
+
+	f.get(g.pc)
+	targets := []uint32{}
+	for i := uint32(0); i < n; i++ {
+		targets = append(targets, i)
+	}
+	f.brTable(targets, n-1)
+
+	for _, block := range body.Blocks {
+		f.op(opEnd)
+		g.depth = n - 1 - uint32(block.Index)
+
+		if err := g.block(block); err != nil {
+			return err
+		}
+	}
+
+	f.op(opEnd)
+
+	// every path returns inside the loop, but a function with a result
+	// still needs one on the stack where its code ends
+	if fn != nil && fn.Result != nil {
+		f.op(opUnreachable)
+	}
+
+	return nil
+}
+
+func (g *generator) block(block *ir.Block) error {
+	f := g.code
+
+	for _, instr := range block.Instrs {
+		if err := g.instr(instr); err != nil {
+			return err
+		}
+	}
+
+	next := block.Index + 1
+
+	switch t := block.Term.(type) {
+	case *ir.Jump:
+		if t.Target.Index != next {
+			g.jump(t.Target, 0)
+		}
+
+	case *ir.Branch:
+		if condType := typeOf(t.Cond); condType != types.Bool && condType != types.Unknown {
+			return fmt.Errorf("non-boolean condition in the native backend")
+		}
+
+		g.pushAs(t.Cond, types.Bool)
+
+		switch {
+		case t.Else.Index == next:
+			f.op(opIf, blockEmpty)
+			g.jump(t.Then, 1)
+			f.op(opEnd)
+		case t.Then.Index == next:
+			f.op(opI32Eqz)
+			f.op(opIf, blockEmpty)
+			g.jump(t.Else, 1)
+			f.op(opEnd)
+		default:
+			f.op(opIf, blockEmpty)
+			g.jump(t.Then, 1)
+			f.op(opElse)
+			g.jump(t.Else, 1)
+			f.op(opEnd)
+		}
+
+	case *ir.Return:
+		if g.fn != nil {
+			if t.Value != nil {
+				g.pushAs(t.Value, g.fn.Result)
+			}
+			f.op(opReturn)
+			break
+		}
+
+		// text printed without a final newline is still pending
+		f.opU32(opGlobalGet, g.outLen)
+		f.op(opIf, blockEmpty)
+		f.call(g.helper("flush"))
+		f.op(opEnd)
+		f.op(opReturn)
+
+	default:
+		return fmt.Errorf("block b%d has no terminator", block.Index)
+	}
+
+	return nil
+}
+
+// continues at target through the dispatch loop, from inside nested extra labels
+func (g *generator) jump(target *ir.Block, nested uint32) {
+	g.code.i32Const(int32(target.Index))
+	g.code.set(g.pc)
+	g.code.opU32(opBr, g.depth+nested)
+}
+
+// pushes an operand
+func (g *generator) push(v ir.Value) {
+	f := g.code
+
+	switch v := v.(type) {
+	case *ir.Local:
+		f.get(g.locals[v.ID])
+	case *ir.Const:
+		switch value := v.Value.(type) {
+		case int64: f.i64Const(value)
+		case float64: f.f64Const(value)
+		case string: f.i64Const(g.str(value))
+		case bool:
+			if value {
+				f.i32Const(1)
+			} else {
+				f.i32Const(0)
+			}
+		}
+	}
+}
+
+// pushes an operand as want, checking the tag of a list element and
+// unboxing it
+func (g *generator) pushAs(v ir.Value, want types.Type) {
+	g.push(v)
+
+	if want = types.Erase(want); typeOf(v) == types.Unknown && want != types.Unknown {
+		g.code.call(g.helper("as_" + want.String()))
+	}
+}
+
+// pushes the address of a cell holding the operand, boxing a statically
+// typed one into the operand cell of the given slot
+func (g *generator) pushCell(v ir.Value, slot uint32) {
+	if typeOf(v) == types.Unknown {
+		g.push(v)
+		return
+	}
+
+	addr := int32(g.operandAddr + slot*cellSize)
+	g.storeCell(func() { g.code.i32Const(addr) }, 0, typeOf(v), func() { g.push(v) })
+	g.code.i32Const(addr)
+}
+
+func (g *generator) instr(instr ir.Instr) error {
+	f := g.code
+
+	switch i := instr.(type) {
+	case *ir.Copy:
+		if types.IsUnknown(i.Dst.Type) {
+			g.storeCell(func() { f.get(g.locals[i.Dst.ID]) }, 0, typeOf(i.Src), func() { g.push(i.Src) })
+			return nil
+		}
+
+		g.pushAs(i.Src, i.Dst.Type)
+		f.set(g.locals[i.Dst.ID])
+
+	case *ir.Unary:
+		switch typeOf(i.X) {
+		case types.Unknown:
+			f.get(g.locals[i.Dst.ID])
+			g.push(i.X)
+			f.call(g.helper("negate"))
+			return nil
+		case types.Int:
+			f.i64Const(0)
+			g.push(i.X)
+			f.call(g.helper("sub_int"))
+		case types.Float:
+			g.push(i.X)
+			f.op(opF64Neg)
+		default:
+			return fmt.Errorf("invalid operation: cannot use '%s' on type '%s'", i.Op, typeOf(i.X))
+		}
+
+		f.set(g.locals[i.Dst.ID])
+
+	case *ir.Binary:
+		leftType, rightType := typeOf(i.X), typeOf(i.Y)
+
+		// operators on list elements are resolved by the runtime helpers
+		if leftType == types.Unknown || rightType == types.Unknown {
+			name, ok := elementOps[i.Op]
+			if !ok {
+				return fmt.Errorf("unsupported operator '%s'", i.Op)
+			}
+
+			if ir.IsComparison(i.Op) {
+				g.pushCell(i.X, 0)
+				g.pushCell(i.Y, 1)
+				f.call(g.helper("compare_" + name))
+				f.set(g.locals[i.Dst.ID])
+				return nil
+			}
+
+			f.get(g.locals[i.Dst.ID])
+			g.pushCell(i.X, 0)
+			g.pushCell(i.Y, 1)
+			f.call(g.helper("binary_" + name))
+			return nil
+		}
+
+		if leftType != rightType {
+			return fmt.Errorf("invalid operation '%s %s %s' in the native backend", leftType, i.Op, rightType)
+		}
+
+		g.push(i.X)
+		g.push(i.Y)
+		if err := g.binary(i.Op, leftType); err != nil {
+			return err
+		}
+
+		f.set(g.locals[i.Dst.ID])
+
+	case *ir.MakeList:
+		list := g.locals[i.Dst.ID]
+
+		f.i32Const(int32(listHeader + cellSize*len(i.Elems)))
+		f.call(g.helper("alloc"))
+		f.opU32(opLocalTee, list)
+		f.i32Const(int32(len(i.Elems)))
+		f.memory(opI32Store, 0)
+
+		for n, el := range i.Elems {
+			g.storeCell(func() { f.get(list) }, uint32(listHeader+cellSize*n), typeOf(el), func() { g.push(el) })
+		}
+
+	case *ir.Load:
+		g.cell(i.List, i.Index)
+		g.storeCell(func() { f.get(g.locals[i.Dst.ID]) }, 0, types.Unknown, func() { f.get(g.scratch) })
+
+	case *ir.Store:
+		g.cell(i.List, i.Index)
+		g.storeCell(func() { f.get(g.scratch) }, 0, typeOf(i.Value), func() { g.push(i.Value) })
+
+	case *ir.Say:
+		for n, arg := range i.Args {
+			if n > 0 && i.Sep != nil {
+				g.pushAs(i.Sep, types.Str)
+				f.call(g.helper("write_str"))
+			} else if n > 0 {
+				f.i32Const(' ')
+				f.call(g.helper("write_byte"))
+			}
+
+			g.push(arg)
+			f.call(g.helper(writers[typeOf(arg)]))
+		}
+
+		switch {
+		case i.End != nil:
+			g.pushAs(i.End, types.Str)
+			f.call(g.helper("write_str"))
+			f.call(g.helper("flush_lines"))
+		case i.Sep != nil:
+			f.call(g.helper("flush_lines"))
+			f.call(g.helper("flush"))
+		default:
+			f.call(g.helper("flush"))
+		}
+
+	case *ir.Display:
+		// the text is written to the pending output and taken back from it
+		f.opU32(opGlobalGet, g.outLen)
+		g.push(i.X)
+		f.call(g.helper(writers[typeOf(i.X)]))
+		f.call(g.helper("capture"))
+		f.set(g.locals[i.Dst.ID])
+
+	case *ir.Call:
+		for n, arg := range i.Args {
+			g.pushAs(arg, i.Func.Params[n].Type)
+		}
+		f.call(g.funcs[i.Func])
+
+		if i.Dst != nil {
+			f.set(g.locals[i.Dst.ID])
+		}
+
+	// simplescript.wit gives the module no way to reach its arguments or environment
+	case *ir.Args: return fmt.Errorf("args() is not supported by the native backend")
+	case *ir.Env: return fmt.Errorf("env() is not supported by the native backend")
+
+	default:
+		return fmt.Errorf("unsupported instruction %T", instr)
+	}
+
+	return nil
+}
+
+var writers = map[types.Type]string{
+	types.Int: "write_int",
+	types.Float: "write_float",
+	types.Bool: "write_bool",
+	types.Str: "write_str",
+	types.AnyList: "write_list",
+	types.Unknown: "write_cell",
+}
+
+// leaves the bounds-checked address of list[index] in the scratch local
+func (g *generator) cell(list, index ir.Value) {
+	g.pushAs(list, types.AnyList)
+	g.pushAs(index, types.Int)
+	g.code.call(g.helper("cell"))
+	g.code.set(g.scratch)
+}
+
+// stores a value and its tag into the cell at address()+offset
+func (g *generator) storeCell(address func(), offset uint32, dataType types.Type, value func()) {
+	f := g.code
+
+	if dataType == types.Unknown {
+		// copy the tag and the raw payload of another cell
+		address()
+		value()
+		f.memory(opI32Load, 0)
+		f.memory(opI32Store, offset)
+
+		address()
+		value()
+		f.memory(opI64Load, cellPayload)
+		f.memory(opI64Store, offset+cellPayload)
+		return
+	}
+
+	address()
+	f.i32Const(cellTags[dataType])
+	f.memory(opI32Store, offset)
+
+	address()
+	value()
+	f.memory(stores[dataType], offset+cellPayload)
+}
+
+var cellTags = map[types.Type]int32{
+	types.Int: tagInt,
+	types.Float: tagFloat,
+	types.Bool: tagBool,
+	types.Str: tagStr,
+	types.AnyList: tagList,
+}
+
+var stores = map[types.Type]byte{
+	types.Int: opI64Store,
+	types.Float: opF64Store,
+	types.Bool: opI32Store,
+	types.Str: opI64Store,
+	types.AnyList: opI32Store,
+}
+
+var loads = map[types.Type]byte{
+	types.Int: opI64Load,
+	types.Float: opF64Load,
+	types.Bool: opI32Load,
+	types.Str: opI64Load,
+	types.AnyList: opI32Load,
+}
+
+var comparisons = map[string][3]byte{
+	"==": {opI64Eq, opF64Eq, opI32Eq},
+	"!=": {opI64Ne, opF64Ne, opI32Ne},
+	"<": {opI64LtS, opF64Lt, opI32LtS},
+	">": {opI64GtS, opF64Gt, opI32GtS},
+	"<=": {opI64LeS, opF64Le, opI32LeS},
+	">=": {opI64GeS, opF64Ge, opI32GeS},
+}
+
+var arithmetic = map[string][2]byte{
+	"+": {opI64Add, opF64Add},
+	"-": {opI64Sub, opF64Sub},
+	"*": {opI64Mul, opF64Mul},
+	"/": {opI64DivS, opF64Div},
+}
+
+// the helpers for int operators that would otherwise wrap around; division
+// already traps on zero and on the one quotient that overflows
+var checkedInts = map[string]string{"+": "add_int", "-": "sub_int", "*": "mul_int"}
+
+// pushes an int operator, checked for overflow
+func (g *generator) intOp(f *function, op string) {
+	if name, ok := checkedInts[op]; ok {
+		f.call(g.helper(name))
+		return
+	}
+
+	f.op(arithmetic[op][0])
+}
+
+// applies an operator to two operands of the same type already on the stack;
+// integer overflow and division by zero trap like the TinyGo build with
+// -panic=trap
+func (g *generator) binary(op string, dataType types.Type) error {
+	f := g.code
+
+	if ops, ok := comparisons[op]; ok {
+		switch dataType {
+		case types.Int: f.op(ops[0])
+		case types.Float: f.op(ops[1])
+		case types.Str:
+			f.call(g.helper("compare"))
+			f.i32Const(0)
+			f.op(ops[2])
+		case types.Bool:
+			if op != "==" && op != "!=" {
+				return fmt.Errorf("invalid operation: operator %s not defined on bool", op)
+			}
+			f.op(ops[2])
+		default:
+			return fmt.Errorf("invalid operation: cannot compare '%s' values", dataType)
+		}
+
+		return nil
+	}
+
+	ops, ok := arithmetic[op]
+	if !ok {
+		return fmt.Errorf("unsupported operator '%s'", op)
+	}
+
+	switch {
+	case dataType == types.Int: g.intOp(f, op)
+	case dataType == types.Float: f.op(ops[1])
+	case dataType == types.Str && op == "+": f.call(g.helper("concat"))
+	default:
+		return fmt.Errorf("invalid operation: operator %s not defined on '%s'", op, dataType)
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package wasm
+
+import (
+	"strings"
+	"testing"
+
+	"simplescript/internal/testutil"
+)
+
+// validates the module and runs it with a host `print` that appends a newline
+func run(t *testing.T, input string) (string, error) {
+	t.Helper()
+
+	binary, err := Generate(testutil.Lower(t, input))
+	if err != nil {
+		t.Fatalf("generation error: %v", err)
+	}
+
+	if err := Validate(binary); err != nil {
+		t.Fatalf("invalid module: %v", err)
+	}
+
+	return testutil.RunNative(t, binary)
+}
+
+func TestPrograms(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+		expected string
+	}{
+		{"arithmetic", `say((10 + 20) * 2, 7 / 2, -7 / 2, 0.5 * 3.0, -4)`, "60 3 -3 1.5 -4\n"},
+		{"integers", `say(0, -9223372036854775807 - 1, 1000000)`, "0 -9223372036854775808 1000000\n"},
+		{
+			"floats",
+			`say(0.1 + 0.2, 1.0 / 3.0, 2.5, 100000.0, 1000000.0, 1234567.0, 0.0001, 0.00001, -0.5, 0.0, 3.0)`,
+			"0.30000000000000004 0.3333333333333333 2.5 100000.0 1000000.0 1234567.0 0.0001 1e-05 -0.5 0.0 3.0\n",
+		},
+		{"float specials", `var z: float = 0.0 say(1.0 / z, -1.0 / z, z / z, -z)`, "+Inf -Inf NaN -0.0\n"},
+		{
+			"float extremes",
+			"say(0." + strings.Repeat("0", 323) + "5, 179769313486231570" + strings.Repeat("0", 291) + ".0, 20611068127987648.0)",
+			"5e-324 1.7976931348623157e+308 2.061106812798765e+16\n",
+		},
+		{"strings", `var s: str = "ab" + "cd" say(s, s == "abcd", "a" < "b", "ab" < "a", "" == "")`, "abcd true true false true\n"},
+		{"booleans", `say(true, false, true == false, 1 < 2)`, "true false false true\n"},
+		{"lists", `var xs: list = [1, "a", true, [2.5]] xs[1] = 2 say(xs, xs[3], xs[0])`, "[1, 2, true, [2.5]] [2.5] 1\n"},
+		{"list copies", `var xs: list = [1, 2] var ys: list = [xs[1], xs] ys[0] = xs[0] say(ys)`, "[1, [1, 2]]\n"},
+		{
+			"element reads",
+			`var xs: list = [1, 2.5, "a", true, [3]] var n: int = xs[0] var f: float = xs[1] var s: str = xs[2] if xs[3] { say(n, f, s, xs[4][0]) }`,
+			"1 2.5 a 3\n",
+		},
+		{
+			"element operators",
+			`var xs: list = [7, 2.5, "a"] xs[0] /= 2 say(xs[0] + 1, xs[1] * 2.0, xs[2] + "b", -xs[0], -xs[1])`,
+			"4 5.0 ab -3 -2.5\n",
+		},
+		{
+			"element comparisons",
+			`var xs: list = [1, 2.5, "a", true, []] say(xs[0] == 1, xs[0] != 1.0, xs[1] < 3.0, xs[2] >= "b", xs[3] == true, xs[3] != 1)`,
+			"true true true false true true\n",
+		},
+		{"element swap", `var xs: list = [1, 2] xs[0], xs[1] = xs[1], xs[0] var x: list = [xs[0]] xs[0] = 3 say(xs, x)`, "[3, 1] [2]\n"},
+		{"swap", `var a: int = 1 var b: int = 2 a, b = b, a say(a, b)`, "2 1\n"},
+		{"augmented", `var a: int = 1 a += 4 a *= 3 var s: str = "x" s += "y" say(a, s)`, "15 xy\n"},
+		{"shadowing", `var x: int = 1 if true { var x: str = "inner" say(x) } say(x)`, "inner\n1\n"},
+		{
+			"loops",
+			`var sum: int = 0
+			for i in 0..10 {
+				if i == 2 { continue }
+				if i == 5 { break }
+				for j in 0..i { sum += 1 }
+			}
+			say(sum)`,
+			"8\n",
+		},
+		{"else if", `var n: int = 5 if n < 3 { say("small") } else if n < 10 { say("medium") } else { say("large") }`, "medium\n"},
+		{"return ends the program", `say(1) return 0 say(2)`, "1\n"},
+		{"empty say", `say()`, "\n"},
+		{"quoted elements", `var xs: list = ['a"b', 'back\slash'] say(xs, xs[0])`, `["a\"b", "back\\slash"] a"b` + "\n"},
+		{"say options", "say(1, 2, sep=', ', end='') say('!') say('a', 'b', sep='\n')", "1, 2!\na\nb\n"},
+		{"pending output", `say("no newline", end="")`, "no newline\n"},
+		{"format", `var xs: list = [1.0, "x"] var s: str = format("{} of {} {{}}", xs, 3) say(s)`, `[1.0, "x"] of 3 {}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := run(t, tt.input)
+			if err != nil {
+				t.Fatalf("unexpected runtime error: %v", err)
+			}
+
+			if out != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, out)
+			}
+		})
+	}
+}
+
+func TestTraps(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+	}{
+		{"division by zero", `var z: int = 0 say(1 / z)`},
+		{"overflowing sum", `var n: int = 9223372036854775807 say(n + 1)`},
+		{"overflowing difference", `var n: int = -9223372036854775807 say(n - 2)`},
+		{"overflowing product", `var n: int = 4611686018427387904 say(n * 2)`},
+		{"overflowing negation", `var n: int = -9223372036854775807 n -= 1 say(-n)`},
+		{"overflowing quotient", `var n: int = -9223372036854775807 n -= 1 say(n / -1)`},
+		{"overflowing element sum", `var xs: list = [9223372036854775807] say(xs[0] + 1)`},
+		{"index out of range", `var xs: list = [1] say(xs[1])`},
+		{"negative index", `var xs: list = [1] var i: int = -1 xs[i] = 2`},
+		{"element of another type", `var xs: list = ["a"] var n: int = xs[0]`},
+		{"mismatched element operands", `var xs: list = [1, 2.5] say(xs[0] + xs[1])`},
+		{"ordered bools", `var xs: list = [true] say(xs[0] < false)`},
+		{"compared lists", `var xs: list = [[1]] say(xs[0] == xs[0])`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := run(t, tt.input); err == nil {
+				t.Fatal("expected the module to trap")
+			}
+		})
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string
+	}{
+		{`say(args())`, "args() is not supported"},
+		{`say(env("HOME"))`, "env() is not supported"},
+	}
+
+	for _, tt := range tests {
+		_, err := Generate(testutil.Lower(t, tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("expected error containing %q, got %v", tt.expected, err)
+		}
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	input := `
+	say(fib(10))
+	greet("bob")
+	say(sum([3, 4]), first([1.5, 2]))
+
+	export func fib(n: int) int {
+		if n < 2 {
+			return n
+		}
+		return fib(n - 1) + fib(n - 2)
+	}
+
+	func greet(name: str) {
+		say(format("hi {}", name), end="")
+	}
+
+	func sum(xs: list[int]) int {
+		var total: int = 0
+		for i in 0..2 {
+			total = total + xs[i]
+		}
+		return total
+	}
+
+	export func first(xs: list) float {
+		return xs[0]
+	}
+
+	export func clamp(n: int, negative: bool) int {
+		if negative {
+			if n > 0 {
+				return 0
+			}
+		}
+		return n
+	}
+
+	export func hello() {
+		say("hey", end="")
+	}`
+
+	out, err := run(t, input)
+	if err != nil {
+		t.Fatalf("unexpected runtime error: %v", err)
+	}
+
+	if expected := "55\nhi bob7 1.5\n"; out != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	binary, err := Generate(testutil.Lower(t, input))
+	if err != nil {
+		t.Fatalf("generation error: %v", err)
+	}
+
+	results, _, err := testutil.CallNative(t, binary, "fib", 20)
+	if err != nil || results[0] != 6765 {
+		t.Errorf("expected fib(20) to return 6765, got %v, %v", results, err)
+	}
+
+	// bools are passed as i32 0 or 1
+	results, _, err = testutil.CallNative(t, binary, "clamp", 5, 1)
+	if err != nil || results[0] != 0 {
+		t.Errorf("expected clamp(5, true) to return 0, got %v, %v", results, err)
+	}
+
+	// the wrapper flushes what the call printed without a final newline
+	if _, out, err := testutil.CallNative(t, binary, "hello"); err != nil || out != "hey\n" {
+		t.Errorf("expected hello() to print %q, got %q, %v", "hey\n", out, err)
+	}
+}
@@ -0,0 +1,255 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+type ValType byte
+
+const (
+	I32 ValType = 0x7F
+	I64 ValType = 0x7E
+	F64 ValType = 0x7C
+)
+
+// block type of structured instructions that leave nothing on the stack
+const blockEmpty byte = 0x40
+
+type funcType struct {
+	params []ValType
+	results []ValType
+}
+
+func (t funcType) equal(other funcType) bool {
+	return bytes.Equal(valBytes(t.params), valBytes(other.params)) &&
+		bytes.Equal(valBytes(t.results), valBytes(other.results))
+}
+
+type importFunc struct {
+	module, name string
+	typeIndex uint32
+}
+
+type global struct {
+	t ValType
+	initial int32
+}
+
+type export struct {
+	name string
+	kind byte
+	index uint32
+}
+
+const (
+	exportFunc byte = 0x00
+	exportMemory byte = 0x02
+)
+
+// a module under construction; functions are indexed after the imports
+type module struct {
+	types []funcType
+	imports []importFunc
+	funcs []*function
+	globals []global
+	exports []export
+	data []byte
+	dataBase uint32
+}
+
+func (m *module) typeIndex(t funcType) uint32 {
+	for i, existing := range m.types {
+		if existing.equal(t) {
+			return uint32(i)
+		}
+	}
+
+	m.types = append(m.types, t)
+	return uint32(len(m.types) - 1)
+}
+
+func (m *module) importFunc(moduleName, name string, params, results []ValType) uint32 {
+	m.imports = append(m.imports, importFunc{
+		module: moduleName,
+		name: name,
+		typeIndex: m.typeIndex(funcType{params, results}),
+	})
+
+	return uint32(len(m.imports) - 1)
+}
+
+// declares a function whose body is filled in by the caller
+func (m *module) newFunction(params, results []ValType) (*function, uint32) {
+	f := &function{
+		typeIndex: m.typeIndex(funcType{params, results}),
+		params: uint32(len(params)),
+	}
+
+	m.funcs = append(m.funcs, f)
+	return f, uint32(len(m.imports) + len(m.funcs) - 1)
+}
+
+// declares a mutable global; f64 globals always start at zero
+func (m *module) global(t ValType, initial int32) uint32 {
+	m.globals = append(m.globals, global{t: t, initial: initial})
+	return uint32(len(m.globals) - 1)
+}
+
+// places bytes in the data segment and returns their address
+func (m *module) addData(b []byte) uint32 {
+	addr := m.dataBase + uint32(len(m.data))
+	m.data = append(m.data, b...)
+	return addr
+}
+
+// first address after the data segment, aligned for the bump allocator
+func (m *module) dataEnd() uint32 {
+	return (m.dataBase + uint32(len(m.data)) + 7) &^ 7
+}
+
+func (m *module) encode() []byte {
+	var out bytes.Buffer
+	out.Write([]byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00})
+
+	section := func(id byte, count int, body func(*bytes.Buffer)) {
+		if count == 0 {
+			return
+		}
+
+		var content bytes.Buffer
+		writeU32(&content, uint32(count))
+		body(&content)
+
+		out.WriteByte(id)
+		writeU32(&out, uint32(content.Len()))
+		out.Write(content.Bytes())
+	}
+
+	section(1, len(m.types), func(b *bytes.Buffer) {
+		for _, t := range m.types {
+			b.WriteByte(0x60)
+			writeU32(b, uint32(len(t.params)))
+			b.Write(valBytes(t.params))
+			writeU32(b, uint32(len(t.results)))
+			b.Write(valBytes(t.results))
+		}
+	})
+
+	section(2, len(m.imports), func(b *bytes.Buffer) {
+		for _, imp := range m.imports {
+			writeName(b, imp.module)
+			writeName(b, imp.name)
+			b.WriteByte(0x00)
+			writeU32(b, imp.typeIndex)
+		}
+	})
+
+	section(3, len(m.funcs), func(b *bytes.Buffer) {
+		for _, f := range m.funcs {
+			writeU32(b, f.typeIndex)
+		}
+	})
+
+	// one growable memory large enough for the data segment
+	section(5, 1, func(b *bytes.Buffer) {
+		pages := (m.dataEnd() + 0xFFFF) / 0x10000
+		b.WriteByte(0x00)
+		writeU32(b, max(pages, 1))
+	})
+
+	section(6, len(m.globals), func(b *bytes.Buffer) {
+		for _, gl := range m.globals {
+			b.WriteByte(byte(gl.t))
+			b.WriteByte(0x01)
+			if gl.t == F64 {
+				b.WriteByte(opF64Const)
+				writeF64(b, 0)
+			} else {
+				b.WriteByte(opI32Const)
+				writeS64(b, int64(gl.initial))
+			}
+			b.WriteByte(opEnd)
+		}
+	})
+
+	section(7, len(m.exports), func(b *bytes.Buffer) {
+		for _, exp := range m.exports {
+			writeName(b, exp.name)
+			b.WriteByte(exp.kind)
+			writeU32(b, exp.index)
+		}
+	})
+
+	section(10, len(m.funcs), func(b *bytes.Buffer) {
+		for _, f := range m.funcs {
+			body := f.encode()
+			writeU32(b, uint32(len(body)))
+			b.Write(body)
+		}
+	})
+
+	if len(m.data) > 0 {
+		section(11, 1, func(b *bytes.Buffer) {
+			b.WriteByte(0x00)
+			b.WriteByte(opI32Const)
+			writeS64(b, int64(m.dataBase))
+			b.WriteByte(opEnd)
+			writeU32(b, uint32(len(m.data)))
+			b.Write(m.data)
+		})
+	}
+
+	return out.Bytes()
+}
+
+func valBytes(types []ValType) []byte {
+	b := make([]byte, len(types))
+	for i, t := range types {
+		b[i] = byte(t)
+	}
+
+	return b
+}
+
+func writeName(b *bytes.Buffer, name string) {
+	writeU32(b, uint32(len(name)))
+	b.WriteString(name)
+}
+
+// unsigned LEB128
+func writeU32(b *bytes.Buffer, v uint32) {
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+
+		if v == 0 {
+			b.WriteByte(c)
+			return
+		}
+
+		b.WriteByte(c | 0x80)
+	}
+}
+
+// signed LEB128
+func writeS64(b *bytes.Buffer, v int64) {
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			b.WriteByte(c)
+			return
+		}
+
+		b.WriteByte(c | 0x80)
+	}
+}
+
+func writeF64(b *bytes.Buffer, v float64) {
+	var raw [8]byte
+	binary.LittleEndian.PutUint64(raw[:], math.Float64bits(v))
+	b.Write(raw[:])
+}
@@ -0,0 +1,1457 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"math"
+	"math/big"
+
+	"simplescript/internal/ir"
+	"simplescript/internal/types"
+)
+
+// Linear memory layout: a one byte staging slot at address 0, a scratch
+// area for number formatting, tables of powers of ten, two cells boxing
+// the operands of an operator on list elements, string literals, and then
+// the heap managed by a bump allocator that never frees.
+const (
+	byteAddr = 0
+	scratchAddr = 8
+	scratchSize = 32
+	pow10Count = 23
+)
+
+// relative distance at which a candidate counts as lying on the rounding boundary
+const tieTolerance = 1e-12
+
+// Lists are a length word followed by 16 byte cells holding a tag at
+// offset 0 and the payload at offset 8, mirroring the Go backend's []interface{}.
+const (
+	listHeader = 8
+	cellSize = 16
+	cellPayload = 8
+)
+
+const (
+	tagInt int32 = iota
+	tagFloat
+	tagBool
+	tagStr
+	tagList
+)
+
+type helperSpec struct {
+	params []ValType
+	results []ValType
+	build func(g *generator, f *function)
+}
+
+var helpers map[string]helperSpec
+
+func init() {
+	helpers = map[string]helperSpec{
+		"alloc": {[]ValType{I32}, []ValType{I32}, buildAlloc},
+		"memcpy": {[]ValType{I32, I32, I32}, nil, buildMemcpy},
+		"write": {[]ValType{I32, I32}, nil, buildWrite},
+		"write_byte": {[]ValType{I32}, nil, buildWriteByte},
+		"write_str": {[]ValType{I64}, nil, buildWriteStr},
+		"write_int": {[]ValType{I64}, nil, buildWriteInt},
+		"write_float": {[]ValType{F64}, nil, buildWriteFloat},
+		"write_bool": {[]ValType{I32}, nil, buildWriteBool},
+		"write_list": {[]ValType{I32}, nil, buildWriteList},
+		"write_cell": {[]ValType{I32}, nil, buildWriteCell},
+		"write_element": {[]ValType{I32}, nil, buildWriteElement},
+		"write_quoted": {[]ValType{I64}, nil, buildWriteQuoted},
+		"flush": {nil, nil, buildFlush},
+		"flush_lines": {nil, nil, buildFlushLines},
+		"capture": {[]ValType{I32}, []ValType{I64}, buildCapture},
+		"scale": {[]ValType{F64, I32}, []ValType{F64}, buildScale},
+		"mul_dd": {[]ValType{F64, F64, F64, F64}, []ValType{F64}, buildMulDD},
+		"concat": {[]ValType{I64, I64}, []ValType{I64}, buildConcat},
+		"compare": {[]ValType{I64, I64}, []ValType{I32}, buildCompare},
+		"cell": {[]ValType{I32, I64}, []ValType{I32}, buildCell},
+		"as_int": {[]ValType{I32}, []ValType{I64}, buildAs(types.Int)},
+		"as_float": {[]ValType{I32}, []ValType{F64}, buildAs(types.Float)},
+		"as_bool": {[]ValType{I32}, []ValType{I32}, buildAs(types.Bool)},
+		"as_str": {[]ValType{I32}, []ValType{I64}, buildAs(types.Str)},
+		"as_list": {[]ValType{I32}, []ValType{I32}, buildAs(types.AnyList)},
+		"negate": {[]ValType{I32, I32}, nil, buildNegate},
+		"add_int": {[]ValType{I64, I64}, []ValType{I64}, buildCheckedInt("+")},
+		"sub_int": {[]ValType{I64, I64}, []ValType{I64}, buildCheckedInt("-")},
+		"mul_int": {[]ValType{I64, I64}, []ValType{I64}, buildCheckedInt("*")},
+	}
+
+	for op, name := range elementOps {
+		if ir.IsComparison(op) {
+			helpers["compare_"+name] = helperSpec{[]ValType{I32, I32}, []ValType{I32}, buildCompareCells(op)}
+		} else {
+			helpers["binary_"+name] = helperSpec{[]ValType{I32, I32, I32}, nil, buildBinaryCells(op)}
+		}
+	}
+}
+
+// names of the helpers applying an operator to the cells of two list elements
+var elementOps = map[string]string{
+	"+": "add", "-": "sub", "*": "mul", "/": "div",
+	"==": "eq", "!=": "ne", "<": "lt", ">": "gt", "<=": "le", ">=": "ge",
+}
+
+// returns the index of a runtime helper, emitting it on first use
+func (g *generator) helper(name string) uint32 {
+	if index, ok := g.helpers[name]; ok {
+		return index
+	}
+
+	spec := helpers[name]
+	f, index := g.mod.newFunction(spec.params, spec.results)
+	g.helpers[name] = index
+	spec.build(g, f)
+
+	return index
+}
+
+// reserves the fixed low memory regions
+func (g *generator) layoutMemory() {
+	g.mod.dataBase = scratchAddr
+	g.mod.addData(make([]byte, scratchSize))
+
+	table := make([]byte, 8*pow10Count)
+	power := 1.0
+	for i := range pow10Count {
+		binary.LittleEndian.PutUint64(table[8*i:], math.Float64bits(power))
+		power *= 10
+	}
+
+	g.pow10Addr = g.mod.addData(table)
+
+	// 10^-j as double-doubles, since none of them is exact in binary
+	negTable := make([]byte, 16*pow10Count)
+	for j := range pow10Count {
+		exact := new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(j)), nil))
+		high, _ := exact.Float64()
+		low, _ := new(big.Rat).Sub(exact, new(big.Rat).SetFloat64(high)).Float64()
+
+		binary.LittleEndian.PutUint64(negTable[16*j:], math.Float64bits(high))
+		binary.LittleEndian.PutUint64(negTable[16*j+8:], math.Float64bits(low))
+	}
+
+	g.pow10NegAddr = g.mod.addData(negTable)
+	g.operandAddr = g.mod.addData(make([]byte, 2*cellSize))
+}
+
+// interns a string literal and returns it packed as address<<32 | length
+func (g *generator) str(s string) int64 {
+	if packed, ok := g.strings[s]; ok {
+		return packed
+	}
+
+	addr := g.mod.addData([]byte(s))
+	packed := int64(addr)<<32 | int64(len(s))
+	g.strings[s] = packed
+
+	return packed
+}
+
+func buildAlloc(g *generator, f *function) {
+	const size = 0
+	ptr := f.local(I32)
+
+	f.opU32(opGlobalGet, g.heap)
+	f.set(ptr)
+
+	f.opU32(opGlobalGet, g.heap)
+	f.get(size)
+	f.op(opI32Add)
+	f.i32Const(7)
+	f.op(opI32Add)
+	f.i32Const(-8)
+	f.op(opI32And)
+	f.opU32(opGlobalSet, g.heap)
+
+	// grow memory by whole pages when the heap passes its end
+	f.op(opBlock, blockEmpty)
+	f.opU32(opGlobalGet, g.heap)
+	f.op(opMemorySize, 0x00)
+	f.i32Const(16)
+	f.op(opI32Shl, opI32LeU)
+	f.opU32(opBrIf, 0)
+
+	f.opU32(opGlobalGet, g.heap)
+	f.op(opMemorySize, 0x00)
+	f.i32Const(16)
+	f.op(opI32Shl, opI32Sub)
+	f.i32Const(0xFFFF)
+	f.op(opI32Add)
+	f.i32Const(16)
+	f.op(opI32ShrU, opMemoryGrow, 0x00)
+	f.i32Const(-1)
+	f.op(opI32Ne)
+	f.opU32(opBrIf, 0)
+	f.op(opUnreachable)
+	f.op(opEnd)
+
+	f.get(ptr)
+}
+
+func buildMemcpy(g *generator, f *function) {
+	const dst, src, n = 0, 1, 2
+
+	f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+	f.get(n)
+	f.op(opI32Eqz)
+	f.opU32(opBrIf, 1)
+
+	f.get(dst)
+	f.get(src)
+	f.memory(opI32Load8U, 0)
+	f.memory(opI32Store8, 0)
+
+	for _, local := range []uint32{dst, src} {
+		f.get(local)
+		f.i32Const(1)
+		f.op(opI32Add)
+		f.set(local)
+	}
+
+	f.get(n)
+	f.i32Const(1)
+	f.op(opI32Sub)
+	f.set(n)
+	f.opU32(opBr, 0)
+	f.op(opEnd, opEnd)
+}
+
+// appends bytes to the pending output line, doubling its buffer as needed
+func buildWrite(g *generator, f *function) {
+	const ptr, length = 0, 1
+	need := f.local(I32)
+	buffer := f.local(I32)
+
+	f.opU32(opGlobalGet, g.outLen)
+	f.get(length)
+	f.op(opI32Add)
+	f.set(need)
+
+	f.get(need)
+	f.opU32(opGlobalGet, g.outCap)
+	f.op(opI32GtS, opIf, blockEmpty)
+	f.get(need)
+	f.i32Const(1)
+	f.op(opI32Shl)
+	f.i32Const(64)
+	f.op(opI32Add)
+	f.opU32(opGlobalSet, g.outCap)
+
+	f.opU32(opGlobalGet, g.outCap)
+	f.call(g.helper("alloc"))
+	f.set(buffer)
+
+	f.get(buffer)
+	f.opU32(opGlobalGet, g.outPtr)
+	f.opU32(opGlobalGet, g.outLen)
+	f.call(g.helper("memcpy"))
+
+	f.get(buffer)
+	f.opU32(opGlobalSet, g.outPtr)
+	f.op(opEnd)
+
+	f.opU32(opGlobalGet, g.outPtr)
+	f.opU32(opGlobalGet, g.outLen)
+	f.op(opI32Add)
+	f.get(ptr)
+	f.get(length)
+	f.call(g.helper("memcpy"))
+
+	f.get(need)
+	f.opU32(opGlobalSet, g.outLen)
+}
+
+func buildWriteByte(g *generator, f *function) {
+	f.i32Const(byteAddr)
+	f.get(0)
+	f.memory(opI32Store8, 0)
+
+	f.i32Const(byteAddr)
+	f.i32Const(1)
+	f.call(g.helper("write"))
+}
+
+func buildWriteStr(g *generator, f *function) {
+	f.get(0)
+	f.i64Const(32)
+	f.op(opI64ShrU, opI32WrapI64)
+	f.get(0)
+	f.op(opI32WrapI64)
+	f.call(g.helper("write"))
+}
+
+// writes the decimal digits from the end of the scratch area backwards
+func buildWriteInt(g *generator, f *function) {
+	const v = 0
+	pos := f.local(I32)
+	negative := f.local(I32)
+
+	f.get(v)
+	f.i64Const(0)
+	f.op(opI64LtS)
+	f.opU32(opLocalTee, negative)
+	f.op(opIf, blockEmpty)
+	// 0 - v viewed as unsigned also covers the most negative int
+	f.i64Const(0)
+	f.get(v)
+	f.op(opI64Sub)
+	f.set(v)
+	f.op(opEnd)
+
+	f.i32Const(scratchAddr + scratchSize)
+	f.set(pos)
+
+	f.op(opLoop, blockEmpty)
+	f.get(pos)
+	f.i32Const(1)
+	f.op(opI32Sub)
+	f.opU32(opLocalTee, pos)
+	f.get(v)
+	f.i64Const(10)
+	f.op(opI64RemU)
+	f.i64Const('0')
+	f.op(opI64Add, opI32WrapI64)
+	f.memory(opI32Store8, 0)
+
+	f.get(v)
+	f.i64Const(10)
+	f.op(opI64DivU)
+	f.opU32(opLocalTee, v)
+	f.op(opI64Eqz, opI32Eqz)
+	f.opU32(opBrIf, 0)
+	f.op(opEnd)
+
+	f.get(negative)
+	f.op(opIf, blockEmpty)
+	f.i32Const('-')
+	f.call(g.helper("write_byte"))
+	f.op(opEnd)
+
+	f.get(pos)
+	f.i32Const(scratchAddr + scratchSize)
+	f.get(pos)
+	f.op(opI32Sub)
+	f.call(g.helper("write"))
+}
+
+// Writes the canonical display of a float: the shortest digits that still
+// parse back to v, in %e form when the exponent is below -4 or at least 16,
+// and with a ".0" when written out in full without a fraction.
+// Digits are found by scaling v with double-double arithmetic (about 106
+// bits) and accepting the first candidate inside v's rounding interval.
+func buildWriteFloat(g *generator, f *function) {
+	const v = 0
+	x := f.local(F64)
+	e := f.local(I32)
+	p := f.local(I32)
+	k := f.local(I32)
+	exp := f.local(I32)
+	nd := f.local(I32)
+	i := f.local(I32)
+	d := f.local(I64)
+	t := f.local(I64)
+	hi := f.local(F64)
+	trunc := f.local(F64)
+	diff := f.local(F64)
+	half := f.local(F64)
+	ulp := f.local(F64)
+	bits := f.local(I64)
+	asymmetric := f.local(I32)
+
+	writeStr := func(s string) {
+		f.i64Const(g.str(s))
+		f.call(g.helper("write_str"))
+	}
+	writeByte := func(c byte) {
+		f.i32Const(int32(c))
+		f.call(g.helper("write_byte"))
+	}
+	special := func(cond func(), s string) {
+		cond()
+		f.op(opIf, blockEmpty)
+		writeStr(s)
+		f.op(opReturn, opEnd)
+	}
+	increment := func(local uint32, delta int32) {
+		f.get(local)
+		f.i32Const(delta)
+		f.op(opI32Add)
+		f.set(local)
+	}
+	// writes '0' count times
+	zeros := func(count func()) {
+		count()
+		f.set(i)
+		f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+		f.get(i)
+		f.i32Const(0)
+		f.op(opI32LeS)
+		f.opU32(opBrIf, 1)
+		writeByte('0')
+		increment(i, -1)
+		f.opU32(opBr, 0)
+		f.op(opEnd, opEnd)
+	}
+
+	special(func() { f.get(v); f.get(v); f.op(opF64Ne) }, "NaN")
+	special(func() { f.get(v); f.f64Const(math.Inf(1)); f.op(opF64Eq) }, "+Inf")
+	special(func() { f.get(v); f.f64Const(math.Inf(-1)); f.op(opF64Eq) }, "-Inf")
+
+	f.get(v)
+	f.op(opI64ReinterpretF64)
+	f.i64Const(0)
+	f.op(opI64LtS, opIf, blockEmpty)
+	writeByte('-')
+	f.get(v)
+	f.op(opF64Neg)
+	f.set(v)
+	f.op(opEnd)
+
+	f.get(v)
+	f.f64Const(0)
+	f.op(opF64Eq, opIf, blockEmpty)
+	writeStr("0.0")
+	f.op(opReturn, opEnd)
+
+	// half the distance to the neighbouring doubles bounds the accepted digits;
+	// the gap below a power of two is half as wide
+	f.get(v)
+	f.op(opI64ReinterpretF64)
+	f.opU32(opLocalTee, bits)
+	f.i64Const(52)
+	f.op(opI64ShrU)
+	f.opU32(opLocalTee, t)
+	f.i64Const(52)
+	f.op(opI64GtS, opIf, byte(I64))
+	f.get(t)
+	f.i64Const(52)
+	f.op(opI64Sub)
+	f.i64Const(52)
+	f.op(opI64Shl)
+	f.op(opElse)
+	f.i64Const(1)
+	f.get(t)
+	f.i64Const(1)
+	f.op(opI64Sub)
+	f.i64Const(0)
+	f.get(t)
+	f.op(opI64Eqz, opI32Eqz, opSelect, opI64Shl)
+	f.op(opEnd)
+	f.op(opF64ReinterpretI64)
+	f.set(ulp)
+
+	f.get(bits)
+	f.i64Const(1<<52 - 1)
+	f.op(opI64And, opI64Eqz)
+	f.get(t)
+	f.i64Const(1)
+	f.op(opI64GtS, opI32And)
+	f.set(asymmetric)
+
+	// estimate the decimal exponent of the leading digit, then correct it
+	f.get(v)
+	f.set(x)
+	for _, step := range []struct {
+		done byte
+		scale byte
+		delta int32
+	}{{opF64Lt, opF64Div, 1}, {opF64Ge, opF64Mul, -1}} {
+		f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+		f.get(x)
+		if step.delta > 0 {
+			f.f64Const(10)
+		} else {
+			f.f64Const(1)
+		}
+		f.op(step.done)
+		f.opU32(opBrIf, 1)
+		f.get(x)
+		f.f64Const(10)
+		f.op(step.scale)
+		f.set(x)
+		increment(e, step.delta)
+		f.opU32(opBr, 0)
+		f.op(opEnd, opEnd)
+	}
+
+	f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+	f.get(v)
+	f.i32Const(0)
+	f.get(e)
+	f.op(opI32Sub)
+	f.call(g.helper("scale"))
+	f.opU32(opLocalTee, x)
+	f.f64Const(10)
+	f.op(opF64Ge, opIf, blockEmpty)
+	increment(e, 1)
+	f.opU32(opBr, 1)
+	f.op(opEnd)
+	f.get(x)
+	f.f64Const(1)
+	f.op(opF64Lt, opIf, blockEmpty)
+	increment(e, -1)
+	f.opU32(opBr, 1)
+	f.op(opEnd)
+	f.op(opEnd, opEnd)
+
+	// try 1..17 significant digits: d = round(v * 10^k)
+	f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+	increment(p, 1)
+	f.get(p)
+	f.i32Const(1)
+	f.op(opI32Sub)
+	f.get(e)
+	f.op(opI32Sub)
+	f.set(k)
+
+	f.get(v)
+	f.get(k)
+	f.call(g.helper("scale"))
+	f.opU32(opLocalTee, hi)
+	f.op(opF64Trunc)
+	f.set(trunc)
+
+	f.get(trunc)
+	f.op(opI64TruncF64U)
+	f.get(hi)
+	f.get(trunc)
+	f.op(opF64Sub)
+	f.opU32(opGlobalGet, g.low)
+	f.op(opF64Add, opF64Nearest, opI64TruncF64S, opI64Add)
+	f.set(d)
+
+	// d - v * 10^k, keeping the large integer parts apart
+	f.get(d)
+	f.get(trunc)
+	f.op(opI64TruncF64U, opI64Sub, opF64ConvertI64S)
+	f.get(hi)
+	f.get(trunc)
+	f.op(opF64Sub, opF64Sub)
+	f.opU32(opGlobalGet, g.low)
+	f.op(opF64Sub)
+	f.set(diff)
+
+	f.get(ulp)
+	f.get(k)
+	f.call(g.helper("scale"))
+	f.f64Const(0.5)
+	f.op(opF64Mul)
+	f.set(half)
+
+	// candidates exactly on the boundary parse back to v only when its
+	// mantissa is even, since ties round to even
+	inside := func(side func(), bound func()) {
+		side()
+		bound()
+		bound()
+		f.f64Const(tieTolerance)
+		f.op(opF64Mul, opF64Sub, opF64Lt)
+
+		side()
+		bound()
+		f.op(opF64Sub, opF64Abs)
+		bound()
+		f.f64Const(tieTolerance)
+		f.op(opF64Mul, opF64Le)
+		f.get(bits)
+		f.i64Const(1)
+		f.op(opI64And, opI64Eqz, opI32And, opI32Or)
+	}
+
+	inside(func() { f.get(diff) }, func() { f.get(half) })
+	inside(
+		func() { f.f64Const(0); f.get(diff); f.op(opF64Sub) },
+		func() {
+			f.get(half)
+			f.f64Const(0.5)
+			f.op(opF64Mul)
+			f.get(half)
+			f.get(asymmetric)
+			f.op(opSelect)
+		},
+	)
+	f.op(opI32And)
+	f.opU32(opBrIf, 1)
+
+	f.get(p)
+	f.i32Const(17)
+	f.op(opI32LtS)
+	f.opU32(opBrIf, 0)
+	f.op(opEnd, opEnd)
+
+	// count the digits to place the decimal point, then drop trailing zeros
+	f.get(d)
+	f.set(t)
+	f.op(opLoop, blockEmpty)
+	increment(nd, 1)
+	f.get(t)
+	f.i64Const(10)
+	f.op(opI64DivU)
+	f.opU32(opLocalTee, t)
+	f.op(opI64Eqz, opI32Eqz)
+	f.opU32(opBrIf, 0)
+	f.op(opEnd)
+
+	f.get(nd)
+	f.i32Const(1)
+	f.op(opI32Sub)
+	f.get(k)
+	f.op(opI32Sub)
+	f.set(exp)
+
+	f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+	f.get(nd)
+	f.i32Const(1)
+	f.op(opI32LeS)
+	f.get(d)
+	f.i64Const(10)
+	f.op(opI64RemU, opI64Eqz, opI32Eqz, opI32Or)
+	f.opU32(opBrIf, 1)
+	f.get(d)
+	f.i64Const(10)
+	f.op(opI64DivU)
+	f.set(d)
+	increment(nd, -1)
+	f.opU32(opBr, 0)
+	f.op(opEnd, opEnd)
+
+	f.get(nd)
+	f.set(i)
+	f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+	f.get(i)
+	f.op(opI32Eqz)
+	f.opU32(opBrIf, 1)
+	increment(i, -1)
+	f.get(i)
+	f.get(d)
+	f.i64Const(10)
+	f.op(opI64RemU)
+	f.i64Const('0')
+	f.op(opI64Add, opI32WrapI64)
+	f.memory(opI32Store8, scratchAddr)
+	f.get(d)
+	f.i64Const(10)
+	f.op(opI64DivU)
+	f.set(d)
+	f.opU32(opBr, 0)
+	f.op(opEnd, opEnd)
+
+	writeDigits := func(from func(), count func()) {
+		f.i32Const(scratchAddr)
+		from()
+		f.op(opI32Add)
+		count()
+		f.call(g.helper("write"))
+	}
+
+	f.get(exp)
+	f.i32Const(-4)
+	f.op(opI32LtS)
+	f.get(exp)
+	f.i32Const(16)
+	f.op(opI32GeS, opI32Or, opIf, blockEmpty)
+	{
+		writeDigits(func() { f.i32Const(0) }, func() { f.i32Const(1) })
+
+		f.get(nd)
+		f.i32Const(1)
+		f.op(opI32GtS, opIf, blockEmpty)
+		writeByte('.')
+		writeDigits(func() { f.i32Const(1) }, func() { f.get(nd); f.i32Const(1); f.op(opI32Sub) })
+		f.op(opEnd)
+
+		writeByte('e')
+		f.get(exp)
+		f.i32Const(0)
+		f.op(opI32LtS, opIf, blockEmpty)
+		writeByte('-')
+		f.i32Const(0)
+		f.get(exp)
+		f.op(opI32Sub)
+		f.set(exp)
+		f.op(opElse)
+		writeByte('+')
+		f.op(opEnd)
+
+		f.get(exp)
+		f.i32Const(10)
+		f.op(opI32LtS, opIf, blockEmpty)
+		writeByte('0')
+		f.op(opEnd)
+
+		f.get(exp)
+		f.op(opI64ExtendI32U)
+		f.call(g.helper("write_int"))
+	}
+	f.op(opElse)
+	{
+		f.get(exp)
+		f.i32Const(0)
+		f.op(opI32LtS, opIf, blockEmpty)
+		writeStr("0.")
+		zeros(func() { f.i32Const(-1); f.get(exp); f.op(opI32Sub) })
+		writeDigits(func() { f.i32Const(0) }, func() { f.get(nd) })
+		f.op(opElse)
+
+		f.get(nd)
+		f.i32Const(1)
+		f.op(opI32Sub)
+		f.get(exp)
+		f.op(opI32LeS, opIf, blockEmpty)
+		writeDigits(func() { f.i32Const(0) }, func() { f.get(nd) })
+		zeros(func() { f.get(exp); f.i32Const(1); f.op(opI32Add); f.get(nd); f.op(opI32Sub) })
+		writeStr(".0")
+		f.op(opElse)
+		writeDigits(func() { f.i32Const(0) }, func() { f.get(exp); f.i32Const(1); f.op(opI32Add) })
+		writeByte('.')
+		writeDigits(
+			func() { f.get(exp); f.i32Const(1); f.op(opI32Add) },
+			func() { f.get(nd); f.get(exp); f.op(opI32Sub); f.i32Const(1); f.op(opI32Sub) },
+		)
+		f.op(opEnd)
+
+		f.op(opEnd)
+	}
+	f.op(opEnd)
+}
+
+func buildWriteBool(g *generator, f *function) {
+	f.get(0)
+	f.op(opIf, byte(I64))
+	f.i64Const(g.str("true"))
+	f.op(opElse)
+	f.i64Const(g.str("false"))
+	f.op(opEnd)
+	f.call(g.helper("write_str"))
+}
+
+func buildWriteList(g *generator, f *function) {
+	const list = 0
+	i := f.local(I32)
+
+	f.i32Const('[')
+	f.call(g.helper("write_byte"))
+
+	f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+	f.get(i)
+	f.get(list)
+	f.memory(opI32Load, 0)
+	f.op(opI32GeU)
+	f.opU32(opBrIf, 1)
+
+	f.get(i)
+	f.op(opIf, blockEmpty)
+	f.i64Const(g.str(", "))
+	f.call(g.helper("write_str"))
+	f.op(opEnd)
+
+	f.get(list)
+	f.get(i)
+	f.i32Const(4)
+	f.op(opI32Shl, opI32Add)
+	f.i32Const(listHeader)
+	f.op(opI32Add)
+	f.call(g.helper("write_element"))
+
+	f.get(i)
+	f.i32Const(1)
+	f.op(opI32Add)
+	f.set(i)
+	f.opU32(opBr, 0)
+	f.op(opEnd, opEnd)
+
+	f.i32Const(']')
+	f.call(g.helper("write_byte"))
+}
+
+// dispatches on the cell tag to the matching writer
+func buildWriteCell(g *generator, f *function) {
+	writeTagged(g, f, "write_str")
+}
+
+// writes a list element, where strings are quoted
+func buildWriteElement(g *generator, f *function) {
+	writeTagged(g, f, "write_quoted")
+}
+
+func writeTagged(g *generator, f *function, strWriter string) {
+	const cell = 0
+
+	cases := []struct {
+		tag int32
+		load byte
+		writer string
+	}{
+		{tagInt, opI64Load, "write_int"},
+		{tagFloat, opF64Load, "write_float"},
+		{tagBool, opI32Load, "write_bool"},
+		{tagStr, opI64Load, strWriter},
+		{tagList, opI32Load, "write_list"},
+	}
+
+	for _, c := range cases {
+		f.get(cell)
+		f.memory(opI32Load, 0)
+		f.i32Const(c.tag)
+		f.op(opI32Eq, opIf, blockEmpty)
+		f.get(cell)
+		f.memory(c.load, cellPayload)
+		f.call(g.helper(c.writer))
+		f.op(opReturn, opEnd)
+	}
+}
+
+// writes a string in double quotes, escaping quotes, backslashes, \n, \r and \t
+func buildWriteQuoted(g *generator, f *function) {
+	const packed = 0
+	ptr := f.local(I32)
+	end := f.local(I32)
+	c := f.local(I32)
+
+	writeByte := func(b byte) {
+		f.i32Const(int32(b))
+		f.call(g.helper("write_byte"))
+	}
+
+	f.get(packed)
+	f.i64Const(32)
+	f.op(opI64ShrU, opI32WrapI64)
+	f.opU32(opLocalTee, ptr)
+	f.get(packed)
+	f.op(opI32WrapI64, opI32Add)
+	f.set(end)
+
+	writeByte('"')
+
+	f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+	f.get(ptr)
+	f.get(end)
+	f.op(opI32GeU)
+	f.opU32(opBrIf, 1)
+
+	f.get(ptr)
+	f.memory(opI32Load8U, 0)
+	f.set(c)
+
+	// every case writes its bytes and continues with the next one
+	f.op(opBlock, blockEmpty)
+	for _, escape := range []struct {
+		raw byte
+		escaped string
+	}{{'"', `\"`}, {'\\', `\\`}, {'\n', `\n`}, {'\r', `\r`}, {'\t', `\t`}} {
+		f.get(c)
+		f.i32Const(int32(escape.raw))
+		f.op(opI32Eq, opIf, blockEmpty)
+		f.i64Const(g.str(escape.escaped))
+		f.call(g.helper("write_str"))
+		f.opU32(opBr, 1)
+		f.op(opEnd)
+	}
+	f.get(c)
+	f.call(g.helper("write_byte"))
+	f.op(opEnd)
+
+	f.get(ptr)
+	f.i32Const(1)
+	f.op(opI32Add)
+	f.set(ptr)
+	f.opU32(opBr, 0)
+	f.op(opEnd, opEnd)
+
+	writeByte('"')
+}
+
+// hands the pending line to the host and starts a new one
+func buildFlush(g *generator, f *function) {
+	f.opU32(opGlobalGet, g.outPtr)
+	f.opU32(opGlobalGet, g.outLen)
+	f.call(g.print)
+
+	f.i32Const(0)
+	f.opU32(opGlobalSet, g.outLen)
+}
+
+// hands every complete line of the pending output to the host, for say
+// with a separator or ending that may hold newlines, and keeps the rest
+func buildFlushLines(g *generator, f *function) {
+	start := f.local(I32)
+	i := f.local(I32)
+
+	f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+	f.get(i)
+	f.opU32(opGlobalGet, g.outLen)
+	f.op(opI32GeU)
+	f.opU32(opBrIf, 1)
+
+	f.opU32(opGlobalGet, g.outPtr)
+	f.get(i)
+	f.op(opI32Add)
+	f.memory(opI32Load8U, 0)
+	f.i32Const('\n')
+	f.op(opI32Eq, opIf, blockEmpty)
+	f.opU32(opGlobalGet, g.outPtr)
+	f.get(start)
+	f.op(opI32Add)
+	f.get(i)
+	f.get(start)
+	f.op(opI32Sub)
+	f.call(g.print)
+	f.get(i)
+	f.i32Const(1)
+	f.op(opI32Add)
+	f.set(start)
+	f.op(opEnd)
+
+	f.get(i)
+	f.i32Const(1)
+	f.op(opI32Add)
+	f.set(i)
+	f.opU32(opBr, 0)
+	f.op(opEnd, opEnd)
+
+	// the memory regions may overlap, but memcpy copies forwards
+	f.opU32(opGlobalGet, g.outPtr)
+	f.opU32(opGlobalGet, g.outPtr)
+	f.get(start)
+	f.op(opI32Add)
+	f.opU32(opGlobalGet, g.outLen)
+	f.get(start)
+	f.op(opI32Sub)
+	f.call(g.helper("memcpy"))
+
+	f.opU32(opGlobalGet, g.outLen)
+	f.get(start)
+	f.op(opI32Sub)
+	f.opU32(opGlobalSet, g.outLen)
+}
+
+// turns what was written to the pending output since mark into a new
+// string, and removes it from the output again
+func buildCapture(g *generator, f *function) {
+	const mark = 0
+	length := f.local(I32)
+	ptr := f.local(I32)
+
+	f.opU32(opGlobalGet, g.outLen)
+	f.get(mark)
+	f.op(opI32Sub)
+	f.opU32(opLocalTee, length)
+	f.call(g.helper("alloc"))
+	f.opU32(opLocalTee, ptr)
+
+	f.opU32(opGlobalGet, g.outPtr)
+	f.get(mark)
+	f.op(opI32Add)
+	f.get(length)
+	f.call(g.helper("memcpy"))
+
+	f.get(mark)
+	f.opU32(opGlobalSet, g.outLen)
+
+	f.get(ptr)
+	f.op(opI64ExtendI32U)
+	f.i64Const(32)
+	f.op(opI64Shl)
+	f.get(length)
+	f.op(opI64ExtendI32U)
+	f.op(opI64Or)
+}
+
+// v * 10^k as a double-double: the high part is returned and the low part
+// left in a global. v is first brought into a range where the error terms of
+// the products neither overflow nor underflow, and scaled back afterwards.
+func buildScale(g *generator, f *function) {
+	const v, k = 0, 1
+	low := f.local(F64)
+	back := f.local(F64)
+
+	f.f64Const(1)
+	f.set(back)
+	for _, r := range []struct {
+		cmp byte
+		limit float64
+		factor float64
+	}{{opF64Gt, 1e300, 0x1p-100}, {opF64Lt, 1e-290, 0x1p100}} {
+		f.get(v)
+		f.f64Const(r.limit)
+		f.op(r.cmp, opIf, blockEmpty)
+		f.get(v)
+		f.f64Const(r.factor)
+		f.op(opF64Mul)
+		f.set(v)
+		f.f64Const(1 / r.factor)
+		f.set(back)
+		f.op(opEnd)
+	}
+
+	multiply := func(power func()) {
+		f.get(v)
+		f.get(low)
+		power()
+		f.call(g.helper("mul_dd"))
+		f.set(v)
+		f.opU32(opGlobalGet, g.low)
+		f.set(low)
+	}
+	positive := func(offset func()) {
+		offset()
+		f.memory(opF64Load, g.pow10Addr)
+		f.f64Const(0)
+	}
+	negative := func(offset func()) {
+		offset()
+		f.memory(opF64Load, g.pow10NegAddr)
+		offset()
+		f.memory(opF64Load, g.pow10NegAddr+8)
+	}
+
+	for _, step := range []struct {
+		done byte
+		limit int32
+		power func(func())
+		stride int32
+	}{{opI32LeS, pow10Count - 1, positive, 8}, {opI32GeS, 1 - pow10Count, negative, 16}} {
+		f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+		f.get(k)
+		f.i32Const(step.limit)
+		f.op(step.done)
+		f.opU32(opBrIf, 1)
+		multiply(func() {
+			step.power(func() { f.i32Const(step.stride * (pow10Count - 1)) })
+		})
+		f.get(k)
+		f.i32Const(step.limit)
+		f.op(opI32Sub)
+		f.set(k)
+		f.opU32(opBr, 0)
+		f.op(opEnd, opEnd)
+	}
+
+	f.get(k)
+	f.i32Const(0)
+	f.op(opI32GeS, opIf, blockEmpty)
+	multiply(func() {
+		positive(func() { f.get(k); f.i32Const(3); f.op(opI32Shl) })
+	})
+	f.op(opElse)
+	multiply(func() {
+		negative(func() { f.i32Const(0); f.get(k); f.op(opI32Sub); f.i32Const(4); f.op(opI32Shl) })
+	})
+	f.op(opEnd)
+
+	f.get(low)
+	f.get(back)
+	f.op(opF64Mul)
+	f.opU32(opGlobalSet, g.low)
+
+	f.get(v)
+	f.get(back)
+	f.op(opF64Mul)
+}
+
+// (ah + al) * (bh + bl) with the exact product of the high parts
+// from Dekker's splitting; the low part of the result goes to a global
+func buildMulDD(g *generator, f *function) {
+	const ah, al, bh, bl = 0, 1, 2, 3
+	product := f.local(F64)
+	err := f.local(F64)
+	sum := f.local(F64)
+	aHigh, aLow := f.local(F64), f.local(F64)
+	bHigh, bLow := f.local(F64), f.local(F64)
+
+	split := func(a, high, low uint32) {
+		f.get(a)
+		f.f64Const(134217729)
+		f.op(opF64Mul)
+		f.opU32(opLocalTee, high)
+		f.get(high)
+		f.get(a)
+		f.op(opF64Sub, opF64Sub)
+		f.set(high)
+		f.get(a)
+		f.get(high)
+		f.op(opF64Sub)
+		f.set(low)
+	}
+	mul := func(x, y uint32) {
+		f.get(x)
+		f.get(y)
+		f.op(opF64Mul)
+	}
+
+	mul(ah, bh)
+	f.set(product)
+	split(ah, aHigh, aLow)
+	split(bh, bHigh, bLow)
+
+	mul(aHigh, bHigh)
+	f.get(product)
+	f.op(opF64Sub)
+	mul(aHigh, bLow)
+	f.op(opF64Add)
+	mul(aLow, bHigh)
+	f.op(opF64Add)
+	mul(aLow, bLow)
+	f.op(opF64Add)
+	mul(ah, bl)
+	mul(al, bh)
+	f.op(opF64Add, opF64Add)
+	f.set(err)
+
+	f.get(product)
+	f.get(err)
+	f.op(opF64Add)
+	f.opU32(opLocalTee, sum)
+	f.get(err)
+	f.get(sum)
+	f.get(product)
+	f.op(opF64Sub, opF64Sub)
+	f.opU32(opGlobalSet, g.low)
+}
+
+func buildConcat(g *generator, f *function) {
+	const a, b = 0, 1
+	ptr := f.local(I32)
+	lenA := f.local(I32)
+	lenB := f.local(I32)
+
+	f.get(a)
+	f.op(opI32WrapI64)
+	f.set(lenA)
+	f.get(b)
+	f.op(opI32WrapI64)
+	f.set(lenB)
+
+	f.get(lenA)
+	f.get(lenB)
+	f.op(opI32Add)
+	f.call(g.helper("alloc"))
+	f.set(ptr)
+
+	f.get(ptr)
+	f.get(a)
+	f.i64Const(32)
+	f.op(opI64ShrU, opI32WrapI64)
+	f.get(lenA)
+	f.call(g.helper("memcpy"))
+
+	f.get(ptr)
+	f.get(lenA)
+	f.op(opI32Add)
+	f.get(b)
+	f.i64Const(32)
+	f.op(opI64ShrU, opI32WrapI64)
+	f.get(lenB)
+	f.call(g.helper("memcpy"))
+
+	f.get(ptr)
+	f.op(opI64ExtendI32U)
+	f.i64Const(32)
+	f.op(opI64Shl)
+	f.get(lenA)
+	f.get(lenB)
+	f.op(opI32Add, opI64ExtendI32U, opI64Or)
+}
+
+// byte-wise comparison returning -1, 0 or 1
+func buildCompare(g *generator, f *function) {
+	const a, b = 0, 1
+	ptrA := f.local(I32)
+	ptrB := f.local(I32)
+	lenA := f.local(I32)
+	lenB := f.local(I32)
+	i := f.local(I32)
+	charA := f.local(I32)
+	charB := f.local(I32)
+
+	for _, s := range []struct{ packed, ptr, length uint32 }{{a, ptrA, lenA}, {b, ptrB, lenB}} {
+		f.get(s.packed)
+		f.i64Const(32)
+		f.op(opI64ShrU, opI32WrapI64)
+		f.set(s.ptr)
+		f.get(s.packed)
+		f.op(opI32WrapI64)
+		f.set(s.length)
+	}
+
+	f.op(opBlock, blockEmpty, opLoop, blockEmpty)
+	f.get(i)
+	f.get(lenA)
+	f.op(opI32GeU)
+	f.get(i)
+	f.get(lenB)
+	f.op(opI32GeU, opI32Or)
+	f.opU32(opBrIf, 1)
+
+	for _, s := range []struct{ ptr, char uint32 }{{ptrA, charA}, {ptrB, charB}} {
+		f.get(s.ptr)
+		f.get(i)
+		f.op(opI32Add)
+		f.memory(opI32Load8U, 0)
+		f.set(s.char)
+	}
+
+	f.get(charA)
+	f.get(charB)
+	f.op(opI32Ne, opIf, blockEmpty)
+	f.i32Const(-1)
+	f.i32Const(1)
+	f.get(charA)
+	f.get(charB)
+	f.op(opI32LtU, opSelect)
+	f.op(opReturn, opEnd)
+
+	f.get(i)
+	f.i32Const(1)
+	f.op(opI32Add)
+	f.set(i)
+	f.opU32(opBr, 0)
+	f.op(opEnd, opEnd)
+
+	f.get(lenA)
+	f.get(lenB)
+	f.op(opI32GtS)
+	f.get(lenA)
+	f.get(lenB)
+	f.op(opI32LtS, opI32Sub)
+}
+
+// address of a list element, trapping when the index is out of range
+func buildCell(g *generator, f *function) {
+	const list, index = 0, 1
+
+	f.get(index)
+	f.i64Const(0)
+	f.op(opI64LtS)
+	f.get(index)
+	f.get(list)
+	f.memory(opI32Load, 0)
+	f.op(opI64ExtendI32U, opI64GeS, opI32Or, opIf, blockEmpty, opUnreachable, opEnd)
+
+	f.get(list)
+	f.get(index)
+	f.op(opI32WrapI64)
+	f.i32Const(4)
+	f.op(opI32Shl, opI32Add)
+	f.i32Const(listHeader)
+	f.op(opI32Add)
+}
+
+// the payload of a cell of the given type, trapping when its tag differs
+func buildAs(dataType types.Type) func(g *generator, f *function) {
+	return func(g *generator, f *function) {
+		const cell = 0
+
+		f.get(cell)
+		f.memory(opI32Load, 0)
+		f.i32Const(cellTags[dataType])
+		f.op(opI32Ne, opIf, blockEmpty, opUnreachable, opEnd)
+
+		f.get(cell)
+		f.memory(loads[dataType], cellPayload)
+	}
+}
+
+// stores the tag of a cell that a helper fills in
+func setTag(f *function, cell uint32, tag int32) {
+	f.get(cell)
+	f.i32Const(tag)
+	f.memory(opI32Store, 0)
+}
+
+// Applies +, - or * to two ints, trapping when the result does not fit in
+// 64 bits. A sum or difference overflowed when its sign differs from the
+// signs it should share; a product when dividing it back does not give the
+// other operand, and dividing the one wrapped product of -1 traps itself.
+func buildCheckedInt(op string) func(g *generator, f *function) {
+	return func(g *generator, f *function) {
+		const a, b = 0, 1
+		result := f.local(I64)
+
+		f.get(a)
+		f.get(b)
+		f.op(arithmetic[op][0])
+		f.set(result)
+
+		switch op {
+		case "+":
+			// (a ^ result) & (b ^ result) < 0
+			f.get(a)
+			f.get(result)
+			f.op(opI64Xor)
+			f.get(b)
+			f.get(result)
+			f.op(opI64Xor, opI64And)
+			f.i64Const(0)
+			f.op(opI64LtS)
+		case "-":
+			// (a ^ b) & (a ^ result) < 0
+			f.get(a)
+			f.get(b)
+			f.op(opI64Xor)
+			f.get(a)
+			f.get(result)
+			f.op(opI64Xor, opI64And)
+			f.i64Const(0)
+			f.op(opI64LtS)
+		case "*":
+			// a != 0 && result / a != b
+			f.get(a)
+			f.op(opI64Eqz, opIf, blockEmpty)
+			f.get(result)
+			f.op(opReturn, opEnd)
+			f.get(result)
+			f.get(a)
+			f.op(opI64DivS)
+			f.get(b)
+			f.op(opI64Ne)
+		}
+
+		f.op(opIf, blockEmpty, opUnreachable, opEnd)
+		f.get(result)
+	}
+}
+
+// negates an int or float cell into dst, trapping on any other tag
+func buildNegate(g *generator, f *function) {
+	const dst, x = 0, 1
+
+	f.get(x)
+	f.memory(opI32Load, 0)
+	f.i32Const(tagInt)
+	f.op(opI32Eq, opIf, blockEmpty)
+	setTag(f, dst, tagInt)
+	f.get(dst)
+	f.i64Const(0)
+	f.get(x)
+	f.memory(opI64Load, cellPayload)
+	f.call(g.helper("sub_int"))
+	f.memory(opI64Store, cellPayload)
+	f.op(opReturn, opEnd)
+
+	f.get(x)
+	f.memory(opI32Load, 0)
+	f.i32Const(tagFloat)
+	f.op(opI32Eq, opIf, blockEmpty)
+	setTag(f, dst, tagFloat)
+	f.get(dst)
+	f.get(x)
+	f.memory(opF64Load, cellPayload)
+	f.op(opF64Neg)
+	f.memory(opF64Store, cellPayload)
+	f.op(opReturn, opEnd)
+
+	f.op(opUnreachable)
+}
+
+// Applies an arithmetic operator to two cells of the same type into dst:
+// ints, floats, and strings for "+". Any other pair traps.
+func buildBinaryCells(op string) func(g *generator, f *function) {
+	return func(g *generator, f *function) {
+		const dst, a, b = 0, 1, 2
+
+		cases := []struct {
+			dataType types.Type
+			apply func()
+		}{
+			{types.Int, func() { g.intOp(f, op) }},
+			{types.Float, func() { f.op(arithmetic[op][1]) }},
+			{types.Str, func() { f.call(g.helper("concat")) }},
+		}
+		if op != "+" {
+			cases = cases[:2]
+		}
+
+		f.get(a)
+		f.memory(opI32Load, 0)
+		f.get(b)
+		f.memory(opI32Load, 0)
+		f.op(opI32Ne, opIf, blockEmpty, opUnreachable, opEnd)
+
+		for _, c := range cases {
+			f.get(a)
+			f.memory(opI32Load, 0)
+			f.i32Const(cellTags[c.dataType])
+			f.op(opI32Eq, opIf, blockEmpty)
+			f.get(dst)
+			f.get(a)
+			f.memory(loads[c.dataType], cellPayload)
+			f.get(b)
+			f.memory(loads[c.dataType], cellPayload)
+			c.apply()
+			f.memory(stores[c.dataType], cellPayload)
+			setTag(f, dst, cellTags[c.dataType])
+			f.op(opReturn, opEnd)
+		}
+
+		f.op(opUnreachable)
+	}
+}
+
+// Applies a comparison to two cells. Values of different types are never
+// equal, and only ints, floats and strings are ordered; comparing lists,
+// or ordering anything else, traps.
+func buildCompareCells(op string) func(g *generator, f *function) {
+	return func(g *generator, f *function) {
+		const a, b = 0, 1
+		ops := comparisons[op]
+
+		tag := func(cell uint32) {
+			f.get(cell)
+			f.memory(opI32Load, 0)
+		}
+
+		cases := []struct {
+			dataType types.Type
+			compare func()
+		}{
+			{types.Int, func() { f.op(ops[0]) }},
+			{types.Float, func() { f.op(ops[1]) }},
+			{types.Str, func() { f.call(g.helper("compare")); f.i32Const(0); f.op(ops[2]) }},
+			{types.Bool, func() { f.op(ops[2]) }},
+		}
+		equality := op == "==" || op == "!="
+		if !equality {
+			cases = cases[:3]
+		}
+
+		tag(a)
+		tag(b)
+		f.op(opI32Eq, opIf, blockEmpty)
+		for _, c := range cases {
+			tag(a)
+			f.i32Const(cellTags[c.dataType])
+			f.op(opI32Eq, opIf, blockEmpty)
+			f.get(a)
+			f.memory(loads[c.dataType], cellPayload)
+			f.get(b)
+			f.memory(loads[c.dataType], cellPayload)
+			c.compare()
+			f.op(opReturn, opEnd)
+		}
+		f.op(opEnd)
+
+		if equality {
+			// the tags differ, or are the same list tag
+			for _, cell := range []uint32{a, b} {
+				tag(cell)
+				f.i32Const(tagList)
+				f.op(opI32Eq, opIf, blockEmpty, opUnreachable, opEnd)
+			}
+
+			tag(a)
+			tag(b)
+			f.op(opI32Ne)
+			if op == "==" {
+				f.op(opI32Eqz)
+			}
+			f.op(opReturn)
+		}
+
+		f.op(opUnreachable)
+	}
+}
@@ -0,0 +1,21 @@
+package wasm
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// checks that the binary is a well-formed, type-correct module without running it
+func Validate(binary []byte) error {
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	compiled, err := r.CompileModule(ctx, binary)
+	if err != nil {
+		return err
+	}
+
+	return compiled.Close(ctx)
+}
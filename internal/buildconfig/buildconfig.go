@@ -0,0 +1,158 @@
+package buildconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ManifestName is the optional project file read from the directory of the
+// script being built
+const ManifestName = "simplescript.json"
+
+// Config holds how `build` and `wasm` invoke the Go and TinyGo toolchains
+type Config struct {
+	// the executable `build` writes and the module `wasm` writes; one
+	// manifest serves both commands, so they are separate keys
+	Output string `json:"output"`
+	WasmOutput string `json:"wasmOutput"`
+	Opt string `json:"opt"`
+	GC string `json:"gc"`
+	Scheduler string `json:"scheduler"`
+	Panic string `json:"panic"`
+	Debug bool `json:"debug"`
+	Target string `json:"target"`
+	GOOS string `json:"goos"`
+	GOARCH string `json:"goarch"`
+	// directory receiving the module with the files serving it in a browser
+	Bundle string `json:"bundle"`
+}
+
+var (
+	OptLevels = []string{"0", "1", "2", "s", "z"}
+	GCModes = []string{"leaking", "conservative", "precise", "none"}
+	Schedulers = []string{"none", "tasks", "asyncify"}
+	PanicStrategies = []string{"print", "trap"}
+	Targets = []string{"wasm", "wasi", "wasm-unknown"}
+)
+
+// TinyGo names the wasi target after the WASI version it implements
+var tinygoTargets = map[string]string{"wasm": "wasm", "wasi": "wasip1", "wasm-unknown": "wasm-unknown"}
+
+// Default is the smallest module TinyGo can produce; the leaking GC never
+// frees memory, so long-running pages should pick the conservative one
+func Default() Config {
+	return Config{Opt: "z", GC: "leaking", Scheduler: "none", Panic: "trap", Target: "wasm"}
+}
+
+// LoadManifest overlays the keys of dir's manifest on cfg; a missing
+// manifest leaves cfg unchanged, and relative paths are taken from dir
+func LoadManifest(dir string, cfg *Config) error {
+	path := filepath.Join(dir, ManifestName)
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	output, wasmOutput, bundle := cfg.Output, cfg.WasmOutput, cfg.Bundle
+	cfg.Output, cfg.WasmOutput, cfg.Bundle = "", "", ""
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	resolve := func(path, fallback string) string {
+		switch {
+		case path == "": return fallback
+		case filepath.IsAbs(path): return path
+		default: return filepath.Join(dir, path)
+		}
+	}
+	cfg.Output, cfg.WasmOutput, cfg.Bundle = resolve(cfg.Output, output), resolve(cfg.WasmOutput, wasmOutput), resolve(cfg.Bundle, bundle)
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	return nil
+}
+
+// Validate rejects options the toolchains would not understand
+func (c Config) Validate() error {
+	for _, option := range []struct {
+		name, value string
+		allowed []string
+	}{
+		{"opt", c.Opt, OptLevels},
+		{"gc", c.GC, GCModes},
+		{"scheduler", c.Scheduler, Schedulers},
+		{"panic", c.Panic, PanicStrategies},
+		{"target", c.Target, Targets},
+	} {
+		if !slices.Contains(option.allowed, option.value) {
+			return fmt.Errorf("unknown %s '%s' (expected %s)", option.name, option.value, strings.Join(option.allowed, ", "))
+		}
+	}
+
+	if (c.GOOS == "") != (c.GOARCH == "") {
+		return fmt.Errorf("goos and goarch must be set together")
+	}
+
+	if c.Bundle != "" && c.WasmOutput != "" {
+		return fmt.Errorf("wasmOutput and bundle cannot be set together, the bundle names the module itself")
+	}
+
+	if c.Bundle != "" && c.Target != "wasm" {
+		return fmt.Errorf("a browser bundle needs the wasm target, got '%s'", c.Target)
+	}
+
+	return nil
+}
+
+// TinyGoArgs are the arguments of `tinygo build` writing the module to output
+func (c Config) TinyGoArgs(output string) []string {
+	args := []string{
+		"build",
+		"-o", output,
+		"-target", tinygoTargets[c.Target],
+		"-opt", c.Opt,
+		"-panic", c.Panic,
+		"-scheduler", c.Scheduler,
+		"-gc", c.GC,
+	}
+
+	if !c.Debug {
+		args = append(args, "-no-debug")
+	}
+
+	return append(args, ".")
+}
+
+// GoEnv is the environment of `go build`, cross-compiling when GOOS and
+// GOARCH are set
+func (c Config) GoEnv() []string {
+	env := os.Environ()
+	if c.GOOS != "" {
+		env = append(env, "GOOS="+c.GOOS, "GOARCH="+c.GOARCH)
+	}
+
+	return env
+}
+
+// ExecutableName is the default output of `build` for a script stem
+func (c Config) ExecutableName(stem string) string {
+	if c.GOOS == "windows" {
+		return stem + ".exe"
+	}
+
+	return stem
+}
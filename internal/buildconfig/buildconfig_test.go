@@ -0,0 +1,102 @@
+package buildconfig
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeManifest(t *testing.T, content string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ManifestName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestDefaultMatchesSmallestModule(t *testing.T) {
+	args := Default().TinyGoArgs("out.wasm")
+	expected := []string{"build", "-o", "out.wasm", "-target", "wasm", "-opt", "z", "-panic", "trap", "-scheduler", "none", "-gc", "leaking", "-no-debug", "."}
+
+	if !slices.Equal(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := writeManifest(t, `{"output": "bin/app", "wasmOutput": "dist/app.wasm", "gc": "conservative", "debug": true, "target": "wasi"}`)
+
+	cfg := Default()
+	if err := LoadManifest(dir, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Output != filepath.Join(dir, "bin/app") || cfg.WasmOutput != filepath.Join(dir, "dist/app.wasm") {
+		t.Errorf("expected the outputs relative to the manifest, got %s and %s", cfg.Output, cfg.WasmOutput)
+	}
+
+	args := cfg.TinyGoArgs(cfg.WasmOutput)
+	if slices.Contains(args, "-no-debug") || !slices.Contains(args, "wasip1") || !slices.Contains(args, "conservative") {
+		t.Errorf("manifest keys not applied: %q", args)
+	}
+
+	// keys the manifest leaves out keep their values
+	if cfg.Opt != "z" || cfg.Scheduler != "none" {
+		t.Errorf("expected the defaults for missing keys, got %+v", cfg)
+	}
+}
+
+// the executable of build does not get in the way of the bundle of wasm
+func TestOutputAndBundle(t *testing.T) {
+	cfg := Default()
+	if err := LoadManifest(writeManifest(t, `{"output": "bin/app", "bundle": "public"}`), &cfg); err != nil {
+		t.Errorf("expected build's output and wasm's bundle to coexist, got %v", err)
+	}
+}
+
+func TestMissingManifest(t *testing.T) {
+	cfg := Default()
+	if err := LoadManifest(t.TempDir(), &cfg); err != nil || cfg != Default() {
+		t.Errorf("expected the config unchanged, got %+v (%v)", cfg, err)
+	}
+}
+
+func TestInvalidManifests(t *testing.T) {
+	for content, expected := range map[string]string{
+		`{"gc": "reference"}`: "unknown gc 'reference' (expected leaking, conservative, precise, none)",
+		`{"target": "wasm32"}`: "unknown target 'wasm32'",
+		`{"goos": "linux"}`: "goos and goarch must be set together",
+		`{"bundle": "public", "wasmOutput": "app.wasm"}`: "wasmOutput and bundle cannot be set together",
+		`{"bundle": "public", "target": "wasi"}`: "a browser bundle needs the wasm target, got 'wasi'",
+		`{"optimize": "z"}`: `unknown field "optimize"`,
+		`{"debug": "yes"}`: "cannot unmarshal string",
+	} {
+		cfg := Default()
+		err := LoadManifest(writeManifest(t, content), &cfg)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", content, expected, err)
+		}
+	}
+}
+
+func TestCrossCompilation(t *testing.T) {
+	cfg := Default()
+	if env := cfg.GoEnv(); slices.ContainsFunc(env, func(v string) bool { return v == "GOOS=windows" }) {
+		t.Errorf("expected no GOOS without cross-compilation")
+	}
+
+	cfg.GOOS, cfg.GOARCH = "windows", "amd64"
+	env := cfg.GoEnv()
+	if !slices.Contains(env, "GOOS=windows") || !slices.Contains(env, "GOARCH=amd64") {
+		t.Errorf("expected GOOS and GOARCH in the environment")
+	}
+
+	if cfg.ExecutableName("app") != "app.exe" {
+		t.Errorf("expected a .exe for windows, got %s", cfg.ExecutableName("app"))
+	}
+}
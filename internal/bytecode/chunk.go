@@ -0,0 +1,88 @@
+package bytecode
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// a source location for runtime errors
+type Position struct {
+	Line int
+	Col int
+}
+
+// a compiled program: instructions, the constants they refer to,
+// the number of local slots the VM must allocate and the functions
+type Chunk struct {
+	File string
+	Code []byte
+	Positions []Position // one entry per byte of Code
+	Constants []any // int, float64, string or bool
+	NumLocals int
+	Functions []Function
+}
+
+// a compiled function, whose code runs from Entry up to the next function's
+// entry or the end of the chunk; the script's own code comes first
+type Function struct {
+	Name string
+	Entry int
+	NumParams int
+	NumLocals int // parameters included
+}
+
+func NewChunk(file string) *Chunk {
+	return &Chunk{File: file}
+}
+
+// appends an instruction and returns its offset
+func (c *Chunk) Emit(pos Position, op Opcode, operands ...int) int {
+	offset := len(c.Code)
+	c.write(pos, byte(op))
+
+	for _, operand := range operands {
+		c.write(pos, byte(operand>>8), byte(operand))
+	}
+
+	return offset
+}
+
+func (c *Chunk) write(pos Position, bytes ...byte) {
+	for _, b := range bytes {
+		c.Code = append(c.Code, b)
+		c.Positions = append(c.Positions, pos)
+	}
+}
+
+// adds a constant, reusing an existing slot for equal values
+func (c *Chunk) AddConstant(value any) (int, error) {
+	for i, existing := range c.Constants {
+		if existing == value {
+			return i, nil
+		}
+	}
+
+	if len(c.Constants) > 0xFFFF {
+		return 0, fmt.Errorf("too many constants in one chunk")
+	}
+
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1, nil
+}
+
+// reads the operand at the given byte offset
+func (c *Chunk) Operand(offset int) int {
+	return int(binary.BigEndian.Uint16(c.Code[offset:]))
+}
+
+// overwrites the jump operand of the instruction at offset so it lands on target
+func (c *Chunk) PatchJump(offset, target int) error {
+	distance := target - (offset + OP_JUMP.Width())
+	if distance < 0 || distance > 0xFFFF {
+		return fmt.Errorf("jump too large")
+	}
+
+	binary.BigEndian.PutUint16(c.Code[offset+1:], uint16(distance))
+	return nil
+}
+
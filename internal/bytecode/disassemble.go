@@ -0,0 +1,49 @@
+package bytecode
+
+import (
+	"fmt"
+	"io"
+)
+
+// writes a human readable listing of the chunk
+func Disassemble(w io.Writer, c *Chunk) {
+	fmt.Fprintf(w, "== %s (%d locals) ==\n", c.File, c.NumLocals)
+
+	for i, constant := range c.Constants {
+		fmt.Fprintf(w, "const %d\t%#v\n", i, constant)
+	}
+
+	entries := map[int]Function{}
+	for _, fn := range c.Functions {
+		entries[fn.Entry] = fn
+	}
+
+	for offset := 0; offset < len(c.Code); {
+		if fn, ok := entries[offset]; ok {
+			fmt.Fprintf(w, "== func %s (%d params, %d locals) ==\n", fn.Name, fn.NumParams, fn.NumLocals)
+		}
+
+		op := Opcode(c.Code[offset])
+		pos := c.Positions[offset]
+		fmt.Fprintf(w, "%04d %4d:%-3d %-14s", offset, pos.Line, pos.Col, op)
+
+		switch op {
+		case OP_CONSTANT:
+			index := c.Operand(offset + 1)
+			fmt.Fprintf(w, " %d (%#v)", index, c.Constants[index])
+		case OP_JUMP, OP_JUMP_IF_FALSE:
+			fmt.Fprintf(w, " -> %04d", offset+op.Width()+c.Operand(offset+1))
+		case OP_LOOP:
+			fmt.Fprintf(w, " -> %04d", offset+op.Width()-c.Operand(offset+1))
+		case OP_CALL:
+			fmt.Fprintf(w, " %d (%s)", c.Operand(offset+1), c.Functions[c.Operand(offset+1)].Name)
+		default:
+			if op.Width() > 1 {
+				fmt.Fprintf(w, " %d", c.Operand(offset+1))
+			}
+		}
+
+		fmt.Fprintln(w)
+		offset += op.Width()
+	}
+}
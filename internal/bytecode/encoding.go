@@ -0,0 +1,309 @@
+package bytecode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Layout of a .ssc file, integers are unsigned varints unless noted:
+//
+//	magic "SSC\x00", format version (1 byte), source file name,
+//	local count, function count + (name, entry, param count, local count),
+//	constant count + constants (tag byte + payload),
+//	code length + code bytes, position run count + (length, line, col) runs
+const FormatVersion = 5
+
+var magic = []byte("SSC\x00")
+
+const (
+	tagInt byte = iota
+	tagFloat
+	tagString
+	tagBool
+)
+
+func Encode(w io.Writer, c *Chunk) error {
+	var buf bytes.Buffer
+
+	writeUvarint := func(n int) {
+		buf.Write(binary.AppendUvarint(nil, uint64(n)))
+	}
+
+	writeString := func(s string) {
+		writeUvarint(len(s))
+		buf.WriteString(s)
+	}
+
+	buf.Write(magic)
+	buf.WriteByte(FormatVersion)
+	writeString(c.File)
+	writeUvarint(c.NumLocals)
+
+	writeUvarint(len(c.Functions))
+	for _, fn := range c.Functions {
+		writeString(fn.Name)
+		writeUvarint(fn.Entry)
+		writeUvarint(fn.NumParams)
+		writeUvarint(fn.NumLocals)
+	}
+
+	writeUvarint(len(c.Constants))
+	for _, constant := range c.Constants {
+		switch v := constant.(type) {
+		case int:
+			buf.WriteByte(tagInt)
+			buf.Write(binary.AppendVarint(nil, int64(v)))
+		case float64:
+			buf.WriteByte(tagFloat)
+			buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+		case string:
+			buf.WriteByte(tagString)
+			writeString(v)
+		case bool:
+			buf.WriteByte(tagBool)
+			if v {
+				buf.WriteByte(1)
+			} else {
+				buf.WriteByte(0)
+			}
+		default:
+			return fmt.Errorf("cannot encode constant of type %T", constant)
+		}
+	}
+
+	writeUvarint(len(c.Code))
+	buf.Write(c.Code)
+
+	runs := positionRuns(c.Positions)
+	writeUvarint(len(runs))
+	for _, run := range runs {
+		writeUvarint(run.length)
+		writeUvarint(run.pos.Line)
+		writeUvarint(run.pos.Col)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func Decode(r io.Reader) (*Chunk, error) {
+	br := bufio.NewReader(r)
+	c := &Chunk{}
+
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(br, header); err != nil || !bytes.Equal(header[:len(magic)], magic) {
+		return nil, errors.New("not a SimpleScript bytecode file")
+	}
+
+	if header[len(magic)] != FormatVersion {
+		return nil, fmt.Errorf(
+			"unsupported bytecode version %d (expected %d)",
+			header[len(magic)],
+			FormatVersion,
+		)
+	}
+
+	d := decoder{r: br}
+
+	c.File = d.string()
+	c.NumLocals = d.uvarint()
+
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		c.Functions = append(c.Functions, Function{
+			Name: d.string(),
+			Entry: d.uvarint(),
+			NumParams: d.uvarint(),
+			NumLocals: d.uvarint(),
+		})
+	}
+
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		switch d.byte() {
+		case tagInt:
+			c.Constants = append(c.Constants, d.varint())
+		case tagFloat:
+			if bits := d.bytes(8); len(bits) == 8 {
+				c.Constants = append(c.Constants, math.Float64frombits(binary.BigEndian.Uint64(bits)))
+			}
+		case tagString:
+			c.Constants = append(c.Constants, d.string())
+		case tagBool:
+			c.Constants = append(c.Constants, d.byte() == 1)
+		default:
+			d.fail(errors.New("unknown constant tag"))
+		}
+	}
+
+	c.Code = d.bytes(d.uvarint())
+
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		length := d.uvarint()
+		pos := Position{Line: d.uvarint(), Col: d.uvarint()}
+
+		for i := 0; i < length && len(c.Positions) < len(c.Code); i++ {
+			c.Positions = append(c.Positions, pos)
+		}
+	}
+
+	if d.err != nil {
+		return nil, fmt.Errorf("corrupt bytecode file: %w", d.err)
+	}
+
+	if err := c.Verify(); err != nil {
+		return nil, fmt.Errorf("corrupt bytecode file: %w", err)
+	}
+
+	return c, nil
+}
+
+// checks that every instruction is well formed, so the VM can trust the chunk
+func (c *Chunk) Verify() error {
+	if len(c.Positions) != len(c.Code) {
+		return errors.New("position table does not match code length")
+	}
+
+	// the script's code and each function's, which jumps cannot leave
+	type region struct{ start, end, locals int }
+	regions := []region{{0, len(c.Code), c.NumLocals}}
+
+	for i, fn := range c.Functions {
+		prev := &regions[len(regions)-1]
+		if fn.Entry <= prev.start || fn.Entry >= len(c.Code) || fn.NumParams > fn.NumLocals {
+			return fmt.Errorf("invalid function %d (%s)", i, fn.Name)
+		}
+
+		prev.end = fn.Entry
+		regions = append(regions, region{fn.Entry, len(c.Code), fn.NumLocals})
+	}
+
+	for r, region := range regions {
+		for offset := region.start; offset < region.end; {
+			op := Opcode(c.Code[offset])
+			if _, ok := opcodes[op]; !ok {
+				return fmt.Errorf("unknown opcode %d at %04d", op, offset)
+			}
+
+			next := offset + op.Width()
+			if next > region.end {
+				return fmt.Errorf("truncated %s at %04d", op, offset)
+			}
+
+			switch op {
+			case OP_CONSTANT:
+				if c.Operand(offset+1) >= len(c.Constants) {
+					return fmt.Errorf("constant index out of range at %04d", offset)
+				}
+			case OP_GET_LOCAL, OP_SET_LOCAL:
+				if c.Operand(offset+1) >= region.locals {
+					return fmt.Errorf("local slot out of range at %04d", offset)
+				}
+			case OP_JUMP, OP_JUMP_IF_FALSE:
+				if next+c.Operand(offset+1) > region.end {
+					return fmt.Errorf("jump out of range at %04d", offset)
+				}
+			case OP_LOOP:
+				if next-c.Operand(offset+1) < region.start {
+					return fmt.Errorf("loop out of range at %04d", offset)
+				}
+			case OP_CALL:
+				if c.Operand(offset+1) >= len(c.Functions) {
+					return fmt.Errorf("function index out of range at %04d", offset)
+				}
+			case OP_RETURN:
+				if r == 0 {
+					return fmt.Errorf("return outside of a function at %04d", offset)
+				}
+			}
+
+			offset = next
+		}
+	}
+
+	return nil
+}
+
+type positionRun struct {
+	length int
+	pos Position
+}
+
+func positionRuns(positions []Position) []positionRun {
+	runs := []positionRun{}
+
+	for _, pos := range positions {
+		if len(runs) > 0 && runs[len(runs)-1].pos == pos {
+			runs[len(runs)-1].length++
+		} else {
+			runs = append(runs, positionRun{length: 1, pos: pos})
+		}
+	}
+
+	return runs
+}
+
+// reads primitive values, remembering the first error so decoding code stays linear
+type decoder struct {
+	r *bufio.Reader
+	err error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) uvarint() int {
+	if d.err != nil {
+		return 0
+	}
+
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil || n > math.MaxInt32 {
+		d.fail(errors.New("invalid length"))
+		return 0
+	}
+
+	return int(n)
+}
+
+func (d *decoder) varint() int {
+	if d.err != nil {
+		return 0
+	}
+
+	n, err := binary.ReadVarint(d.r)
+	d.fail(err)
+	return int(n)
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+
+	b, err := d.r.ReadByte()
+	d.fail(err)
+	return b
+}
+
+// copies instead of preallocating, so a corrupt length cannot force a huge allocation
+func (d *decoder) bytes(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	_, err := io.CopyN(&buf, d.r, int64(n))
+	d.fail(err)
+	return buf.Bytes()
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes(d.uvarint()))
+}
@@ -0,0 +1,81 @@
+package bytecode
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func sampleChunk() *Chunk {
+	c := NewChunk("sample.ss")
+	c.NumLocals = 1
+	pos := Position{Line: 1, Col: 1}
+
+	for _, constant := range []any{42, 2.5, "hi", true} {
+		index, _ := c.AddConstant(constant)
+		c.Emit(pos, OP_CONSTANT, index)
+	}
+
+	c.Emit(Position{Line: 2, Col: 3}, OP_SAY, 4)
+	c.Emit(Position{Line: 3, Col: 1}, OP_CALL, 0)
+	c.Emit(Position{Line: 3, Col: 1}, OP_HALT)
+
+	// func id(x: int) int { return x }
+	c.Functions = append(c.Functions, Function{Name: "id", Entry: len(c.Code), NumParams: 1, NumLocals: 1})
+	c.Emit(Position{Line: 4, Col: 23}, OP_GET_LOCAL, 0)
+	c.Emit(Position{Line: 4, Col: 23}, OP_RETURN)
+	return c
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	original := sampleChunk()
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, original); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(original, decoded) {
+		t.Errorf("round trip changed the chunk.\nExpected: %+v\nGot: %+v", original, decoded)
+	}
+}
+
+func TestDecodeRejectsInvalidFiles(t *testing.T) {
+	var buf bytes.Buffer
+	Encode(&buf, sampleChunk())
+	valid := buf.Bytes()
+
+	wrongVersion := append([]byte{}, valid...)
+	wrongVersion[4] = FormatVersion + 1
+
+	badConstant := sampleChunk()
+	badConstant.Code[2] = 9
+
+	var badBuf bytes.Buffer
+	Encode(&badBuf, badConstant)
+
+	badFunction := sampleChunk()
+	badFunction.Code[len(badFunction.Code)-6] = 1
+
+	var badFunctionBuf bytes.Buffer
+	Encode(&badFunctionBuf, badFunction)
+
+	inputs := map[string][]byte{
+		"not bytecode": []byte("var x: int = 1"),
+		"wrong version": wrongVersion,
+		"truncated": valid[:len(valid)-4],
+		"bad constant index": badBuf.Bytes(),
+		"bad function index": badFunctionBuf.Bytes(),
+	}
+
+	for name, input := range inputs {
+		if _, err := Decode(bytes.NewReader(input)); err == nil {
+			t.Errorf("%s: expected decode error", name)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package bytecode
+
+type Opcode byte
+
+// Operands are big-endian uint16 values following the opcode byte.
+const (
+	OP_CONSTANT Opcode = iota // index: push Constants[index]
+	OP_GET_LOCAL // slot: push the value of a local
+	OP_SET_LOCAL // slot: pop a value into a local
+	OP_LIST // count: pop count values and push them as a list
+	OP_INDEX // pop index and list, push list[index]
+	OP_SET_INDEX // pop value, index and list, then store list[index] = value
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_NEGATE
+	OP_EQUAL
+	OP_NOT_EQUAL
+	OP_LESS
+	OP_LESS_EQUAL
+	OP_GREATER
+	OP_GREATER_EQUAL
+	OP_SAY // count: pop count values and print them separated by spaces, then a newline
+	OP_DISPLAY // pop a value and push its display text
+	OP_PRINT // pop a string and print it as is
+	OP_ARGS // push the command-line arguments as a list of strings
+	OP_ENV // pop a name and push its environment variable, or ""
+	OP_CHECK // type: fail unless the value on top has the type CheckTypes[type]
+	OP_JUMP // offset: move forward unconditionally
+	OP_JUMP_IF_FALSE // offset: pop a condition and move forward when false
+	OP_LOOP // offset: move backward unconditionally
+	OP_CALL // function: pop its arguments into the first locals of a new frame and run Functions[function]
+	OP_RETURN // leave the current function; the result it pushed, if any, stays on the stack
+	OP_HALT
+)
+
+// the types OP_CHECK checks a list element used as a static type against
+var CheckTypes = []string{"int", "float", "bool", "str", "list"}
+
+type opcodeInfo struct {
+	name string
+	operands int
+}
+
+var opcodes = map[Opcode]opcodeInfo{
+	OP_CONSTANT: {"CONSTANT", 1},
+	OP_GET_LOCAL: {"GET_LOCAL", 1},
+	OP_SET_LOCAL: {"SET_LOCAL", 1},
+	OP_LIST: {"LIST", 1},
+	OP_INDEX: {"INDEX", 0},
+	OP_SET_INDEX: {"SET_INDEX", 0},
+	OP_ADD: {"ADD", 0},
+	OP_SUBTRACT: {"SUBTRACT", 0},
+	OP_MULTIPLY: {"MULTIPLY", 0},
+	OP_DIVIDE: {"DIVIDE", 0},
+	OP_NEGATE: {"NEGATE", 0},
+	OP_EQUAL: {"EQUAL", 0},
+	OP_NOT_EQUAL: {"NOT_EQUAL", 0},
+	OP_LESS: {"LESS", 0},
+	OP_LESS_EQUAL: {"LESS_EQUAL", 0},
+	OP_GREATER: {"GREATER", 0},
+	OP_GREATER_EQUAL: {"GREATER_EQUAL", 0},
+	OP_SAY: {"SAY", 1},
+	OP_DISPLAY: {"DISPLAY", 0},
+	OP_PRINT: {"PRINT", 0},
+	OP_ARGS: {"ARGS", 0},
+	OP_ENV: {"ENV", 0},
+	OP_CHECK: {"CHECK", 1},
+	OP_JUMP: {"JUMP", 1},
+	OP_JUMP_IF_FALSE: {"JUMP_IF_FALSE", 1},
+	OP_LOOP: {"LOOP", 1},
+	OP_CALL: {"CALL", 1},
+	OP_RETURN: {"RETURN", 0},
+	OP_HALT: {"HALT", 0},
+}
+
+func (op Opcode) String() string {
+	if info, ok := opcodes[op]; ok {
+		return info.name
+	}
+
+	return "UNKNOWN"
+}
+
+// the encoded size of the instruction, opcode included
+func (op Opcode) Width() int {
+	return 1 + 2*opcodes[op].operands
+}
+
+// maps infix operators to the instruction implementing them
+var BinaryOpcodes = map[string]Opcode{
+	"+": OP_ADD,
+	"-": OP_SUBTRACT,
+	"*": OP_MULTIPLY,
+	"/": OP_DIVIDE,
+	"==": OP_EQUAL,
+	"!=": OP_NOT_EQUAL,
+	"<": OP_LESS,
+	"<=": OP_LESS_EQUAL,
+	">": OP_GREATER,
+	">=": OP_GREATER_EQUAL,
+}
@@ -0,0 +1,205 @@
+// Package devserver serves a browser bundle while it is being worked on:
+// it rebuilds the bundle when asked, tells the open pages to reload once a
+// build succeeds, and shows the diagnostics of a failed build over the page.
+package devserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// EventsPath is where pages listen for build events
+const EventsPath = "/_simplescript/events"
+
+// A Builder writes a complete bundle to the empty directory dir. A failed
+// build returns the text to show in the pages, like compiler diagnostics.
+type Builder func(dir string) error
+
+type event struct {
+	name string
+	data string
+}
+
+// Serves the bundle of the last successful build and streams its events
+type Server struct {
+	root string
+	build Builder
+	building sync.Mutex // one build at a time, so an older one never wins
+
+	mu sync.Mutex
+	builds int
+	current string // directory of the last successful build, "" before it
+	failure string // text of the last build when it failed
+	clients map[chan event]bool
+}
+
+// builds are written to fresh directories inside root, so pages never see
+// a half-written bundle
+func New(root string, build Builder) *Server {
+	return &Server{root: root, build: build, clients: map[chan event]bool{}}
+}
+
+// Rebuild runs the builder and tells the pages to reload, or shows them
+// the failure while the previous bundle stays served
+func (s *Server) Rebuild() error {
+	s.building.Lock()
+	defer s.building.Unlock()
+
+	s.mu.Lock()
+	s.builds++
+	dir := filepath.Join(s.root, fmt.Sprintf("build-%d", s.builds))
+	s.mu.Unlock()
+
+	err := os.MkdirAll(dir, 0755)
+	if err == nil {
+		err = s.build(dir)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		os.RemoveAll(dir)
+		s.failure = err.Error()
+		s.broadcast(event{"diagnostics", s.failure})
+		return err
+	}
+
+	if s.current != "" {
+		os.RemoveAll(s.current)
+	}
+	s.current, s.failure = dir, ""
+	s.broadcast(event{"reload", ""})
+	return nil
+}
+
+// slow pages miss events rather than hold up a build
+func (s *Server) broadcast(ev event) {
+	for client := range s.clients {
+		select {
+		case client <- ev:
+		default:
+		}
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// every request gets the latest build
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch name := path.Clean("/" + r.URL.Path); name {
+	case EventsPath: s.serveEvents(w, r)
+	case "/", "/index.html": s.serveIndex(w)
+	default: s.serveFile(w, r, name)
+	}
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, name string) {
+	s.mu.Lock()
+	dir := s.current
+	s.mu.Unlock()
+
+	if dir == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// set before ServeFile, which would otherwise guess from the system's tables
+	switch filepath.Ext(name) {
+	case ".wasm": w.Header().Set("Content-Type", "application/wasm")
+	case ".js": w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	}
+
+	http.ServeFile(w, r, filepath.Join(dir, filepath.FromSlash(name)))
+}
+
+// index.html with the live reload client; before the first successful
+// build, an empty page that can still show the diagnostics
+func (s *Server) serveIndex(w http.ResponseWriter) {
+	s.mu.Lock()
+	dir := s.current
+	s.mu.Unlock()
+
+	page := "<!DOCTYPE html>\n<html>\n<body>\n</body>\n</html>\n"
+	if dir != "" {
+		if index, err := os.ReadFile(filepath.Join(dir, "index.html")); err == nil {
+			page = string(index)
+		}
+	}
+
+	if i := strings.LastIndex(page, "</body>"); i >= 0 {
+		page = page[:i] + client + page[i:]
+	} else {
+		page += client
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, page)
+}
+
+// Server-sent events: "reload" after a successful build and "diagnostics"
+// with the JSON-encoded failure text, sent at once to a page opened while
+// the build is broken
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events := make(chan event, 4)
+
+	s.mu.Lock()
+	s.clients[events] = true
+	if s.failure != "" {
+		events <- event{"diagnostics", s.failure}
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, events)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-events:
+			data, _ := json.Marshal(ev.data)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// injected before </body> of index.html
+const client = `<script>
+(() => {
+  const events = new EventSource("` + EventsPath + `");
+  let overlay;
+
+  events.addEventListener("reload", () => location.reload());
+  events.addEventListener("diagnostics", (e) => {
+    if (!overlay) {
+      overlay = document.createElement("pre");
+      overlay.style.cssText = "position:fixed;inset:0;margin:0;padding:1em;overflow:auto;" +
+        "background:rgba(24,24,24,0.94);color:#ff6b6b;font:14px/1.4 monospace;white-space:pre-wrap;z-index:2147483647";
+      document.body.appendChild(overlay);
+    }
+    overlay.textContent = JSON.parse(e.data);
+  });
+})();
+</script>
+`
@@ -0,0 +1,185 @@
+package devserver
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// a builder writing version into the bundle, or failing with failure
+type fakeBuild struct {
+	version string
+	failure string
+}
+
+func (b *fakeBuild) build(dir string) error {
+	if b.failure != "" {
+		return errors.New(b.failure)
+	}
+
+	files := map[string]string{
+		"index.html": "<html><body><p>" + b.version + "</p></body></html>",
+		"app.wasm": "\x00asm" + b.version,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func get(t *testing.T, url string) (*http.Response, string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+// reads server-sent events until one arrives, returning its name and data
+func nextEvent(t *testing.T, events *bufio.Reader) (string, string) {
+	t.Helper()
+
+	var name, data string
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		switch {
+		case strings.HasPrefix(line, "event: "): name = strings.TrimSpace(line[7:])
+		case strings.HasPrefix(line, "data: "): data = strings.TrimSpace(line[6:])
+		case line == "\n" && name != "": return name, data
+		}
+	}
+}
+
+func TestServesTheLastSuccessfulBuild(t *testing.T) {
+	builder := &fakeBuild{version: "one"}
+	server := New(t.TempDir(), builder.build)
+	if err := server.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	resp, index := get(t, ts.URL+"/")
+	if !strings.Contains(index, "<p>one</p><script>") || !strings.Contains(index, EventsPath) {
+		t.Errorf("expected the page with the reload client before </body>, got:\n%s", index)
+	}
+	if resp.Header.Get("Cache-Control") != "no-store" {
+		t.Errorf("expected the page not to be cached")
+	}
+
+	resp, module := get(t, ts.URL+"/app.wasm")
+	if resp.Header.Get("Content-Type") != "application/wasm" || module != "\x00asmone" {
+		t.Errorf("expected the module as application/wasm, got %q as %s", module, resp.Header.Get("Content-Type"))
+	}
+
+	builder.failure = "main.ss:1:1: [SyntaxError] unexpected '}'"
+	if err := server.Rebuild(); err == nil {
+		t.Fatal("expected the build to fail")
+	}
+
+	if _, module := get(t, ts.URL+"/app.wasm"); module != "\x00asmone" {
+		t.Errorf("expected the previous module while the build is broken, got %q", module)
+	}
+
+	if resp, _ := get(t, ts.URL+"/missing.js"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing file, got %d", resp.StatusCode)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	builder := &fakeBuild{failure: "main.ss:2:5: [NameError] undefined variable 'x'"}
+	server := New(t.TempDir(), builder.build)
+	server.Rebuild()
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	// a page opened before the first successful build can still show the failure
+	if _, index := get(t, ts.URL+"/"); !strings.Contains(index, EventsPath) {
+		t.Errorf("expected the reload client in the placeholder page, got:\n%s", index)
+	}
+
+	resp, err := http.Get(ts.URL + EventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("expected an event stream, got %s", resp.Header.Get("Content-Type"))
+	}
+
+	events := bufio.NewReader(resp.Body)
+	if name, data := nextEvent(t, events); name != "diagnostics" || data != `"main.ss:2:5: [NameError] undefined variable 'x'"` {
+		t.Errorf("expected the current failure on connecting, got %s %s", name, data)
+	}
+
+	builder.failure, builder.version = "", "two"
+	if err := server.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, _ := nextEvent(t, events); name != "reload" {
+		t.Errorf("expected a reload after the fix, got %s", name)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "main.ss")
+	os.WriteFile(source, []byte("say(1)"), 0644)
+
+	w := newWatcher(dir, []string{"*.ss"})
+
+	// polls once for each expected result
+	expect := func(what string, changes ...bool) {
+		t.Helper()
+
+		for n, changed := range changes {
+			if got := w.poll(); got != changed {
+				t.Errorf("%s: expected poll %d to report %v, got %v", what, n+1, changed, got)
+			}
+		}
+	}
+
+	os.WriteFile(source, []byte("say(1)"), 0644)
+	expect("saving the same contents", false, false)
+
+	// a change takes two polls to be reported, and is reported once
+	os.WriteFile(source, []byte("say(2)"), 0644)
+	expect("editing a file", false, true, false)
+
+	// what editors do when saving: the empty file is seen by one poll
+	os.WriteFile(source, nil, 0644)
+	expect("truncating a file", false)
+	os.WriteFile(source, []byte("say(3)"), 0644)
+	expect("rewriting the truncated file", false, true, false)
+
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644)
+	expect("a file not matching the patterns", false, false)
+
+	os.WriteFile(filepath.Join(dir, "other.ss"), []byte("say(4)"), 0644)
+	expect("a new file", false, true)
+
+	os.Remove(source)
+	expect("a removed file", false, true)
+}
@@ -0,0 +1,87 @@
+package devserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Watch calls changed whenever the files in dir matching patterns change,
+// appear or disappear, polling every interval until ctx is done. Only
+// contents count, so saving a file without editing it rebuilds nothing.
+// A change is only reported once two polls in a row agree on it, so an
+// editor truncating a file before writing it back causes one call, not one
+// for the empty file and another for its new contents.
+func Watch(ctx context.Context, dir string, patterns []string, interval time.Duration, changed func()) {
+	w := newWatcher(dir, patterns)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if w.poll() {
+				changed()
+			}
+		}
+	}
+}
+
+// what Watch remembers between polls
+type watcher struct {
+	dir string
+	patterns []string
+	last [sha256.Size]byte // what a change was last reported for
+	previous [sha256.Size]byte // what the previous poll saw
+}
+
+func newWatcher(dir string, patterns []string) *watcher {
+	sum := fingerprint(dir, patterns)
+	return &watcher{dir: dir, patterns: patterns, last: sum, previous: sum}
+}
+
+// reads the files once and reports whether they changed since the last
+// reported change, with the previous poll seeing the same contents
+func (w *watcher) poll() bool {
+	current := fingerprint(w.dir, w.patterns)
+	changed := current == w.previous && current != w.last
+	if changed {
+		w.last = current
+	}
+	w.previous = current
+
+	return changed
+}
+
+// a hash of the names and contents of the matching files
+func fingerprint(dir string, patterns []string) [sha256.Size]byte {
+	names := []string{}
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		names = append(names, matches...)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+
+		hash.Write([]byte(name))
+		hash.Write([]byte{0})
+		hash.Write(content)
+		hash.Write([]byte{0})
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
@@ -0,0 +1,113 @@
+package diagnostic
+
+import (
+	"fmt"
+
+	"simplescript/internal/ast"
+)
+
+type Level int
+
+const (
+	Note Level = iota
+	Warning
+	Error
+	Fatal
+)
+
+func (l Level) String() string {
+	switch l {
+	case Note: return "note"
+	case Warning: return "warning"
+	case Error: return "error"
+	case Fatal: return "fatal"
+	default: return "unknown"
+	}
+}
+
+type Code int
+
+const (
+	SyntaxError Code = iota
+	TypeError
+	NameError
+	LinkerError
+	InternalError
+)
+
+func (c Code) String() string {
+	switch c {
+	case SyntaxError: return "SyntaxError"
+	case TypeError: return "TypeError"
+	case NameError: return "NameError"
+	case LinkerError: return "LinkerError"
+	case InternalError: return "InternalError"
+	default: return "UnknownError"
+	}
+}
+
+type Position struct {
+	Line int
+	Col int
+	Offset int
+}
+
+type Range struct {
+	Start Position
+	End Position
+}
+
+// a single problem found while compiling a SimpleScript file
+type Diagnostic struct {
+	Level Level
+	Code Code
+	File string
+	Range Range
+	Message string
+	Hints []string
+}
+
+// creates an error diagnostic covering the given source span
+func Errorf(code Code, span ast.Span, format string, args ...any) Diagnostic {
+	return Diagnostic{
+		Level: Error,
+		Code: code,
+		File: span.File,
+		Range: SpanRange(span),
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// returns a copy of the diagnostic with an extra hint attached
+func (d Diagnostic) WithHint(format string, args ...any) Diagnostic {
+	d.Hints = append(append([]string{}, d.Hints...), fmt.Sprintf(format, args...))
+	return d
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf(
+		"%s:%d:%d: [%s] %s",
+		d.File,
+		d.Range.Start.Line,
+		d.Range.Start.Col,
+		d.Code,
+		d.Message,
+	)
+}
+
+func SpanRange(span ast.Span) Range {
+	return Range{
+		Start: Position{Line: span.Start.Line, Col: span.Start.Col, Offset: span.Start.Offset},
+		End: Position{Line: span.End.Line, Col: span.End.Col, Offset: span.End.Offset},
+	}
+}
+
+func HasErrors(diags []Diagnostic) bool {
+	for _, d := range diags {
+		if d.Level >= Error {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,123 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type Format int
+
+const (
+	Text Format = iota
+	JSON
+	SARIF
+)
+
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "text", "": return Text, nil
+	case "json": return JSON, nil
+	case "sarif": return SARIF, nil
+	default: return Text, fmt.Errorf("unknown diagnostics format '%s' (expected text, json or sarif)", name)
+	}
+}
+
+// writes the diagnostics in the requested format
+func Write(w io.Writer, format Format, diags []Diagnostic, color bool) error {
+	switch format {
+	case JSON: return WriteJSON(w, diags)
+	case SARIF: return WriteSARIF(w, diags)
+	default: return WriteText(w, diags, color)
+	}
+}
+
+// prints human readable diagnostics, optionally with ANSI colors
+func WriteText(w io.Writer, diags []Diagnostic, color bool) error {
+	paint := func(code, text string) string {
+		if !color {
+			return text
+		}
+		return code + text + "\x1b[0m"
+	}
+
+	for _, d := range diags {
+		levelColor := "\x1b[31m" // Red
+		switch d.Level {
+		case Warning:
+			levelColor = "\x1b[33m" // Yellow
+		case Note:
+			levelColor = "\x1b[36m" // Cyan
+		}
+
+		_, err := fmt.Fprintf(
+			w,
+			"%s:%d:%d: %s %s\n",
+			d.File,
+			d.Range.Start.Line,
+			d.Range.Start.Col,
+			paint(levelColor, "["+d.Code.String()+"]"),
+			d.Message,
+		)
+		if err != nil {
+			return err
+		}
+
+		for _, hint := range d.Hints {
+			if _, err := fmt.Fprintf(w, "  └─ %s\n", paint("\x1b[32m", "Hint: "+hint)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type jsonPosition struct {
+	Line int `json:"line"`
+	Col int `json:"col"`
+	Offset int `json:"offset"`
+}
+
+type jsonRange struct {
+	Start jsonPosition `json:"start"`
+	End jsonPosition `json:"end"`
+}
+
+type jsonDiagnostic struct {
+	File string `json:"file"`
+	Range jsonRange `json:"range"`
+	Level string `json:"level"`
+	Code string `json:"code"`
+	Message string `json:"message"`
+	Hints []string `json:"hints"`
+}
+
+// writes one JSON object per line for each diagnostic
+func WriteJSON(w io.Writer, diags []Diagnostic) error {
+	enc := json.NewEncoder(w)
+
+	for _, d := range diags {
+		hints := d.Hints
+		if hints == nil {
+			hints = []string{}
+		}
+
+		err := enc.Encode(jsonDiagnostic{
+			File: d.File,
+			Range: jsonRange{
+				Start: jsonPosition(d.Range.Start),
+				End: jsonPosition(d.Range.End),
+			},
+			Level: d.Level.String(),
+			Code: d.Code.String(),
+			Message: d.Message,
+			Hints: hints,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package diagnostic
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"simplescript/internal/ast"
+)
+
+func sampleDiagnostics() []Diagnostic {
+	span := ast.Span{
+		File: "main.ss",
+		Start: ast.Position{Offset: 20, Line: 3, Col: 5},
+		End: ast.Position{Offset: 25, Line: 3, Col: 10},
+	}
+	d := Errorf(NameError, span, "undefined variable '%s'", "total").WithHint("declare it first")
+	return []Diagnostic{d}
+}
+
+func TestWriteText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteText(&buf, sampleDiagnostics(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "main.ss:3:5: [NameError] undefined variable 'total'\n  └─ Hint: declare it first\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected text output.\nExpected: %q\nGot: %q", expected, buf.String())
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, sampleDiagnostics()); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected 1 JSON line, got=%d", len(lines))
+	}
+
+	var got jsonDiagnostic
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Code != "NameError" || got.File != "main.ss" || got.Range.End.Col != 10 {
+		t.Errorf("unexpected JSON diagnostic: %+v", got)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, sampleDiagnostics()); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("unexpected SARIF log: %+v", log)
+	}
+
+	result := log.Runs[0].Results[0]
+	if result.RuleID != "NameError" || result.Locations[0].PhysicalLocation.Region.StartLine != 3 {
+		t.Errorf("unexpected SARIF result: %+v", result)
+	}
+}
+
+func TestWriteEmptySARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `"results": []`) {
+		t.Errorf("expected an empty results array, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,141 @@
+package diagnostic
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	toolURI = "https://github.com/VictorFrancelino/simplescript"
+)
+
+// ToolVersion is reported as the driver version in SARIF logs
+var ToolVersion = "dev"
+
+type sarifLog struct {
+	Schema string `json:"$schema"`
+	Version string `json:"version"`
+	Runs []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool sarifTool `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+	Version string `json:"version"`
+	InformationURI string `json:"informationUri"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID string `json:"ruleId"`
+	Level string `json:"level"`
+	Message sarifMessage `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+	Properties *sarifProperties `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifact `json:"artifactLocation"`
+	Region *sarifRegion `json:"region,omitempty"`
+}
+
+type sarifArtifact struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndLine int `json:"endLine"`
+	EndColumn int `json:"endColumn"`
+}
+
+type sarifProperties struct {
+	Hints []string `json:"hints"`
+}
+
+// writes all diagnostics as a single SARIF 2.1.0 log
+func WriteSARIF(w io.Writer, diags []Diagnostic) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name: "simplescript",
+			Version: ToolVersion,
+			InformationURI: toolURI,
+			Rules: []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+
+	seenRules := map[Code]bool{}
+
+	for _, d := range diags {
+		if !seenRules[d.Code] {
+			seenRules[d.Code] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: d.Code.String()})
+		}
+
+		location := sarifPhysicalLocation{ArtifactLocation: sarifArtifact{URI: d.File}}
+
+		// SARIF regions are 1-based, so diagnostics without a position only name the file
+		if d.Range.Start.Line > 0 {
+			location.Region = &sarifRegion{
+				StartLine: d.Range.Start.Line,
+				StartColumn: d.Range.Start.Col,
+				EndLine: d.Range.End.Line,
+				EndColumn: d.Range.End.Col,
+			}
+		}
+
+		result := sarifResult{
+			RuleID: d.Code.String(),
+			Level: sarifLevel(d.Level),
+			Message: sarifMessage{Text: d.Message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		}
+
+		if len(d.Hints) > 0 {
+			result.Properties = &sarifProperties{Hints: d.Hints}
+		}
+
+		run.Results = append(run.Results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(sarifLog{
+		Schema: sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{run},
+	})
+}
+
+func sarifLevel(level Level) string {
+	switch level {
+	case Note: return "note"
+	case Warning: return "warning"
+	default: return "error"
+	}
+}
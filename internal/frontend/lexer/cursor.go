@@ -1,15 +1,33 @@
 package lexer
 
-import "simplescript/internal/ast"
-
-// creates a populated ast.Token structure
-func (l *Lexer) newToken(
-	tag ast.TokenType,
-	slice string,
-	line,
-	col int,
-) ast.Token {
-	return ast.Token{ Tag: tag, Slice: slice, Line: line, Col: col, }
+import (
+	"simplescript/internal/ast"
+	"simplescript/internal/diagnostic"
+)
+
+// creates a populated ast.Token structure ending at the cursor
+func (l *Lexer) newToken(tag ast.TokenType, slice string, start ast.Position) ast.Token {
+	return ast.Token{
+		Tag: tag,
+		Slice: slice,
+		File: l.file,
+		Line: start.Line,
+		Col: start.Col,
+		Offset: start.Offset,
+		End: l.position(),
+	}
+}
+
+// creates an invalid token and records why it could not be scanned
+func (l *Lexer) invalidToken(slice, msg string, start ast.Position) ast.Token {
+	tok := l.newToken(ast.TOKEN_INVALID, slice, start)
+	l.errors = append(l.errors, diagnostic.Errorf(diagnostic.SyntaxError, tok.Span(), "%s", msg))
+	return tok
+}
+
+// the current cursor location
+func (l *Lexer) position() ast.Position {
+	return ast.Position{Offset: l.pos, Line: l.line, Col: l.col}
 }
 
 // it consumes the current character and updates the row and column coordinates
@@ -1,18 +1,18 @@
 package lexer
 
 import (
-	"fmt"
-	"os"
-
 	"simplescript/internal/ast"
+	"simplescript/internal/diagnostic"
 )
 
 // it transforms the source code into a sequence of logical units (Tokens)
 type Lexer struct {
+	file string
 	buffer string
 	pos int
 	line int
 	col int
+	errors []diagnostic.Diagnostic
 }
 
 // initializes Lexer with the source code.
@@ -20,31 +20,25 @@ func NewLexer(buffer string) *Lexer {
 	return &Lexer{ buffer: buffer, line: 1, col: 1, }
 }
 
-func MustTokenize(source string) []ast.Token {
-	l := NewLexer(source)
-	tokens := l.tokenize()
-
-	for _, token := range tokens {
-		if token.Tag == ast.TOKEN_INVALID {
-			fmt.Printf(
-				"Lexical Error [%d:%d]: invalid token '%s'\n",
-				token.Line,
-				token.Col,
-				token.Slice,
-			)
-			os.Exit(1)
-		}
-	}
+// initializes Lexer with source code read from the named file,
+// so every token knows where it came from
+func NewFileLexer(file, buffer string) *Lexer {
+	l := NewLexer(buffer)
+	l.file = file
+	return l
+}
 
-	return tokens
+// consumes and returns the next token, repeating EOF once the buffer ends
+func (l *Lexer) Next() ast.Token {
+	return l.scanToken()
 }
 
 // reads the entire buffer and returns all tokens at once
-func (l *Lexer) tokenize() []ast.Token {
+func (l *Lexer) Tokenize() []ast.Token {
 	var tokens []ast.Token
 
 	for {
-		tok := l.scanToken()
+		tok := l.Next()
 		tokens = append(tokens, tok)
 
 		if tok.Tag == ast.TOKEN_EOF {
@@ -55,94 +49,98 @@ func (l *Lexer) tokenize() []ast.Token {
 	return tokens
 }
 
+// lexical errors found so far, one for each invalid token
+func (l *Lexer) Errors() []diagnostic.Diagnostic {
+	return l.errors
+}
+
 // master function that identifies which token is next in the buffer
 func (l *Lexer) scanToken() ast.Token {
 	l.skipWhitespace()
 
-	startLine := l.line
-	startCol := l.col
-	startPos := l.pos
+	start := l.position()
 
 	if l.pos >= len(l.buffer) {
-		return l.newToken(ast.TOKEN_EOF, "", startLine, startCol)
+		return l.newToken(ast.TOKEN_EOF, "", start)
 	}
 
 	char := l.advance()
 
 	if isAlpha(char) {
-		return l.scanIdentifier(startPos, startLine, startCol)
+		return l.scanIdentifier(start)
 	}
 
 	if isDigit(char) {
-		return l.scanNumber(startPos, startLine, startCol)
+		return l.scanNumber(start)
 	}
 
 	switch char {
-	case '(': return l.newToken(ast.TOKEN_LPAREN, "(", startLine, startCol)
-	case ')': return l.newToken(ast.TOKEN_RPAREN, ")", startLine, startCol)
-	case '{': return l.newToken(ast.TOKEN_LBRACE, "{", startLine, startCol)
-	case '}': return l.newToken(ast.TOKEN_RBRACE, "}", startLine, startCol)
-	case '[': return l.newToken(ast.TOKEN_LBRACKET, "[", startLine, startCol)
-	case ']': return l.newToken(ast.TOKEN_RBRACKET, "]", startLine, startCol)
+	case '(': return l.newToken(ast.TOKEN_LPAREN, "(", start)
+	case ')': return l.newToken(ast.TOKEN_RPAREN, ")", start)
+	case '{': return l.newToken(ast.TOKEN_LBRACE, "{", start)
+	case '}': return l.newToken(ast.TOKEN_RBRACE, "}", start)
+	case '[': return l.newToken(ast.TOKEN_LBRACKET, "[", start)
+	case ']': return l.newToken(ast.TOKEN_RBRACKET, "]", start)
 	case '+':
 		if l.match('=') {
-			return l.newToken(ast.TOKEN_PLUS_EQUAL, "+=", startLine, startCol)
+			return l.newToken(ast.TOKEN_PLUS_EQUAL, "+=", start)
 		}
 
-		return l.newToken(ast.TOKEN_PLUS, "+", startLine, startCol)
+		return l.newToken(ast.TOKEN_PLUS, "+", start)
 	case '-':
 		if l.match('=') {
-			return l.newToken(ast.TOKEN_MINUS_EQUAL, "-=", startLine, startCol)
+			return l.newToken(ast.TOKEN_MINUS_EQUAL, "-=", start)
 		}
 
-		return l.newToken(ast.TOKEN_MINUS, "-", startLine, startCol)
+		return l.newToken(ast.TOKEN_MINUS, "-", start)
 	case '*':
 		if l.match('=') {
-			return l.newToken(ast.TOKEN_ASTERISK_EQUAL, "*=", startLine, startCol)
+			return l.newToken(ast.TOKEN_ASTERISK_EQUAL, "*=", start)
 		}
 
-		return l.newToken(ast.TOKEN_ASTERISK, "*", startLine, startCol)
-	case ':': return l.newToken(ast.TOKEN_COLON, ":", startLine, startCol)
-	case ',': return l.newToken(ast.TOKEN_COMMA, ",", startLine, startCol)
+		return l.newToken(ast.TOKEN_ASTERISK, "*", start)
+	case ':': return l.newToken(ast.TOKEN_COLON, ":", start)
+	case '?': return l.newToken(ast.TOKEN_QUESTION, "?", start)
+	case ',': return l.newToken(ast.TOKEN_COMMA, ",", start)
 	case '/':
 		if l.match('=') {
-			return l.newToken(ast.TOKEN_SLASH_EQUAL, "/=", startLine, startCol)
+			return l.newToken(ast.TOKEN_SLASH_EQUAL, "/=", start)
 		}
 
-		return l.newToken(ast.TOKEN_SLASH, "/", startLine, startCol)
+		return l.newToken(ast.TOKEN_SLASH, "/", start)
 	case '=':
 		if l.match('=') {
-			return l.newToken(ast.TOKEN_EQUAL_EQUAL, "==", startLine, startCol)
+			return l.newToken(ast.TOKEN_EQUAL_EQUAL, "==", start)
 		}
 
-		return l.newToken(ast.TOKEN_EQUALS, "=", startLine, startCol)
+		return l.newToken(ast.TOKEN_EQUALS, "=", start)
 	case '!':
 		if l.match('=') {
-			return l.newToken(ast.TOKEN_BANG_EQUAL, "!=", startLine, startCol)
+			return l.newToken(ast.TOKEN_BANG_EQUAL, "!=", start)
 		}
 
-		return l.newToken(ast.TOKEN_INVALID, "!", startLine, startCol)
+		return l.invalidToken("!", "invalid token '!'", start)
 	case '<':
 		if l.match('=') {
-			return l.newToken(ast.TOKEN_LESS_EQUAL, "<=", startLine, startCol)
+			return l.newToken(ast.TOKEN_LESS_EQUAL, "<=", start)
 		}
 
-		return l.newToken(ast.TOKEN_LESS, "<", startLine, startCol)
+		return l.newToken(ast.TOKEN_LESS, "<", start)
 	case '>':
 		if l.match('=') {
-			return l.newToken(ast.TOKEN_GREATER_EQUAL, ">=", startLine, startCol)
+			return l.newToken(ast.TOKEN_GREATER_EQUAL, ">=", start)
 		}
 
-		return l.newToken(ast.TOKEN_GREATER, ">", startLine, startCol)
+		return l.newToken(ast.TOKEN_GREATER, ">", start)
 	case '.':
 		if l.match('.') {
-			return l.newToken(ast.TOKEN_RANGE, "..", startLine, startCol)
+			return l.newToken(ast.TOKEN_RANGE, "..", start)
 		}
 
-		return l.newToken(ast.TOKEN_DOT, ".", startLine, startCol)
+		return l.newToken(ast.TOKEN_DOT, ".", start)
 	case '"', '\'':
-		return l.scanString(char, startLine, startCol)
+		return l.scanString(char, start)
 	}
 
-	return l.newToken(ast.TOKEN_INVALID, string(char), startLine, startCol)
+	return l.invalidToken(string(char), "invalid token '"+string(char)+"'", start)
 }
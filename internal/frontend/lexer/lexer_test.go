@@ -92,17 +92,6 @@ func TestLexer_Strings(t *testing.T) {
 	assertToken(t, l.Next(), ast.TOKEN_INVALID, "Unterminated string")
 }
 
-func TestLexer_Peek(t *testing.T) {
-	input := `var x`
-	l := NewLexer(input)
-
-	assertToken(t, l.Peek(), ast.TOKEN_KW_VAR, "var")
-	assertToken(t, l.Peek(), ast.TOKEN_KW_VAR, "var")
-
-	assertToken(t, l.Next(), ast.TOKEN_KW_VAR, "var")
-	assertToken(t, l.Next(), ast.TOKEN_IDENTIFIER, "x")
-}
-
 func TestLexer_EOF(t *testing.T) {
 	input := `x`
 	l := NewLexer(input)
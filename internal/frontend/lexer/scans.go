@@ -3,8 +3,8 @@ package lexer
 import "simplescript/internal/ast"
 
 // it consumes characters until it finds the closing delimiter
-func (l *Lexer) scanString(delimiter byte, line, col int) ast.Token {
-	start := l.pos
+func (l *Lexer) scanString(delimiter byte, start ast.Position) ast.Token {
+	contentStart := l.pos
 
 	for l.pos < len(l.buffer) {
 		char := l.buffer[l.pos]
@@ -23,17 +23,17 @@ func (l *Lexer) scanString(delimiter byte, line, col int) ast.Token {
 	}
 
 	if l.pos >= len(l.buffer) {
-		return l.newToken(ast.TOKEN_INVALID, "Unterminated string", line, col)
+		return l.invalidToken("Unterminated string", "unterminated string literal", start)
 	}
 
-	content := l.buffer[start:l.pos]
+	content := l.buffer[contentStart:l.pos]
 	l.advance()
 
-	return l.newToken(ast.TOKEN_STR, content, line, col)
+	return l.newToken(ast.TOKEN_STR, content, start)
 }
 
 // processes numeric literals and decides whether they are integers or floats
-func (l *Lexer) scanNumber(startPos, line, col int) ast.Token {
+func (l *Lexer) scanNumber(start ast.Position) ast.Token {
 	isFloat := false
 
 	for l.pos < len(l.buffer) {
@@ -54,11 +54,11 @@ func (l *Lexer) scanNumber(startPos, line, col int) ast.Token {
 	tag := ast.TOKEN_INT
 	if isFloat { tag = ast.TOKEN_FLOAT }
 
-	return l.newToken(tag, l.buffer[startPos:l.pos], line, col)
+	return l.newToken(tag, l.buffer[start.Offset:l.pos], start)
 }
 
 // it groups letters and numbers and checks if the word is a reserved keyword
-func (l *Lexer) scanIdentifier(startPos, line, col int) ast.Token {
+func (l *Lexer) scanIdentifier(start ast.Position) ast.Token {
 	for l.pos < len(l.buffer) {
 		c := l.peekChar(0)
 
@@ -69,7 +69,7 @@ func (l *Lexer) scanIdentifier(startPos, line, col int) ast.Token {
 		}
 	}
 
-	slice := l.buffer[startPos:l.pos]
+	slice := l.buffer[start.Offset:l.pos]
 
-	return l.newToken(ast.GetKeyword(slice), slice, line, col)
+	return l.newToken(ast.GetKeyword(slice), slice, start)
 }
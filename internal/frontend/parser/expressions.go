@@ -16,7 +16,7 @@ func (p *Parser) parseEquality() ast.Expression {
 	for p.match(ast.TOKEN_EQUAL_EQUAL, ast.TOKEN_BANG_EQUAL) {
 		operator := p.previous()
 		right := p.parseComparison()
-		expr = &ast.InfixExpression{Left: expr, Operator: operator.Slice, Right: right}
+		expr = p.infix(expr, operator, right)
 	}
 	return expr
 }
@@ -27,7 +27,7 @@ func (p *Parser) parseComparison() ast.Expression {
 	for p.match(ast.TOKEN_LESS, ast.TOKEN_LESS_EQUAL, ast.TOKEN_GREATER, ast.TOKEN_GREATER_EQUAL) {
 		operator := p.previous()
 		right := p.parseTerm()
-		expr = &ast.InfixExpression{Left: expr, Operator: operator.Slice, Right: right}
+		expr = p.infix(expr, operator, right)
 	}
 	return expr
 }
@@ -38,7 +38,7 @@ func (p *Parser) parseTerm() ast.Expression {
 	for p.match(ast.TOKEN_PLUS, ast.TOKEN_MINUS) {
 		operator := p.previous()
 		right := p.parseFactor()
-		expr = &ast.InfixExpression{Left: expr, Operator: operator.Slice, Right: right}
+		expr = p.infix(expr, operator, right)
 	}
 	return expr
 }
@@ -49,16 +49,29 @@ func (p *Parser) parseFactor() ast.Expression {
 	for p.match(ast.TOKEN_ASTERISK, ast.TOKEN_SLASH) {
 		operator := p.previous()
 		right := p.parseUnary()
-		expr = &ast.InfixExpression{Left: expr, Operator: operator.Slice, Right: right}
+		expr = p.infix(expr, operator, right)
 	}
 	return expr
 }
 
+func (p *Parser) infix(left ast.Expression, operator ast.Token, right ast.Expression) ast.Expression {
+	return finish(p, &ast.InfixExpression{
+		Token: operator,
+		Left: left,
+		Operator: operator.Slice,
+		Right: right,
+	}, spanOf(left, operator))
+}
+
 func (p *Parser) parseUnary() ast.Expression {
 	if p.match(ast.TOKEN_MINUS) {
 		operator := p.previous()
 		right := p.parseUnary()
-		return &ast.PrefixExpression{Operator: operator.Slice, Right: right}
+		return finish(p, &ast.PrefixExpression{
+			Token: operator,
+			Operator: operator.Slice,
+			Right: right,
+		}, operator.Span())
 	}
 	return p.parseIndex()
 }
@@ -72,11 +85,11 @@ func (p *Parser) parseIndex() ast.Expression {
 			indexExpr := p.ParseExpression()
 			p.consume(ast.TOKEN_RBRACKET, "expected ']' after index")
 
-			expr = &ast.IndexExpression{
+			expr = finish(p, &ast.IndexExpression{
 				Token: bracketToken,
 				Left: expr,
 				Index: indexExpr,
-			}
+			}, spanOf(expr, bracketToken))
 		} else {
 			break
 		}
@@ -86,7 +99,10 @@ func (p *Parser) parseIndex() ast.Expression {
 }
 
 func (p *Parser) parsePrimary() ast.Expression {
-	if p.check(ast.TOKEN_EOF) {
+	// braces never start an expression; leaving them lets `if x > {` still
+	// parse its block instead of reporting the closing '}' as well
+	if p.isAtEnd() || statementStarts[p.current().Tag] || p.check(ast.TOKEN_LBRACE) || p.check(ast.TOKEN_RBRACE) {
+		p.addError("expected expression")
 		return nil
 	}
 
@@ -95,16 +111,16 @@ func (p *Parser) parsePrimary() ast.Expression {
 	switch token.Tag {
 	case ast.TOKEN_INT:
 		val, _ := strconv.ParseInt(token.Slice, 10, 64)
-		return &ast.IntegerLiteral{Token: token, Value: val}
+		return finish(p, &ast.IntegerLiteral{Token: token, Value: val}, token.Span())
 	case ast.TOKEN_FLOAT:
 		val, _ := strconv.ParseFloat(token.Slice, 64)
-		return &ast.FloatLiteral{Token: token, Value: val}
+		return finish(p, &ast.FloatLiteral{Token: token, Value: val}, token.Span())
 	case ast.TOKEN_STR:
-		return &ast.StringLiteral{Token: token, Value: token.Slice}
+		return finish(p, &ast.StringLiteral{Token: token, Value: token.Slice}, token.Span())
 	case ast.TOKEN_KW_TRUE:
-		return &ast.BooleanLiteral{Token: token, Value: true}
+		return finish(p, &ast.BooleanLiteral{Token: token, Value: true}, token.Span())
 	case ast.TOKEN_KW_FALSE:
-		return &ast.BooleanLiteral{Token: token, Value: false}
+		return finish(p, &ast.BooleanLiteral{Token: token, Value: false}, token.Span())
 	case ast.TOKEN_LBRACKET:
 		elements := []ast.Expression{}
 
@@ -118,15 +134,41 @@ func (p *Parser) parsePrimary() ast.Expression {
 
 		p.consume(ast.TOKEN_RBRACKET, "expected ']' after list elements")
 
-		return &ast.ListLiteral{Token: token, Elements: elements}
+		return finish(p, &ast.ListLiteral{Token: token, Elements: elements}, token.Span())
 	case ast.TOKEN_IDENTIFIER:
-		return &ast.Identifier{Token: token, Value: token.Slice}
+		// say is a statement, not a function
+		if token.Slice != "say" && p.match(ast.TOKEN_LPAREN) {
+			return p.parseCall(token)
+		}
+
+		return finish(p, &ast.Identifier{Token: token, Value: token.Slice}, token.Span())
 	case ast.TOKEN_LPAREN:
 		expr := p.ParseExpression()
 		p.consume(ast.TOKEN_RPAREN, "expected ')' after expression")
+
+		if expr != nil {
+			expr.SetSpan(token.Span().Cover(p.previous().Span()))
+		}
+
 		return expr
 	default:
-		p.addError("unexpected token in expression")
+		p.addErrorAt(token, "unexpected token in expression")
 		return nil
 	}
 }
+
+func (p *Parser) parseCall(name ast.Token) ast.Expression {
+	args := []ast.Expression{}
+
+	if !p.check(ast.TOKEN_RPAREN) {
+		for {
+			args = append(args, p.ParseExpression())
+
+			if !p.match(ast.TOKEN_COMMA) { break }
+		}
+	}
+
+	p.consume(ast.TOKEN_RPAREN, "expected ')' after arguments")
+
+	return finish(p, &ast.CallExpression{Token: name, Function: name.Slice, Args: args}, name.Span())
+}
@@ -2,66 +2,168 @@ package parser
 
 import (
 	"fmt"
-	"os"
+	"sort"
 
 	"simplescript/internal/ast"
+	"simplescript/internal/diagnostic"
+	"simplescript/internal/frontend/lexer"
 )
 
 type Parser struct {
 	tokens []ast.Token
 	pos int
-  errors []string
+	lexer *lexer.Lexer
+  errors []diagnostic.Diagnostic
+  panicking bool
 }
 
-func NewParser(tokens []ast.Token) *Parser {
+func NewParser(l *lexer.Lexer) *Parser {
 	return &Parser{
-		tokens: tokens,
+		tokens: l.Tokenize(),
 		pos: 0,
-		errors: []string{},
+		lexer: l,
+		errors: []diagnostic.Diagnostic{},
 	}
 }
 
-func MustParse(tokens []ast.Token) *ast.Program {
-	p := NewParser(tokens)
+// parses the whole token stream, returning an error if any diagnostic was reported
+func (p *Parser) Parse() (*ast.Program, error) {
 	program := p.parse()
 
+	p.errors = append(p.errors, p.lexer.Errors()...)
+	sort.SliceStable(p.errors, func(i, j int) bool {
+		a, b := p.errors[i].Range.Start, p.errors[j].Range.Start
+		return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+	})
+
 	if len(p.errors) > 0 {
-		fmt.Println("Syntax Errors found:")
-		for _, msg := range p.errors {
-			fmt.Printf(" - %s\n", msg)
-		}
-		os.Exit(1)
+		return program, fmt.Errorf("parsing finished with %d errors", len(p.errors))
+	}
+
+	return program, nil
+}
+
+// parses input that must consist of exactly one expression, as typed in the REPL
+func (p *Parser) ParseStandaloneExpression() (ast.Expression, error) {
+	expr := p.ParseExpression()
+
+	if !p.isAtEnd() {
+		p.addError("expected end of expression")
+	}
+
+	p.errors = append(p.errors, p.lexer.Errors()...)
+
+	if len(p.errors) > 0 {
+		return nil, fmt.Errorf("parsing finished with %d errors", len(p.errors))
 	}
 
-	return program
+	return expr, nil
+}
+
+func (p *Parser) Errors() []diagnostic.Diagnostic {
+	return p.errors
 }
 
 func (p *Parser) parse() *ast.Program {
 	prog := &ast.Program{}
+	prog.SetSpan(p.tokens[0].Span().Cover(p.tokens[len(p.tokens)-1].Span()))
 
 	for !p.isAtEnd() {
-		if stmt := p.parseStatement(); stmt != nil {
+		stmt := p.parseStatement()
+		if stmt != nil {
 			prog.Statements = append(prog.Statements, stmt)
-		} else {
-			p.advance()
+		}
+
+		if stmt == nil || p.panicking {
+			p.synchronize()
 		}
 	}
 
 	return prog
 }
 
+// statements always begin with one of these keywords or with an identifier
+var statementStarts = map[ast.TokenType]bool{
+	ast.TOKEN_KW_VAR: true,
+	ast.TOKEN_KW_CONST: true,
+	ast.TOKEN_KW_IF: true,
+	ast.TOKEN_KW_FOR: true,
+	ast.TOKEN_KW_FUNC: true,
+	ast.TOKEN_KW_RETURN: true,
+	ast.TOKEN_KW_BREAK: true,
+	ast.TOKEN_KW_CONTINUE: true,
+}
+
+// leaves panic mode by skipping tokens until the start of the next statement
+// or the '}' closing the current block, so one mistake yields one error
+func (p *Parser) synchronize() {
+	p.panicking = false
+	depth := 0
+
+	for !p.isAtEnd() {
+		cur := p.current()
+
+		if depth == 0 {
+			if statementStarts[cur.Tag] || cur.Tag == ast.TOKEN_RBRACE {
+				return
+			}
+
+			startsLine := p.pos == 0 || p.previous().Line < cur.Line
+			if cur.Tag == ast.TOKEN_IDENTIFIER && startsLine {
+				return
+			}
+		}
+
+		switch cur.Tag {
+		case ast.TOKEN_LBRACE:
+			depth++
+		case ast.TOKEN_RBRACE:
+			depth--
+		}
+
+		p.advance()
+	}
+}
+
 func (p *Parser) addError(msg string) {
-	cur := p.current()
-	err := fmt.Sprintf(
-		"Syntax Error at line %d, col %d: %s. Got '%s' instead.",
-		cur.Line,
-		cur.Col,
-		msg,
-		cur.Slice,
-	)
+	p.addErrorAt(p.current(), msg)
+}
+
+// reports a syntax error unless the parser is already recovering from one.
+// Invalid tokens were already reported by the lexer, so they only start recovery.
+func (p *Parser) addErrorAt(tok ast.Token, msg string) {
+	if p.panicking {
+		return
+	}
+
+	p.panicking = true
+	if tok.Tag == ast.TOKEN_INVALID {
+		return
+	}
+	got := tok.Slice
+	if tok.Tag == ast.TOKEN_EOF {
+		got = "end of file"
+	}
+
+	err := diagnostic.Errorf(diagnostic.SyntaxError, tok.Span(), "%s. Got '%s' instead", msg, got)
 	p.errors = append(p.errors, err)
 }
 
+// stamps the node with the source range from start up to the last consumed token
+func finish[T ast.Node](p *Parser, node T, start ast.Span) T {
+	node.SetSpan(start.Cover(p.previous().Span()))
+	return node
+}
+
+// the span of a possibly missing node, falling back to a nearby token
+func spanOf(node ast.Node, fallback ast.Token) ast.Span {
+	if node == nil {
+		return fallback.Span()
+	}
+
+	return node.Span()
+}
+
 func (p *Parser) current() ast.Token {
 	if p.pos >= len(p.tokens) {
 		return p.tokens[len(p.tokens)-1]
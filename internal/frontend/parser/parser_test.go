@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"simplescript/internal/ast"
@@ -58,8 +59,78 @@ func TestVarStatements(t *testing.T) {
 			t.Errorf("varDecl.IsConst not %t. got=%t", tt.isConst, varDecl.IsConst)
 		}
 
-		if tt.expectedType != "" && varDecl.DataType != tt.expectedType {
-			t.Errorf("varDecl.DataType not %s. got=%s", tt.expectedType, varDecl.DataType)
+		if tt.expectedType != "" && typeString(varDecl.DataType) != tt.expectedType {
+			t.Errorf("varDecl.DataType not %s. got=%s", tt.expectedType, typeString(varDecl.DataType))
+		}
+	}
+}
+
+// renders a parsed type annotation back to source
+func typeString(expr ast.TypeExpr) string {
+	switch e := expr.(type) {
+	case *ast.NamedType: return e.Name
+	case *ast.ListType: return "list[" + typeString(e.Elem) + "]"
+	case *ast.MapType: return "map[" + typeString(e.Key) + "]" + typeString(e.Value)
+	case *ast.FuncType:
+		params := []string{}
+		for _, p := range e.Params {
+			params = append(params, typeString(p))
+		}
+
+		s := "func(" + strings.Join(params, ", ") + ")"
+		if e.Result != nil {
+			s += " " + typeString(e.Result)
+		}
+		return s
+	case *ast.StructType:
+		fields := []string{}
+		for _, f := range e.Fields {
+			fields = append(fields, f.Name+": "+typeString(f.Type))
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	case *ast.OptionalType: return typeString(e.Elem) + "?"
+	}
+
+	return "<nil>"
+}
+
+func TestTypeExpressions(t *testing.T) {
+	tests := []struct {
+		annotation string
+		expected string
+	}{
+		{"list", "list"},
+		{"list[int]", "list[int]"},
+		{"list[list[str]]", "list[list[str]]"},
+		{"map[str]float", "map[str]float"},
+		{"func()", "func()"},
+		{"func(int, str) bool", "func(int, str) bool"},
+		{"func(func(int)) list[int]", "func(func(int)) list[int]"},
+		{"{x: int, y: float}", "{x: int, y: float}"},
+		{"{}", "{}"},
+		{"int?", "int?"},
+		{"list[int?]?", "list[int?]?"},
+	}
+
+	for _, tt := range tests {
+		p := NewParser(lexer.NewLexer("var v: " + tt.annotation + " = 0"))
+		program, _ := p.Parse()
+		checkParserErrors(t, p)
+
+		got := typeString(program.Statements[0].(*ast.VarDecl).DataType)
+		if got != tt.expected {
+			t.Errorf("%q: expected %s, got %s", tt.annotation, tt.expected, got)
+		}
+	}
+}
+
+func TestInvalidTypeExpressions(t *testing.T) {
+	tests := []string{"var v: = 1", "var v: list[int = 1", "var v: map[str] = 1", "var v: func(int = 1", "var v: {x int} = 1", "var v: 5 = 1", `var v: "int" = 1`}
+
+	for _, input := range tests {
+		p := NewParser(lexer.NewLexer(input))
+		if _, err := p.Parse(); err == nil {
+			t.Errorf("%q: expected a syntax error", input)
 		}
 	}
 }
@@ -86,6 +157,81 @@ func TestSayStatement(t *testing.T) {
 	}
 }
 
+func TestSayOptions(t *testing.T) {
+	p := NewParser(lexer.NewLexer(`say(1, format("{}", 2), sep=", ", end="")`))
+	program, _ := p.Parse()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.SayStmt)
+	if len(stmt.Args) != 2 || stmt.Sep == nil || stmt.End == nil {
+		t.Fatalf("expected 2 arguments and both options, got %+v", stmt)
+	}
+
+	call, ok := stmt.Args[1].(*ast.CallExpression)
+	if !ok || call.Function != "format" || len(call.Args) != 2 {
+		t.Errorf("expected a call of format with 2 arguments, got %#v", stmt.Args[1])
+	}
+
+	for input, expected := range map[string]string{
+		`say(sep=" ", 1)`: "positional argument after a 'sep' or 'end' option",
+		`say(1, end="", end="")`: "duplicate 'end' option",
+	} {
+		errors := parseErrors(t, input)
+		if len(errors) != 1 || !strings.Contains(errors[0], expected) {
+			t.Errorf("%s: expected an error containing %q, got %q", input, expected, errors)
+		}
+	}
+}
+
+func TestFunctionDeclarations(t *testing.T) {
+	input := "export func add(a: int, b: list[int]) int {\n  return a + b[0]\n}\nfunc log(msg: str) {\n  say(msg)\n  return\n}\nlog(\"hi\")"
+	p := NewParser(lexer.NewLexer(input))
+	program, _ := p.Parse()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements does not contain 3 statements. got=%d", len(program.Statements))
+	}
+
+	add, ok := program.Statements[0].(*ast.FuncDecl)
+	if !ok || !add.Exported || add.Name != "add" || len(add.Params) != 2 || typeString(add.Result) != "int" {
+		t.Fatalf("expected an exported add(a, b) int, got %#v", program.Statements[0])
+	}
+
+	if add.Params[1].Name != "b" || typeString(add.Params[1].DataType) != "list[int]" {
+		t.Errorf("expected parameter b: list[int], got %s: %s", add.Params[1].Name, typeString(add.Params[1].DataType))
+	}
+
+	log, ok := program.Statements[1].(*ast.FuncDecl)
+	if !ok || log.Exported || log.Result != nil || len(log.Body.Statements) != 2 {
+		t.Fatalf("expected log(msg) with two statements, got %#v", program.Statements[1])
+	}
+
+	if ret := log.Body.Statements[1].(*ast.ReturnStmt); ret.ReturnValue != nil {
+		t.Errorf("expected a bare return, got %#v", ret.ReturnValue)
+	}
+
+	if call, ok := program.Statements[2].(*ast.CallStmt); !ok || call.Call.Function != "log" || len(call.Call.Args) != 1 {
+		t.Errorf("expected a call statement of log, got %#v", program.Statements[2])
+	}
+
+	for input, expected := range map[string]string{
+		"func (a: int) {}": "expected function name",
+		"func f(a int) {}": "expected ':' and a type after parameter name",
+		"func f(a: int) = {}": "expected result type or '{' after parameters",
+		"export func {}": "expected function name",
+	} {
+		errors := parseErrors(t, input)
+		if len(errors) != 1 || !strings.Contains(errors[0], expected) {
+			t.Errorf("%s: expected an error containing %q, got %q", input, expected, errors)
+		}
+	}
+
+	p = NewParser(lexer.NewLexer("var export: int = 1\nexport = 2"))
+	p.Parse()
+	checkParserErrors(t, p)
+}
+
 func TestIfElseStatement(t *testing.T) {
 	input := `
 		if x > 10 {
@@ -108,3 +254,112 @@ func TestIfElseStatement(t *testing.T) {
 		t.Errorf("expected else (Alternative) to not be nil")
 	}
 }
+
+func parseErrors(t *testing.T, input string) []string {
+	t.Helper()
+
+	p := NewParser(lexer.NewLexer(input))
+	if _, err := p.Parse(); err == nil {
+		t.Fatalf("expected parse errors for input %q", input)
+	}
+
+	messages := []string{}
+	for _, d := range p.Errors() {
+		messages = append(messages, d.String())
+	}
+
+	return messages
+}
+
+func TestErrorRecoverySynchronizesToNextStatement(t *testing.T) {
+	input := `
+		var = 5 + 3
+		say("still parsed")
+		const : int = 1
+		var ok: int = 2
+	`
+
+	errors := parseErrors(t, input)
+	if len(errors) != 2 {
+		t.Fatalf("expected 2 errors (one per bad line), got=%d: %q", len(errors), errors)
+	}
+}
+
+func TestErrorRecoveryInsideBlocks(t *testing.T) {
+	input := `
+		if x > 1 {
+			var = 1
+			say("inside")
+		}
+		for i in 0 10 {
+			say(i)
+		}
+		y = = 2
+	`
+
+	errors := parseErrors(t, input)
+	if len(errors) != 3 {
+		t.Fatalf("expected 3 errors, got=%d: %q", len(errors), errors)
+	}
+}
+
+func TestErrorRecoveryAfterMissingConditions(t *testing.T) {
+	for _, input := range []string{
+		"if x > {\n  say(1)\n}\nsay(2)",
+		"for i in 0.. {\n  say(i)\n}\nsay(2)",
+		"if {\n  say(1)\n} else {\n  say(2)\n}",
+	} {
+		errors := parseErrors(t, input)
+		if len(errors) != 1 || !strings.Contains(errors[0], "expected expression") {
+			t.Errorf("%q: expected only the missing expression to be reported, got %q", input, errors)
+		}
+	}
+}
+
+func TestMissingTypeIsReportedAtTheNextToken(t *testing.T) {
+	errors := parseErrors(t, "var x: = 1")
+	if len(errors) != 1 || !strings.Contains(errors[0], ":1:8:") || !strings.Contains(errors[0], "Got '=' instead") {
+		t.Errorf("expected the missing type to be reported at '=', got %q", errors)
+	}
+}
+
+func TestLexicalErrorsAreRecoverable(t *testing.T) {
+	input := `
+		var a: int = 1 !
+		var b: int = @
+		var = 3
+	`
+
+	errors := parseErrors(t, input)
+	if len(errors) != 3 {
+		t.Fatalf("expected 3 errors, got=%d: %q", len(errors), errors)
+	}
+}
+
+func TestNodeSpans(t *testing.T) {
+	input := "var total: int = (a + b) * 2\nsay(-total)"
+
+	p := NewParser(lexer.NewFileLexer("spans.ss", input))
+	program, _ := p.Parse()
+	checkParserErrors(t, p)
+
+	varDecl := program.Statements[0].(*ast.VarDecl)
+	if span := varDecl.Span(); span.Start.Offset != 0 || span.End.Offset != 28 {
+		t.Errorf("varDecl span not [0, 28). got=[%d, %d)", span.Start.Offset, span.End.Offset)
+	}
+
+	product := varDecl.Value.(*ast.InfixExpression)
+	if span := product.Span(); span.Start.Col != 18 || span.End.Col != 29 {
+		t.Errorf("product span not cols [18, 29). got=[%d, %d)", span.Start.Col, span.End.Col)
+	}
+
+	sum := product.Left.(*ast.InfixExpression)
+	if sum.Token.Slice != "+" || sum.Span().File != "spans.ss" {
+		t.Errorf("unexpected infix token or file. got=%q in %q", sum.Token.Slice, sum.Span().File)
+	}
+
+	prefix := program.Statements[1].(*ast.SayStmt).Args[0].(*ast.PrefixExpression)
+	if span := prefix.Span(); span.Start.Line != 2 || span.Start.Col != 5 || span.End.Col != 11 {
+		t.Errorf("prefix span not 2:[5, 11). got=%d:[%d, %d)", span.Start.Line, span.Start.Col, span.End.Col)
+	}
+}
@@ -1,6 +1,10 @@
 package parser
 
-import "simplescript/internal/ast"
+import (
+	"fmt"
+
+	"simplescript/internal/ast"
+)
 
 func (p *Parser) parseStatement() ast.Statement {
 	token := p.advance()
@@ -10,32 +14,48 @@ func (p *Parser) parseStatement() ast.Statement {
 	case ast.TOKEN_KW_CONST: return p.parseVarDecl(true)
 	case ast.TOKEN_KW_IF: return p.parseIf()
 	case ast.TOKEN_KW_FOR: return p.parseFor()
-	case ast.TOKEN_LBRACE: return p.parseBlock()
+	case ast.TOKEN_LBRACE:
+		if block := p.parseBlock(); block != nil {
+			return block
+		}
+
+		return nil
 	case ast.TOKEN_KW_RETURN: return p.parseReturn()
 	case ast.TOKEN_KW_BREAK: return p.parseBreak()
 	case ast.TOKEN_KW_CONTINUE: return p.parseContinue()
+	case ast.TOKEN_KW_FUNC: return p.parseFuncDecl(token, false)
 	case ast.TOKEN_IDENTIFIER:
 		if token.Slice == "say" {
 			return p.parseSay()
 		}
 
-		var target ast.Expression = &ast.Identifier{Token: token, Value: token.Slice}
+		// export is only a keyword before func, so variables may still use the name
+		if token.Slice == "export" && p.match(ast.TOKEN_KW_FUNC) {
+			return p.parseFuncDecl(token, true)
+		}
+
+		if p.match(ast.TOKEN_LPAREN) {
+			call := p.parseCall(token).(*ast.CallExpression)
+			return finish(p, &ast.CallStmt{Call: call}, token.Span())
+		}
+
+		var target ast.Expression = finish(p, &ast.Identifier{Token: token, Value: token.Slice}, token.Span())
 
 		for p.match(ast.TOKEN_LBRACKET) {
 			bracketToken := p.previous()
 			indexExpr := p.ParseExpression()
 			p.consume(ast.TOKEN_RBRACKET, "expected ']' after index")
 
-			target = &ast.IndexExpression{
+			target = finish(p, &ast.IndexExpression{
 				Token: bracketToken,
 				Left: target,
 				Index: indexExpr,
-			}
+			}, target.Span())
 		}
 
 		return p.parseAssignment(target)
 	default:
-		p.addError("unexpected token in statement")
+		p.addErrorAt(token, "unexpected token in statement")
 		return nil
 	}
 }
@@ -46,16 +66,17 @@ func (p *Parser) parseVarDecl(isConst bool) ast.Statement {
   name := p.consume(ast.TOKEN_IDENTIFIER, "expected variable name")
   if name.Tag == ast.TOKEN_INVALID { return nil }
 
-  dataType := ""
+  var dataType ast.TypeExpr
   if p.match(ast.TOKEN_COLON) {
-  	dt := p.advance()
-
-    if dt.Tag == ast.TOKEN_EQUALS || dt.Tag == ast.TOKEN_EOF {
+    if p.check(ast.TOKEN_EQUALS) || p.isAtEnd() {
     	p.addError("expected type after ':'")
+    	p.advance()
     	return nil
     }
 
-    dataType = dt.Slice
+    if dataType = p.parseType(); dataType == nil {
+    	return nil
+    }
   }
 
   if p.consume(ast.TOKEN_EQUALS, "expected '=' in variable declaration").Tag == ast.TOKEN_INVALID {
@@ -64,13 +85,13 @@ func (p *Parser) parseVarDecl(isConst bool) ast.Statement {
 
   value := p.ParseExpression()
 
-  return &ast.VarDecl{
+  return finish(p, &ast.VarDecl{
 		Token: token,
 		Name: name.Slice,
 		DataType: dataType,
 		IsConst: isConst,
 		Value: value,
-	}
+	}, token.Span())
 }
 
 func (p *Parser) parseAssignment(firstTarget ast.Expression) ast.Statement {
@@ -111,12 +132,12 @@ func (p *Parser) parseAssignment(firstTarget ast.Expression) ast.Statement {
     }
   }
 
-  return &ast.Assignment{
+  return finish(p, &ast.Assignment{
 		Token: token,
 		Targets: targets,
 		Operator: operator,
 		Values: values,
-	}
+	}, firstTarget.Span())
 }
 
 func (p *Parser) parseSay() ast.Statement {
@@ -126,10 +147,10 @@ func (p *Parser) parseSay() ast.Statement {
 		return nil
 	}
 
-	args := []ast.Expression{}
+	stmt := &ast.SayStmt{Token: token, Args: []ast.Expression{}}
 	if !p.check(ast.TOKEN_RPAREN) {
 		for {
-			args = append(args, p.ParseExpression())
+			p.parseSayArgument(stmt)
 
 			if !p.match(ast.TOKEN_COMMA) {
 				break
@@ -141,7 +162,39 @@ func (p *Parser) parseSay() ast.Statement {
 		return nil
 	}
 
-	return &ast.SayStmt{Token: token, Args: args}
+	return finish(p, stmt, token.Span())
+}
+
+// parses one argument of say: a value to print, or one of the sep=... and
+// end=... options, which come after the values
+func (p *Parser) parseSayArgument(stmt *ast.SayStmt) {
+	name := p.current()
+	isOption := name.Tag == ast.TOKEN_IDENTIFIER && (name.Slice == "sep" || name.Slice == "end") &&
+		p.pos+1 < len(p.tokens) && p.tokens[p.pos+1].Tag == ast.TOKEN_EQUALS
+
+	if !isOption {
+		if stmt.Sep != nil || stmt.End != nil {
+			p.addErrorAt(name, "positional argument after a 'sep' or 'end' option")
+		}
+
+		stmt.Args = append(stmt.Args, p.ParseExpression())
+		return
+	}
+
+	p.advance()
+	p.advance()
+	value := p.ParseExpression()
+
+	option := &stmt.Sep
+	if name.Slice == "end" {
+		option = &stmt.End
+	}
+
+	if *option != nil {
+		p.addErrorAt(name, fmt.Sprintf("duplicate '%s' option", name.Slice))
+	}
+
+	*option = value
 }
 
 func (p *Parser) parseBlock() *ast.Block {
@@ -149,16 +202,21 @@ func (p *Parser) parseBlock() *ast.Block {
 	stmts := []ast.Statement{}
 
 	for !p.check(ast.TOKEN_RBRACE) && !p.isAtEnd() {
-		if stmt := p.parseStatement(); stmt != nil {
+		stmt := p.parseStatement()
+		if stmt != nil {
 			stmts = append(stmts, stmt)
 		}
+
+		if stmt == nil || p.panicking {
+			p.synchronize()
+		}
 	}
 
 	if p.consume(ast.TOKEN_RBRACE, "expected '}' after block").Tag == ast.TOKEN_INVALID {
 		return nil
 	}
 
-	return &ast.Block{Token: token, Statements: stmts}
+	return finish(p, &ast.Block{Token: token, Statements: stmts}, token.Span())
 }
 
 func (p *Parser) parseIf() ast.Statement {
@@ -184,15 +242,15 @@ func (p *Parser) parseIf() ast.Statement {
     }
   }
 
-  return &ast.IfStmt{
+  return finish(p, &ast.IfStmt{
 		Token: token,
 		Condition: cond,
 		Consequence: cons,
 		Alternative: alt,
-	}
+	}, token.Span())
 }
 
-func (p *Parser) parseFor() *ast.ForStmt {
+func (p *Parser) parseFor() ast.Statement {
 	token := p.previous()
 
 	iter := p.consume(ast.TOKEN_IDENTIFIER, "expected iterator variable name")
@@ -218,26 +276,114 @@ func (p *Parser) parseFor() *ast.ForStmt {
 
 	body := p.parseBlock()
 
-	return &ast.ForStmt{
+	return finish(p, &ast.ForStmt{
 		Token: token,
 		Iterator: iter.Slice,
 		Start: start,
 		End: end,
 		Body: body,
+	}, token.Span())
+}
+
+// parses the rest of a declaration starting at token, which is 'func' or 'export'
+func (p *Parser) parseFuncDecl(token ast.Token, exported bool) ast.Statement {
+	name := p.consume(ast.TOKEN_IDENTIFIER, "expected function name")
+	if name.Tag == ast.TOKEN_INVALID {
+		return nil
+	}
+
+	if p.consume(ast.TOKEN_LPAREN, "expected '(' after function name").Tag == ast.TOKEN_INVALID {
+		return nil
+	}
+
+	params := []*ast.Param{}
+	if !p.check(ast.TOKEN_RPAREN) {
+		for {
+			param := p.parseParam()
+			if param == nil {
+				return nil
+			}
+
+			params = append(params, param)
+
+			if !p.match(ast.TOKEN_COMMA) {
+				break
+			}
+		}
+	}
+
+	if p.consume(ast.TOKEN_RPAREN, "expected ')' after parameters").Tag == ast.TOKEN_INVALID {
+		return nil
+	}
+
+	var result ast.TypeExpr
+	if !p.check(ast.TOKEN_LBRACE) {
+		if !p.startsType() {
+			p.addError("expected result type or '{' after parameters")
+			return nil
+		}
+
+		if result = p.parseType(); result == nil {
+			return nil
+		}
+	}
+
+	if p.consume(ast.TOKEN_LBRACE, "expected '{' before function body").Tag == ast.TOKEN_INVALID {
+		return nil
+	}
+
+	body := p.parseBlock()
+	if body == nil {
+		return nil
+	}
+
+	return finish(p, &ast.FuncDecl{
+		Token: token,
+		Exported: exported,
+		Name: name.Slice,
+		Params: params,
+		Result: result,
+		Body: body,
+	}, token.Span())
+}
+
+// parses name: Type
+func (p *Parser) parseParam() *ast.Param {
+	name := p.consume(ast.TOKEN_IDENTIFIER, "expected parameter name")
+	if name.Tag == ast.TOKEN_INVALID {
+		return nil
+	}
+
+	if p.consume(ast.TOKEN_COLON, "expected ':' and a type after parameter name").Tag == ast.TOKEN_INVALID {
+		return nil
 	}
+
+	dataType := p.parseType()
+	if dataType == nil {
+		return nil
+	}
+
+	return finish(p, &ast.Param{Token: name, Name: name.Slice, DataType: dataType}, name.Span())
 }
 
+// the value is optional: a return followed by '}' or by a new line is bare
 func (p *Parser) parseReturn() ast.Statement {
-	return &ast.ReturnStmt{
-		Token: p.previous(),
-		ReturnValue: p.ParseExpression(),
+	token := p.previous()
+	stmt := &ast.ReturnStmt{Token: token}
+
+	if !p.isAtEnd() && !p.check(ast.TOKEN_RBRACE) && p.current().Line == token.Line {
+		stmt.ReturnValue = p.ParseExpression()
 	}
+
+	return finish(p, stmt, token.Span())
 }
 
 func (p *Parser) parseBreak() ast.Statement {
-	return &ast.BreakStmt{Token: p.previous()}
+	token := p.previous()
+	return finish(p, &ast.BreakStmt{Token: token}, token.Span())
 }
 
 func (p *Parser) parseContinue() ast.Statement {
-	return &ast.ContinueStmt{Token: p.previous()}
+	token := p.previous()
+	return finish(p, &ast.ContinueStmt{Token: token}, token.Span())
 }
@@ -0,0 +1,138 @@
+package parser
+
+import "simplescript/internal/ast"
+
+// tokens that name a type on their own
+var namedTypes = map[ast.TokenType]bool{
+	ast.TOKEN_INT: true,
+	ast.TOKEN_FLOAT: true,
+	ast.TOKEN_STR: true,
+	ast.TOKEN_BOOL: true,
+	ast.TOKEN_JSON: true,
+	ast.TOKEN_IDENTIFIER: true,
+}
+
+// number and string literals share their tags with the type keywords,
+// so `5` or `"int"` must not be taken for a type name
+func isTypeName(tok ast.Token) bool {
+	if tok.Tag == ast.TOKEN_IDENTIFIER {
+		return true
+	}
+
+	return namedTypes[tok.Tag] && ast.GetKeyword(tok.Slice) == tok.Tag && tok.End.Offset-tok.Offset == len(tok.Slice)
+}
+
+// parses a type annotation:
+//   int | float | str | bool | list | list[T] | map[K]V
+//   | func(T, ...) R | {name: T, ...} | T?
+func (p *Parser) parseType() ast.TypeExpr {
+	token := p.advance()
+	var typ ast.TypeExpr
+
+	switch {
+	case isTypeName(token):
+		typ = finish(p, &ast.NamedType{Token: token, Name: token.Slice}, token.Span())
+
+	case token.Tag == ast.TOKEN_LIST:
+		if !p.match(ast.TOKEN_LBRACKET) {
+			typ = finish(p, &ast.NamedType{Token: token, Name: token.Slice}, token.Span())
+			break
+		}
+
+		elem := p.parseType()
+		if elem == nil || p.consume(ast.TOKEN_RBRACKET, "expected ']' after list element type").Tag == ast.TOKEN_INVALID {
+			return nil
+		}
+
+		typ = finish(p, &ast.ListType{Token: token, Elem: elem}, token.Span())
+
+	case token.Tag == ast.TOKEN_MAP:
+		if p.consume(ast.TOKEN_LBRACKET, "expected '[' after 'map'").Tag == ast.TOKEN_INVALID {
+			return nil
+		}
+
+		key := p.parseType()
+		if key == nil || p.consume(ast.TOKEN_RBRACKET, "expected ']' after map key type").Tag == ast.TOKEN_INVALID {
+			return nil
+		}
+
+		value := p.parseType()
+		if value == nil {
+			return nil
+		}
+
+		typ = finish(p, &ast.MapType{Token: token, Key: key, Value: value}, token.Span())
+
+	case token.Tag == ast.TOKEN_KW_FUNC:
+		if p.consume(ast.TOKEN_LPAREN, "expected '(' after 'func'").Tag == ast.TOKEN_INVALID {
+			return nil
+		}
+
+		params := []ast.TypeExpr{}
+		for !p.check(ast.TOKEN_RPAREN) {
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+
+			params = append(params, param)
+			if !p.match(ast.TOKEN_COMMA) {
+				break
+			}
+		}
+
+		if p.consume(ast.TOKEN_RPAREN, "expected ')' after parameter types").Tag == ast.TOKEN_INVALID {
+			return nil
+		}
+
+		// the result type is optional, so only parse one that starts here
+		var result ast.TypeExpr
+		if p.startsType() {
+			if result = p.parseType(); result == nil {
+				return nil
+			}
+		}
+
+		typ = finish(p, &ast.FuncType{Token: token, Params: params, Result: result}, token.Span())
+
+	case token.Tag == ast.TOKEN_LBRACE:
+		fields := []*ast.FieldType{}
+		for !p.check(ast.TOKEN_RBRACE) {
+			name := p.consume(ast.TOKEN_IDENTIFIER, "expected field name")
+			if name.Tag == ast.TOKEN_INVALID || p.consume(ast.TOKEN_COLON, "expected ':' after field name").Tag == ast.TOKEN_INVALID {
+				return nil
+			}
+
+			fieldType := p.parseType()
+			if fieldType == nil {
+				return nil
+			}
+
+			fields = append(fields, finish(p, &ast.FieldType{Token: name, Name: name.Slice, Type: fieldType}, name.Span()))
+			if !p.match(ast.TOKEN_COMMA) {
+				break
+			}
+		}
+
+		if p.consume(ast.TOKEN_RBRACE, "expected '}' after struct fields").Tag == ast.TOKEN_INVALID {
+			return nil
+		}
+
+		typ = finish(p, &ast.StructType{Token: token, Fields: fields}, token.Span())
+
+	default:
+		p.addErrorAt(token, "expected type")
+		return nil
+	}
+
+	for p.match(ast.TOKEN_QUESTION) {
+		typ = finish(p, &ast.OptionalType{Token: p.previous(), Elem: typ}, typ.Span())
+	}
+
+	return typ
+}
+
+func (p *Parser) startsType() bool {
+	cur := p.current().Tag
+	return isTypeName(p.current()) || cur == ast.TOKEN_LIST || cur == ast.TOKEN_MAP || cur == ast.TOKEN_KW_FUNC || cur == ast.TOKEN_LBRACE
+}
@@ -0,0 +1,28 @@
+package interpreter
+
+import (
+	"fmt"
+
+	"simplescript/internal/ast"
+)
+
+// an error raised while the program is running, pointing at the failing node
+type RuntimeError struct {
+	Span ast.Span
+	Message string
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf(
+		"RuntimeError: %s at %s:%d:%d",
+		e.Message,
+		e.Span.File,
+		e.Span.Start.Line,
+		e.Span.Start.Col,
+	)
+}
+
+// node is the AST node or IR instruction that failed
+func runtimeError(node interface{ Span() ast.Span }, format string, args ...any) *RuntimeError {
+	return &RuntimeError{Span: node.Span(), Message: fmt.Sprintf(format, args...)}
+}
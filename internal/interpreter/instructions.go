@@ -0,0 +1,151 @@
+package interpreter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"simplescript/internal/ast"
+	"simplescript/internal/ir"
+	"simplescript/internal/types"
+	"simplescript/internal/value"
+)
+
+// the values of a running program's locals, indexed by Local.ID
+type frame struct {
+	values []any
+}
+
+func (f frame) read(v ir.Value, span ast.Span) (any, error) {
+	switch v := v.(type) {
+	case *ir.Const:
+		if n, ok := v.Value.(int64); ok {
+			return int(n), nil
+		}
+
+		return v.Value, nil
+	case *ir.Local:
+		if result := f.values[v.ID]; result != nil || !v.Outer {
+			return result, nil
+		}
+
+		return nil, &RuntimeError{Span: span, Message: fmt.Sprintf("undefined variable '%s'", v.Name)}
+	}
+
+	return nil, &RuntimeError{Span: span, Message: fmt.Sprintf("invalid operand %T", v)}
+}
+
+func (f frame) readAll(values []ir.Value, span ast.Span) ([]any, error) {
+	results := []any{}
+
+	for _, v := range values {
+		result, err := f.read(v, span)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func (in *Interpreter) exec(f frame, instr ir.Instr) error {
+	operands, err := f.readAll(ir.Uses(instr), instr.Span())
+	if err != nil {
+		return err
+	}
+
+	var result any
+
+	switch i := instr.(type) {
+	case *ir.Copy:
+		result = operands[0]
+		err = checkElement(operands[0], i.Src, i.Dst.Type)
+	case *ir.Unary:
+		result, err = value.Negate(operands[0])
+	case *ir.Binary:
+		result, err = value.Binary(i.Op, operands[0], operands[1])
+	case *ir.MakeList:
+		result = operands
+	case *ir.Load:
+		list, index, checkErr := value.CheckIndex(operands[0], operands[1])
+		if checkErr != nil {
+			return runtimeError(i, "%v", checkErr)
+		}
+
+		result = list[index]
+	case *ir.Store:
+		list, index, checkErr := value.CheckIndex(operands[0], operands[1])
+		if checkErr != nil {
+			return runtimeError(i, "%v", checkErr)
+		}
+
+		list[index] = operands[2]
+		return nil
+	case *ir.Display:
+		result = value.Display(operands[0])
+	case *ir.Args:
+		args := []any{}
+		for _, arg := range in.args {
+			args = append(args, arg)
+		}
+		result = args
+	case *ir.Env:
+		if err := value.Check(operands[0], "str"); err != nil {
+			return runtimeError(i, "%v", err)
+		}
+
+		result = os.Getenv(operands[0].(string))
+	case *ir.Call:
+		result, err := in.call(i, operands)
+		if err != nil || i.Dst == nil {
+			return err
+		}
+
+		f.values[i.Dst.ID] = result
+		return nil
+	case *ir.Say:
+		sep, end := " ", "\n"
+		options := operands[len(i.Args):]
+		if i.Sep != nil {
+			if err := checkElement(options[0], i.Sep, types.Str); err != nil {
+				return runtimeError(i, "%v", err)
+			}
+			sep, options = options[0].(string), options[1:]
+		}
+		if i.End != nil {
+			if err := checkElement(options[0], i.End, types.Str); err != nil {
+				return runtimeError(i, "%v", err)
+			}
+			end = options[0].(string)
+		}
+
+		texts := []string{}
+		for _, arg := range operands[:len(i.Args)] {
+			texts = append(texts, value.Display(arg))
+		}
+
+		fmt.Fprint(in.out, strings.Join(texts, sep)+end)
+		return nil
+	default:
+		return runtimeError(instr, "unsupported instruction %T", instr)
+	}
+
+	if err != nil {
+		return runtimeError(instr, "%v", err)
+	}
+
+	f.values[ir.Defines(instr).ID] = result
+	return nil
+}
+
+// checks that a value read from an operand of no static type, like a list
+// element, has the type it is used as
+func checkElement(v any, operand ir.Value, want types.Type) error {
+	if !types.IsUnknown(operand.ValueType()) || types.IsUnknown(want) {
+		return nil
+	}
+
+	return value.Check(v, types.Erase(want).String())
+}
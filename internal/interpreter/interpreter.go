@@ -0,0 +1,139 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+
+	"simplescript/internal/ast"
+	"simplescript/internal/ir"
+	"simplescript/internal/types"
+)
+
+// Executes analyzed programs by lowering them to IR and running its blocks
+// directly, without generating Go code. Top-level variables outlive the
+// program that declared them, so later programs run by the same
+// interpreter, like REPL entries, can keep using them.
+type Interpreter struct {
+	globals map[string]any
+	out io.Writer
+	args []string
+	depth int // how many function calls are running
+}
+
+// the deepest chain of calls a program may make before it is stopped
+const maxCallDepth = 100000
+
+func NewInterpreter(out io.Writer) *Interpreter {
+	return &Interpreter{
+		globals: map[string]any{},
+		out: out,
+	}
+}
+
+// sets the command-line arguments args() returns
+func (in *Interpreter) WithArgs(args []string) *Interpreter {
+	in.args = args
+	return in
+}
+
+func (in *Interpreter) Run(prog *ast.Program) error {
+	program, err := ir.Build(prog)
+	if err != nil {
+		return err
+	}
+
+	_, err = in.execute(program)
+	return err
+}
+
+// evaluates a single expression against the current variables
+func (in *Interpreter) Eval(expr ast.Expression) (any, error) {
+	program, result, err := ir.BuildExpression(expr.Span().File, expr)
+	if err != nil {
+		return nil, err
+	}
+
+	frame, err := in.execute(program)
+	if err != nil {
+		return nil, err
+	}
+
+	return frame.read(result, expr.Span())
+}
+
+func (in *Interpreter) execute(program *ir.Program) (f frame, err error) {
+	f = frame{values: make([]any, len(program.Locals))}
+
+	for _, local := range program.Locals {
+		if local.Outer {
+			f.values[local.ID] = in.globals[local.Name]
+		}
+	}
+
+	// assignments to earlier declarations stick even when a runtime error
+	// stops the program, but its own declarations only persist once it
+	// finishes, the way a REPL only keeps the names of a successful entry
+	defer func() {
+		for _, local := range program.Locals {
+			if (local.Outer || local.TopLevel && err == nil) && f.values[local.ID] != nil {
+				in.globals[local.Name] = f.values[local.ID]
+			}
+		}
+	}()
+
+	_, err = in.run(&program.Body, f)
+	return f, err
+}
+
+// runs the blocks of a body in f and returns the value it returned, if any
+func (in *Interpreter) run(body *ir.Body, f frame) (any, error) {
+	block := body.Blocks[0]
+	for {
+		for _, instr := range block.Instrs {
+			if err := in.exec(f, instr); err != nil {
+				return nil, err
+			}
+		}
+
+		switch t := block.Term.(type) {
+		case *ir.Jump:
+			block = t.Target
+		case *ir.Branch:
+			cond, err := f.read(t.Cond, t.Span())
+			if err != nil {
+				return nil, err
+			}
+			if err := checkElement(cond, t.Cond, types.Bool); err != nil {
+				return nil, runtimeError(t, "%v", err)
+			}
+
+			if cond == true {
+				block = t.Then
+			} else {
+				block = t.Else
+			}
+		case *ir.Return:
+			if t.Value == nil {
+				return nil, nil
+			}
+
+			return f.read(t.Value, t.Span())
+		default:
+			return nil, fmt.Errorf("block b%d has no terminator", block.Index)
+		}
+	}
+}
+
+// calls fn with the arguments in a frame of its own
+func (in *Interpreter) call(call *ir.Call, args []any) (any, error) {
+	if in.depth == maxCallDepth {
+		return nil, runtimeError(call, "stack overflow: more than %d nested calls", maxCallDepth)
+	}
+
+	in.depth++
+	defer func() { in.depth-- }()
+
+	f := frame{values: make([]any, len(call.Func.Locals))}
+	copy(f.values, args)
+	return in.run(&call.Func.Body, f)
+}
@@ -0,0 +1,105 @@
+package interpreter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"simplescript/internal/testutil"
+)
+
+func run(t *testing.T, input string) (string, error) {
+	t.Helper()
+
+	var out bytes.Buffer
+	err := NewInterpreter(&out).Run(testutil.Analyze(t, input))
+	return out.String(), err
+}
+
+func TestPrograms(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+		expected string
+	}{
+		{"arithmetic", `say((10 + 20) * 2, 7 / 2, 0.5 * 3.0, -4)`, "60 3 1.5 -4\n"},
+		{"strings", `var s: str = "Simple" say(s + "Script", s < "Z")`, "SimpleScript true\n"},
+		{"lists", `var xs: list = [1, "a", true] xs[1] = 2.5 say(xs, xs[0])`, "[1, 2.5, true] 1\n"},
+		{"swap", `var a: int = 1 var b: int = 2 a, b = b, a say(a, b)`, "2 1\n"},
+		{"augmented", `var n: int = 10 n += 5 n *= 2 n -= 1 n /= 3 say(n)`, "9\n"},
+		{"shadowing", `var x: int = 1 if true { var x: str = "inner" say(x) } say(x)`, "inner\n1\n"},
+		{
+			"loops",
+			`var sum: int = 0
+			for i in 0..10 {
+				if i == 2 { continue }
+				if i == 5 { break }
+				sum += i
+			}
+			say(sum)`,
+			"8\n",
+		},
+		{"else if", `var n: int = 5 if n > 10 { say("big") } else if n > 3 { say("mid") } else { say("small") }`, "mid\n"},
+		{
+			"functions",
+			`say(fib(10), first([1.5]))
+			log("done")
+			func fib(n: int) int { if n < 2 { return n } return fib(n - 1) + fib(n - 2) }
+			func first(xs: list) float { return xs[0] }
+			func log(s: str) { if s == "" { return } say(s) }`,
+			"55 1.5\ndone\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := run(t, tt.input)
+			if err != nil {
+				t.Fatalf("unexpected runtime error: %v", err)
+			}
+
+			if out != tt.expected {
+				t.Errorf("unexpected output.\nExpected: %q\nGot: %q", tt.expected, out)
+			}
+		})
+	}
+}
+
+func TestArgsAndEnv(t *testing.T) {
+	t.Setenv("SS_TEST_NAME", "simple")
+
+	program := testutil.Analyze(t, `var a: list = args() say(a, a[1] + "!", env("SS_TEST_NAME"), env("SS_TEST_UNSET") == "")`)
+
+	var out bytes.Buffer
+	if err := NewInterpreter(&out).WithArgs([]string{"one", "two"}).Run(program); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "[\"one\", \"two\"] two! simple true\n"; out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string
+	}{
+		{"var z: int = 0\nsay(1 / z)", "RuntimeError: integer divide by zero at test.ss:2:5"},
+		{"var xs: list = [1, 2, 3]\nsay(xs[5])", "RuntimeError: index 5 out of range for list of length 3 at test.ss:2:5"},
+		{"var n: int = 9223372036854775807\nsay(n * 2)", "RuntimeError: integer overflow at test.ss:2:5"},
+		{"var xs: list = [1]\nsay(env(xs[0]))", "RuntimeError: type mismatch: cannot use int as str at test.ss:2:5"},
+		{"f(1)\nfunc f(n: int) { f(n + 1) }", "RuntimeError: stack overflow: more than 100000 nested calls"},
+	}
+
+	for _, tt := range tests {
+		_, err := run(t, tt.input)
+		if err == nil {
+			t.Fatalf("expected runtime error for %q", tt.input)
+		}
+
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("unexpected error.\nExpected: %q\nGot: %q", tt.expected, err.Error())
+		}
+	}
+}
@@ -0,0 +1,741 @@
+package ir
+
+import (
+	"fmt"
+
+	"simplescript/internal/ast"
+	"simplescript/internal/types"
+	"simplescript/internal/value"
+)
+
+// where `break` and `continue` jump inside the innermost loop
+type loopTargets struct {
+	exit, next *Block
+}
+
+type builder struct {
+	prog *Program
+	body *Body // the script's or the function's being lowered
+	fn *Func // the function being lowered, nil for the script
+	current *Block
+	locals map[*ast.Symbol]*Local
+	depth int // how many blocks enclose the current statement
+	loops []loopTargets
+	funcs map[*ast.Symbol]*Func // shared by the builders of every body in prog
+}
+
+func newBuilder(file string) *builder {
+	b := &builder{
+		prog: &Program{File: file},
+		locals: map[*ast.Symbol]*Local{},
+		funcs: map[*ast.Symbol]*Func{},
+	}
+
+	b.body = &b.prog.Body
+	b.start(&Block{})
+	return b
+}
+
+// Lowers an analyzed program, using the symbols the analyzer resolved each
+// name to. Symbols it reads without declaring become Outer locals, which
+// lets a REPL run entries against earlier declarations.
+func Build(prog *ast.Program) (*Program, error) {
+	b := newBuilder(prog.Span().File)
+
+	// declared functions keep their order even when called before they appear
+	for _, stmt := range prog.Statements {
+		if decl, ok := stmt.(*ast.FuncDecl); ok {
+			if _, err := b.function(decl.Symbol); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, stmt := range prog.Statements {
+		if err := b.statement(stmt); err != nil {
+			return nil, err
+		}
+	}
+
+	b.terminate(&Return{Pos{prog.Span()}, nil})
+	b.finish()
+
+	if err := b.lowerFuncs(); err != nil {
+		return nil, err
+	}
+
+	return b.prog, nil
+}
+
+// Lowers a standalone analyzed expression into a program that computes it,
+// and returns the value holding the result once the program has run
+func BuildExpression(file string, expr ast.Expression) (*Program, Value, error) {
+	b := newBuilder(file)
+
+	result, err := b.expression(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b.terminate(&Return{Pos{expr.Span()}, nil})
+	b.finish()
+
+	if err := b.lowerFuncs(); err != nil {
+		return nil, nil, err
+	}
+
+	return b.prog, result, nil
+}
+
+// the function a symbol declares; its body is lowered later by lowerFuncs
+func (b *builder) function(symbol *ast.Symbol) (*Func, error) {
+	if symbol == nil || symbol.Func == nil {
+		return nil, fmt.Errorf("function declaration was not resolved by the analyzer")
+	}
+
+	if fn, ok := b.funcs[symbol]; ok {
+		return fn, nil
+	}
+
+	fn := &Func{
+		Name: symbol.Name,
+		Result: symbol.Type.(*types.Func).Result,
+		Exported: symbol.Func.Exported,
+		decl: symbol.Func,
+	}
+
+	b.funcs[symbol] = fn
+	b.prog.Funcs = append(b.prog.Funcs, fn)
+	return fn, nil
+}
+
+// lowers the body of every function in prog, including the ones found
+// while lowering others
+func (b *builder) lowerFuncs() error {
+	for i := 0; i < len(b.prog.Funcs); i++ {
+		fn := b.prog.Funcs[i]
+		if fn.Blocks != nil {
+			continue
+		}
+
+		// nothing in a function outlives a call, so it starts nested
+		fb := &builder{
+			prog: b.prog,
+			body: &fn.Body,
+			fn: fn,
+			locals: map[*ast.Symbol]*Local{},
+			depth: 1,
+			funcs: b.funcs,
+		}
+		fb.start(&Block{})
+
+		for _, param := range fn.decl.Params {
+			local, err := fb.declare(param.Symbol)
+			if err != nil {
+				return err
+			}
+
+			fn.Params = append(fn.Params, local)
+		}
+
+		for _, stmt := range fn.decl.Body.Statements {
+			if err := fb.statement(stmt); err != nil {
+				return fmt.Errorf("in function %s: %v", fn.Name, err)
+			}
+		}
+
+		// the analyzer made sure this is unreachable in functions with a result
+		fb.terminate(&Return{Pos{fn.decl.Body.Span()}, nil})
+		fb.finish()
+	}
+
+	return nil
+}
+
+// drops blocks no path reaches, like the rest of a loop body after `break`
+func (b *builder) finish() {
+	reachable := map[*Block]bool{}
+
+	var visit func(block *Block)
+	visit = func(block *Block) {
+		if reachable[block] {
+			return
+		}
+
+		reachable[block] = true
+		for _, next := range block.Successors() {
+			visit(next)
+		}
+	}
+	visit(b.body.Blocks[0])
+
+	blocks := []*Block{}
+	for _, block := range b.body.Blocks {
+		if reachable[block] {
+			block.Index = len(blocks)
+			blocks = append(blocks, block)
+		}
+	}
+
+	b.body.Blocks = blocks
+}
+
+// places block after the ones already laid out and continues emitting there
+func (b *builder) start(block *Block) {
+	block.Index = len(b.body.Blocks)
+	b.body.Blocks = append(b.body.Blocks, block)
+	b.current = block
+}
+
+func (b *builder) emit(instr Instr) {
+	b.current.Instrs = append(b.current.Instrs, instr)
+}
+
+// ends the current block; whatever follows lands in a fresh, unreachable one
+func (b *builder) terminate(term Terminator) {
+	b.current.Term = term
+	b.start(&Block{})
+}
+
+func (b *builder) jump(node ast.Node, target *Block) {
+	b.terminate(&Jump{Pos{node.Span()}, target})
+}
+
+func (b *builder) newLocal(name string, dataType types.Type) *Local {
+	local := &Local{ID: len(b.body.Locals), Name: name, Type: dataType}
+	b.body.Locals = append(b.body.Locals, local)
+	return local
+}
+
+func (b *builder) temp(dataType types.Type) *Local {
+	return b.newLocal("", dataType)
+}
+
+func (b *builder) declare(symbol *ast.Symbol) (*Local, error) {
+	if symbol == nil {
+		return nil, fmt.Errorf("declaration was not resolved by the analyzer")
+	}
+
+	local := b.newLocal(symbol.Name, symbol.Type)
+	local.Const = symbol.Const
+	local.TopLevel = b.depth == 0
+	b.locals[symbol] = local
+	return local, nil
+}
+
+func (b *builder) resolve(ident *ast.Identifier) (*Local, error) {
+	if ident.Symbol == nil {
+		return nil, fmt.Errorf("undefined variable '%s'", ident.Value)
+	}
+
+	if local, ok := b.locals[ident.Symbol]; ok {
+		return local, nil
+	}
+
+	local := b.newLocal(ident.Symbol.Name, ident.Symbol.Type)
+	local.Const = ident.Symbol.Const
+	local.Outer = true
+	b.locals[ident.Symbol] = local
+	return local, nil
+}
+
+func (b *builder) enterScope() { b.depth++ }
+func (b *builder) exitScope() { b.depth-- }
+
+func (b *builder) statement(stmt ast.Statement) error {
+	switch s := stmt.(type) {
+	case *ast.VarDecl:
+		// the initializer still sees a variable the declaration shadows
+		value, err := b.expression(s.Value)
+		if err != nil {
+			return err
+		}
+
+		local, err := b.declare(s.Symbol)
+		if err != nil {
+			return err
+		}
+
+		b.emit(&Copy{Pos{s.Span()}, local, value})
+
+	case *ast.Assignment: return b.assignment(s)
+
+	case *ast.SayStmt:
+		args, err := b.expressions(s.Args)
+		if err != nil {
+			return err
+		}
+
+		say := &Say{Pos: Pos{s.Span()}, Args: args}
+		if say.Sep, err = b.option(s.Sep); err != nil {
+			return err
+		}
+		if say.End, err = b.option(s.End); err != nil {
+			return err
+		}
+
+		b.emit(say)
+
+	case *ast.IfStmt:
+		join := &Block{}
+		return b.ifStatement(s, join)
+
+	case *ast.ForStmt:
+		start, err := b.expression(s.Start)
+		if err != nil {
+			return err
+		}
+
+		b.enterScope()
+		defer b.exitScope()
+
+		iterator, err := b.declare(s.Symbol)
+		if err != nil {
+			return err
+		}
+
+		b.emit(&Copy{Pos{s.Start.Span()}, iterator, start})
+
+		head, body, step, exit := &Block{}, &Block{}, &Block{}, &Block{}
+		b.jump(s, head)
+
+		// the end is re-evaluated on every pass, like the Go loop condition
+		b.start(head)
+		end, err := b.expression(s.End)
+		if err != nil {
+			return err
+		}
+
+		more := b.temp(types.Bool)
+		b.emit(&Binary{Pos{s.End.Span()}, more, "<", iterator, end})
+		b.terminate(&Branch{Pos{s.End.Span()}, more, body, exit})
+
+		b.start(body)
+		b.loops = append(b.loops, loopTargets{exit: exit, next: step})
+		err = b.block(s.Body)
+		b.loops = b.loops[:len(b.loops)-1]
+		if err != nil {
+			return err
+		}
+		b.jump(s, step)
+
+		b.start(step)
+		b.emit(&Binary{Pos{s.Span()}, iterator, "+", iterator, &Const{int64(1), types.Int}})
+		b.jump(s, head)
+
+		b.start(exit)
+
+	case *ast.Block: return b.block(s)
+
+	case *ast.BreakStmt:
+		if len(b.loops) == 0 {
+			return fmt.Errorf("break outside of a loop")
+		}
+		b.jump(s, b.loops[len(b.loops)-1].exit)
+
+	case *ast.ContinueStmt:
+		if len(b.loops) == 0 {
+			return fmt.Errorf("continue outside of a loop")
+		}
+		b.jump(s, b.loops[len(b.loops)-1].next)
+
+	case *ast.ReturnStmt:
+		var value Value
+		if s.ReturnValue != nil {
+			v, err := b.expression(s.ReturnValue)
+			if err != nil {
+				return err
+			}
+
+			// the value of a top-level return is ignored
+			if b.fn != nil {
+				value = b.coerce(Pos{s.Span()}, v, b.fn.Result)
+			}
+		}
+
+		b.terminate(&Return{Pos{s.Span()}, value})
+
+	case *ast.CallStmt:
+		_, err := b.call(Pos{s.Span()}, s.Call)
+		return err
+
+	// lowered by Build, after the script's body
+	case *ast.FuncDecl:
+
+	default:
+		return fmt.Errorf("unsupported statement %T", stmt)
+	}
+
+	return nil
+}
+
+func (b *builder) block(block *ast.Block) error {
+	b.enterScope()
+	defer b.exitScope()
+
+	for _, stmt := range block.Statements {
+		if err := b.statement(stmt); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lowers an if/else-if chain whose branches all continue at join
+func (b *builder) ifStatement(s *ast.IfStmt, join *Block) error {
+	cond, err := b.expression(s.Condition)
+	if err != nil {
+		return err
+	}
+
+	then, otherwise := &Block{}, join
+	if s.Alternative != nil {
+		otherwise = &Block{}
+	}
+
+	b.terminate(&Branch{Pos{s.Condition.Span()}, cond, then, otherwise})
+
+	b.start(then)
+	if err := b.block(s.Consequence); err != nil {
+		return err
+	}
+	b.jump(s, join)
+
+	switch alt := s.Alternative.(type) {
+	case nil:
+	case *ast.IfStmt:
+		b.start(otherwise)
+		return b.ifStatement(alt, join)
+	default:
+		b.start(otherwise)
+		if err := b.statement(alt); err != nil {
+			return err
+		}
+		b.jump(s, join)
+	}
+
+	b.start(join)
+	return nil
+}
+
+// a resolved assignment destination: a variable or a list slot
+type target struct {
+	local *Local
+	list, index Value
+}
+
+// Evaluates every target operand and value before storing anything, so
+// `a, b = b, a` swaps and `xs[i], i = 1, 0` writes to the old index
+func (b *builder) assignment(s *ast.Assignment) error {
+	if len(s.Targets) != len(s.Values) {
+		return fmt.Errorf(
+			"assignment mismatch: %d variables but %d values",
+			len(s.Targets),
+			len(s.Values),
+		)
+	}
+
+	multiple := len(s.Targets) > 1
+	pos := Pos{s.Span()}
+
+	targets := []target{}
+	for _, t := range s.Targets {
+		switch t := t.(type) {
+		case *ast.Identifier:
+			local, err := b.resolve(t)
+			if err != nil {
+				return err
+			}
+
+			targets = append(targets, target{local: local})
+		case *ast.IndexExpression:
+			list, err := b.expression(t.Left)
+			if err != nil {
+				return err
+			}
+
+			index, err := b.expression(t.Index)
+			if err != nil {
+				return err
+			}
+
+			if multiple {
+				list, index = b.snapshot(pos, list), b.snapshot(pos, index)
+			}
+
+			targets = append(targets, target{list: list, index: index})
+		default:
+			return fmt.Errorf("cannot assign to %T", t)
+		}
+	}
+
+	values := []Value{}
+	for _, v := range s.Values {
+		value, err := b.expression(v)
+		if err != nil {
+			return err
+		}
+
+		if multiple {
+			value = b.snapshot(pos, value)
+		}
+
+		values = append(values, value)
+	}
+
+	for i, t := range targets {
+		value := values[i]
+		op := s.Operator[:1]
+
+		switch {
+		case t.local != nil && s.Operator != "=":
+			// an operator on a list element yields an element, which is
+			// checked against the variable's type like any other
+			if dataType := binaryType(op, t.local, value); types.IsUnknown(dataType) && !types.IsUnknown(t.local.Type) {
+				result := b.temp(dataType)
+				b.emit(&Binary{pos, result, op, t.local, value})
+				b.emit(&Copy{pos, t.local, result})
+				continue
+			}
+
+			b.emit(&Binary{pos, t.local, op, t.local, value})
+		case t.local != nil:
+			b.emit(&Copy{pos, t.local, value})
+		default:
+			if s.Operator != "=" {
+				element := b.temp(types.Unknown)
+				b.emit(&Load{pos, element, t.list, t.index})
+
+				result := b.temp(binaryType(op, element, value))
+				b.emit(&Binary{pos, result, op, element, value})
+				value = result
+			}
+
+			b.emit(&Store{pos, t.list, t.index, value})
+		}
+	}
+
+	return nil
+}
+
+// copies a variable into a temporary so later stores cannot change it
+func (b *builder) snapshot(pos Pos, value Value) Value {
+	local, ok := value.(*Local)
+	if !ok || local.IsTemp() {
+		return value
+	}
+
+	tmp := b.temp(local.Type)
+	b.emit(&Copy{pos, tmp, local})
+	return tmp
+}
+
+func (b *builder) expressions(exprs []ast.Expression) ([]Value, error) {
+	values := []Value{}
+
+	for _, expr := range exprs {
+		value, err := b.expression(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+func (b *builder) expression(expr ast.Expression) (Value, error) {
+	pos := Pos{expr.Span()}
+
+	switch e := expr.(type) {
+	case *ast.IntegerLiteral: return &Const{e.Value, types.Int}, nil
+	case *ast.FloatLiteral: return &Const{e.Value, types.Float}, nil
+	case *ast.StringLiteral: return &Const{e.Value, types.Str}, nil
+	case *ast.BooleanLiteral: return &Const{e.Value, types.Bool}, nil
+	case *ast.ListLiteral:
+		elems, err := b.expressions(e.Elements)
+		if err != nil {
+			return nil, err
+		}
+
+		dst := b.temp(types.AnyList)
+		b.emit(&MakeList{pos, dst, elems})
+		return dst, nil
+	case *ast.Identifier: return b.resolve(e)
+	case *ast.PrefixExpression:
+		x, err := b.expression(e.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		dst := b.temp(x.ValueType())
+		b.emit(&Unary{pos, dst, e.Operator, x})
+		return dst, nil
+	case *ast.InfixExpression:
+		x, err := b.expression(e.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := b.expression(e.Right)
+		if err != nil {
+			return nil, err
+		}
+
+		dst := b.temp(binaryType(e.Operator, x, y))
+		b.emit(&Binary{pos, dst, e.Operator, x, y})
+		return dst, nil
+	case *ast.IndexExpression:
+		list, err := b.expression(e.Left)
+		if err != nil {
+			return nil, err
+		}
+
+		index, err := b.expression(e.Index)
+		if err != nil {
+			return nil, err
+		}
+
+		dst := b.temp(types.Unknown)
+		b.emit(&Load{pos, dst, list, index})
+		return dst, nil
+	case *ast.CallExpression: return b.call(pos, e)
+	}
+
+	return nil, fmt.Errorf("unsupported expression %T", expr)
+}
+
+// lowers an optional expression, leaving nil when it is omitted
+func (b *builder) option(expr ast.Expression) (Value, error) {
+	if expr == nil {
+		return nil, nil
+	}
+
+	return b.expression(expr)
+}
+
+// lowers a call of a builtin or declared function; the value is nil when
+// the function returns nothing
+func (b *builder) call(pos Pos, call *ast.CallExpression) (Value, error) {
+	if call.Symbol != nil {
+		fn, err := b.function(call.Symbol)
+		if err != nil {
+			return nil, err
+		}
+
+		args, err := b.expressions(call.Args)
+		if err != nil {
+			return nil, err
+		}
+
+		params := call.Symbol.Type.(*types.Func).Params
+		if len(args) != len(params) {
+			return nil, fmt.Errorf("function %s expects %d arguments, got %d", fn.Name, len(params), len(args))
+		}
+
+		for i := range args {
+			args[i] = b.coerce(pos, args[i], params[i])
+		}
+
+		instr := &Call{Pos: pos, Func: fn, Args: args}
+		if fn.Result != nil {
+			instr.Dst = b.temp(fn.Result)
+		}
+
+		b.emit(instr)
+
+		if instr.Dst == nil {
+			return nil, nil
+		}
+		return instr.Dst, nil
+	}
+
+	switch {
+	case call.Function == "format" && len(call.Args) > 0: return b.format(pos, call)
+	case call.Function == "args" && len(call.Args) == 0:
+		dst := b.temp(types.AnyList)
+		b.emit(&Args{pos, dst})
+		return dst, nil
+	case call.Function == "env" && len(call.Args) == 1:
+		name, err := b.expression(call.Args[0])
+		if err != nil {
+			return nil, err
+		}
+
+		dst := b.temp(types.Str)
+		b.emit(&Env{pos, dst, name})
+		return dst, nil
+	}
+
+	return nil, fmt.Errorf("unsupported call of %s", call.Function)
+}
+
+// lowers format(template, args...) to the concatenation of the template's
+// literal pieces with the displayed text of each argument
+func (b *builder) format(pos Pos, call *ast.CallExpression) (Value, error) {
+	template, ok := call.Args[0].(*ast.StringLiteral)
+	if !ok {
+		return nil, fmt.Errorf("the template of format must be a string literal")
+	}
+
+	pieces, err := value.SplitFormat(template.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := b.expressions(call.Args[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) != len(pieces)-1 {
+		return nil, fmt.Errorf("format string has %d placeholders but %d values were given", len(pieces)-1, len(args))
+	}
+
+	var result Value = &Const{pieces[0], types.Str}
+	concat := func(x Value) {
+		dst := b.temp(types.Str)
+		b.emit(&Binary{pos, dst, "+", result, x})
+		result = dst
+	}
+
+	for i, arg := range args {
+		text := b.temp(types.Str)
+		b.emit(&Display{pos, text, arg})
+
+		if pieces[i] == "" && i == 0 {
+			result = text
+		} else {
+			concat(text)
+		}
+
+		if pieces[i+1] != "" {
+			concat(&Const{pieces[i+1], types.Str})
+		}
+	}
+
+	return result, nil
+}
+
+// copies a list element into a temporary of the static type a parameter or
+// result needs, so the copy checks the element's type at runtime
+func (b *builder) coerce(pos Pos, value Value, want types.Type) Value {
+	if !types.IsUnknown(value.ValueType()) || types.IsUnknown(want) {
+		return value
+	}
+
+	tmp := b.temp(want)
+	b.emit(&Copy{pos, tmp, value})
+	return tmp
+}
+
+// comparisons are bools; arithmetic on list elements is only known at runtime
+func binaryType(op string, x, y Value) types.Type {
+	switch {
+	case IsComparison(op): return types.Bool
+	case types.IsUnknown(x.ValueType()) || types.IsUnknown(y.ValueType()): return types.Unknown
+	default: return x.ValueType()
+	}
+}
@@ -0,0 +1,257 @@
+// Package ir defines the typed intermediate representation the backends
+// consume: three-address code grouped into basic blocks. Every variable
+// declaration becomes its own Local, so scoping and shadowing are resolved
+// once here instead of by name in each backend.
+package ir
+
+import (
+	"simplescript/internal/ast"
+	"simplescript/internal/types"
+)
+
+// a lowered script: its own body and the functions it declares or calls
+type Program struct {
+	File string
+	Body
+	Funcs []*Func
+}
+
+// the locals and the blocks of a script or function; Blocks[0] is the entry
+type Body struct {
+	Locals []*Local
+	Blocks []*Block
+}
+
+// a lowered function, whose Params are its first Locals
+type Func struct {
+	Name string
+	Params []*Local
+	Result types.Type // nil when the function returns nothing
+	Exported bool
+	Body
+	decl *ast.FuncDecl // lowered by Build once the script's body is done
+}
+
+// a typed storage slot: a declared variable or a compiler temporary
+type Local struct {
+	ID int
+	Name string // empty for temporaries
+	Type types.Type
+	Const bool
+	// declared by a previous program sharing the same variables, like an
+	// earlier REPL entry, and resolved by name when the program starts
+	Outer bool
+	// declared at the top level, so it outlives the program in a REPL
+	TopLevel bool
+}
+
+func (l *Local) IsTemp() bool { return l.Name == "" }
+
+// an instruction operand: a Local or a Const
+type Value interface {
+	ValueType() types.Type
+}
+
+// a literal: int64, float64, string or bool
+type Const struct {
+	Value any
+	Type types.Type
+}
+
+func (l *Local) ValueType() types.Type { return l.Type }
+func (c *Const) ValueType() types.Type { return c.Type }
+
+// a straight-line run of instructions ending in exactly one terminator
+type Block struct {
+	Index int
+	Instrs []Instr
+	Term Terminator
+}
+
+type Instr interface {
+	Span() ast.Span
+	instr()
+}
+
+type Terminator interface {
+	Span() ast.Span
+	terminator()
+}
+
+// the source range an instruction was lowered from, used for runtime errors
+type Pos struct {
+	Loc ast.Span
+}
+
+func (p Pos) Span() ast.Span { return p.Loc }
+
+// Dst = Src
+type Copy struct {
+	Pos
+	Dst *Local
+	Src Value
+}
+
+// Dst = Op X, where Op is "-"
+type Unary struct {
+	Pos
+	Dst *Local
+	Op string
+	X Value
+}
+
+// Dst = X Op Y for arithmetic and comparison operators
+type Binary struct {
+	Pos
+	Dst *Local
+	Op string
+	X, Y Value
+}
+
+// Dst = [Elems...]
+type MakeList struct {
+	Pos
+	Dst *Local
+	Elems []Value
+}
+
+// Dst = List[Index], bounds-checked
+type Load struct {
+	Pos
+	Dst *Local
+	List, Index Value
+}
+
+// List[Index] = Value, bounds-checked
+type Store struct {
+	Pos
+	List, Index, Value Value
+}
+
+// Dst = the text say prints for X, in the canonical display format
+type Display struct {
+	Pos
+	Dst *Local
+	X Value
+}
+
+// prints Args separated by Sep and followed by End, which are str values
+// or nil for the defaults: a space and a newline
+type Say struct {
+	Pos
+	Args []Value
+	Sep, End Value
+}
+
+// Dst = the command-line arguments of the program, a list of str
+type Args struct {
+	Pos
+	Dst *Local
+}
+
+// Dst = the environment variable Name, or "" when it is not set
+type Env struct {
+	Pos
+	Dst *Local
+	Name Value
+}
+
+// Dst = Func(Args...), where each argument has the type of its parameter and
+// Dst, the type of the result, is nil when the function returns nothing
+type Call struct {
+	Pos
+	Dst *Local
+	Func *Func
+	Args []Value
+}
+
+func (*Copy) instr() {}
+func (*Unary) instr() {}
+func (*Binary) instr() {}
+func (*MakeList) instr() {}
+func (*Load) instr() {}
+func (*Store) instr() {}
+func (*Display) instr() {}
+func (*Say) instr() {}
+func (*Args) instr() {}
+func (*Env) instr() {}
+func (*Call) instr() {}
+
+type Jump struct {
+	Pos
+	Target *Block
+}
+
+// continues at Then when Cond is true and at Else otherwise
+type Branch struct {
+	Pos
+	Cond Value
+	Then, Else *Block
+}
+
+// ends the program, or returns Value from a function; Value is nil when
+// there is none
+type Return struct {
+	Pos
+	Value Value
+}
+
+func (*Jump) terminator() {}
+func (*Branch) terminator() {}
+func (*Return) terminator() {}
+
+// the blocks control can continue at after b
+func (b *Block) Successors() []*Block {
+	switch t := b.Term.(type) {
+	case *Jump: return []*Block{t.Target}
+	case *Branch: return []*Block{t.Then, t.Else}
+	}
+
+	return nil
+}
+
+// the local an instruction assigns, if any
+func Defines(instr Instr) *Local {
+	switch i := instr.(type) {
+	case *Copy: return i.Dst
+	case *Unary: return i.Dst
+	case *Binary: return i.Dst
+	case *MakeList: return i.Dst
+	case *Load: return i.Dst
+	case *Display: return i.Dst
+	case *Args: return i.Dst
+	case *Env: return i.Dst
+	case *Call: return i.Dst
+	}
+
+	return nil
+}
+
+// the operands an instruction reads, in evaluation order
+func Uses(instr Instr) []Value {
+	switch i := instr.(type) {
+	case *Copy: return []Value{i.Src}
+	case *Unary: return []Value{i.X}
+	case *Binary: return []Value{i.X, i.Y}
+	case *MakeList: return i.Elems
+	case *Load: return []Value{i.List, i.Index}
+	case *Store: return []Value{i.List, i.Index, i.Value}
+	case *Display: return []Value{i.X}
+	case *Env: return []Value{i.Name}
+	case *Call: return i.Args
+	case *Say:
+		uses := append([]Value{}, i.Args...)
+		for _, option := range []Value{i.Sep, i.End} {
+			if option != nil {
+				uses = append(uses, option)
+			}
+		}
+		return uses
+	}
+
+	return nil
+}
+
+var comparisons = map[string]bool{"==": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true}
+
+func IsComparison(op string) bool { return comparisons[op] }
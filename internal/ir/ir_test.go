@@ -0,0 +1,242 @@
+package ir_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"simplescript/internal/analyzer"
+	"simplescript/internal/ir"
+	"simplescript/internal/testutil"
+	"simplescript/internal/types"
+)
+
+func build(t *testing.T, input string) *ir.Program {
+	t.Helper()
+	return buildIn(t, analyzer.NewEnvironment(), input)
+}
+
+// analyzes input against env, like a REPL entry after earlier declarations
+func buildIn(t *testing.T, env *analyzer.Environment, input string) *ir.Program {
+	t.Helper()
+
+	prog, err := ir.Build(testutil.AnalyzeIn(t, env, input))
+	if err != nil {
+		t.Fatalf("build error: %v", err)
+	}
+
+	return prog
+}
+
+func TestPrint(t *testing.T) {
+	prog := build(t, `var n: int = 3
+for i in 0..n { if i == 1 { continue } say(i) }`)
+
+	expected := `locals:
+  n.0 int
+  i.1 int
+  t2 bool
+  t3 bool
+
+b0:
+  n.0 = 3
+  i.1 = 0
+  jump b1
+
+b1:
+  t2 = i.1 < n.0
+  branch t2, b2, b6
+
+b2:
+  t3 = i.1 == 1
+  branch t3, b3, b4
+
+b3:
+  jump b5
+
+b4:
+  say i.1
+  jump b5
+
+b5:
+  i.1 = i.1 + 1
+  jump b1
+
+b6:
+  return
+`
+
+	if prog.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, prog.String())
+	}
+}
+
+func TestLowering(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+		contains []string
+	}{
+		{"shadowing gets a new local", `var x: int = 1 if true { var x: str = "a" say(x) } say(x)`, []string{"x.0 = 1", `x.1 = "a"`, "say x.1", "say x.0"}},
+		{"sibling declarations stay distinct", `if true { var v: int = 1 say(v) } if true { var v: str = "a" say(v) }`, []string{"v.0 int", "v.1 str", "say v.0", "say v.1"}},
+		{"loop start sees the outer name", `var i: int = 1 for i in i..3 { say(i) }`, []string{"i.1 = i.0", "t2 = i.1 < 3"}},
+		{"swap snapshots both values", `var a: int = 1 var b: int = 2 a, b = b, a`, []string{"t2 = b.1", "t3 = a.0", "a.0 = t2", "b.1 = t3"}},
+		{"augmented element", `var xs: list = [1] xs[0] += 2`, []string{"t2 = xs.1[0]", "t3 = t2 + 2", "xs.1[0] = t3"}},
+		{"augmented with an element", `var xs: list = [1] var n: int = 1 n += xs[0]`, []string{"t3 = xs.1[0]", "t4 = n.2 + t3", "n.2 = t4"}},
+		{"code after return is dropped", `say(1) return 0 say(2)`, []string{"say 1\n  return"}},
+		{"floats keep a decimal point", `say(2.0, "q")`, []string{`say 2.0, "q"`}},
+		{"say options", `say(1, 2, sep=", ", end="")`, []string{`say 1, 2 sep=", " end=""`}},
+		{"format concatenates displayed values", `var n: int = 2 var s: str = format("{} of {}!", n, [n])`, []string{"t1 = [n.0]", "t2 = display n.0", `t3 = t2 + " of "`, "t4 = display t1", "t5 = t3 + t4", `t6 = t5 + "!"`, "s.7 = t6"}},
+		{"args and env", `var a: list = args() var home: str = env("HOME")`, []string{"t0 = args", `t2 = env "HOME"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog := build(t, tt.input)
+			if err := ir.Verify(prog); err != nil {
+				t.Fatalf("verify: %v\n%s", err, prog)
+			}
+
+			out := prog.String()
+			for _, want := range tt.contains {
+				if !strings.Contains(out, want) {
+					t.Errorf("expected %q in:\n%s", want, out)
+				}
+			}
+		})
+	}
+}
+
+func TestOuterLocals(t *testing.T) {
+	env := analyzer.NewEnvironment()
+	env.Define("x", types.Int)
+
+	prog := buildIn(t, env, `x += 1 var y: int = x`)
+	if err := ir.Verify(prog); err != nil {
+		t.Fatal(err)
+	}
+
+	x, y := prog.Locals[0], prog.Locals[1]
+	if x.Name != "x" || !x.Outer || x.TopLevel {
+		t.Errorf("expected x to be an outer local, got %+v", x)
+	}
+	if y.Name != "y" || y.Outer || !y.TopLevel {
+		t.Errorf("expected y to be a top-level local, got %+v", y)
+	}
+}
+
+func TestVerifyExamples(t *testing.T) {
+	files, _ := filepath.Glob("../../examples/*.ss")
+	more, _ := filepath.Glob("../backend/testdata/conformance/*.ss")
+	files = append(files, more...)
+
+	if len(files) == 0 {
+		t.Skip("no examples found")
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			source, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			prog := build(t, string(source))
+			if err := ir.Verify(prog); err != nil {
+				t.Errorf("verify: %v\n%s", err, prog)
+			}
+		})
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		corrupt func(p *ir.Program)
+		expected string
+	}{
+		{"missing terminator", func(p *ir.Program) { p.Blocks[0].Term = nil }, "has no terminator"},
+		{"foreign local", func(p *ir.Program) {
+			p.Blocks[0].Instrs[1].(*ir.Copy).Dst = &ir.Local{ID: 99, Name: "y", Type: types.Int}
+		}, "is not a local of the program"},
+		{"jump outside the program", func(p *ir.Program) {
+			p.Blocks[0].Term = &ir.Jump{Target: &ir.Block{Index: 7}}
+		}, "not a block of the program"},
+		{"temp used before it is defined", func(p *ir.Program) {
+			block := p.Blocks[0]
+			block.Instrs[0], block.Instrs[1] = block.Instrs[1], block.Instrs[0]
+		}, "used before it is defined"},
+		{"constant assigned twice", func(p *ir.Program) {
+			block := p.Blocks[0]
+			block.Instrs = append(block.Instrs, block.Instrs[1])
+		}, "assigned more than once"},
+		{"mismatched types", func(p *ir.Program) {
+			p.Blocks[0].Instrs[1].(*ir.Copy).Src = &ir.Const{"a", types.Str}
+		}, "cannot copy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog := build(t, `const c: int = 1 + 2 say(c)`)
+			if err := ir.Verify(prog); err != nil {
+				t.Fatalf("valid program rejected: %v", err)
+			}
+
+			tt.corrupt(prog)
+
+			err := ir.Verify(prog)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected error containing %q, got %v\n%s", tt.expected, err, prog)
+			}
+		})
+	}
+}
+
+func TestFunctions(t *testing.T) {
+	prog := build(t, `say(twice(2))
+export func twice(n: int) int { return n * 2 }
+func first(xs: list) float { return xs[0] }`)
+	if err := ir.Verify(prog); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `locals:
+  t0 int
+
+b0:
+  t0 = call twice(2)
+  say t0
+  return
+
+export func twice(n.0 int) int:
+locals:
+  n.0 int
+  t1 int
+
+b0:
+  t1 = n.0 * 2
+  return t1
+
+func first(xs.0 list) float:
+locals:
+  xs.0 list
+  t1 unknown
+  t2 float
+
+b0:
+  t1 = xs.0[0]
+  t2 = t1
+  return t2
+`
+
+	if prog.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, prog.String())
+	}
+
+	// a function only reaches its own locals
+	prog.Funcs[0].Body.Blocks[0].Term = &ir.Return{Value: prog.Locals[0]}
+	if err := ir.Verify(prog); err == nil || !strings.Contains(err.Error(), "function twice") {
+		t.Errorf("expected the foreign local to be rejected, got %v", err)
+	}
+}
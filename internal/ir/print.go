@@ -0,0 +1,159 @@
+package ir
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Writes a readable listing of the program: its locals with their types,
+// then each block with one instruction per line, and then each function
+// the same way under its signature
+func Fprint(w io.Writer, p *Program) {
+	fprintBody(w, &p.Body)
+
+	for _, fn := range p.Funcs {
+		fmt.Fprintf(w, "\n%s:\n", FormatSignature(fn))
+		fprintBody(w, &fn.Body)
+	}
+}
+
+func fprintBody(w io.Writer, body *Body) {
+	fmt.Fprintln(w, "locals:")
+	for _, local := range body.Locals {
+		flags := ""
+		if local.Const {
+			flags += " const"
+		}
+		if local.Outer {
+			flags += " outer"
+		}
+
+		fmt.Fprintf(w, "  %s %s%s\n", local, local.Type, flags)
+	}
+
+	for _, block := range body.Blocks {
+		fmt.Fprintf(w, "\n%s:\n", block)
+
+		for _, instr := range block.Instrs {
+			fmt.Fprintf(w, "  %s\n", FormatInstr(instr))
+		}
+
+		if block.Term != nil {
+			fmt.Fprintf(w, "  %s\n", FormatTerminator(block.Term))
+		}
+	}
+}
+
+// renders the function's header, like `export func add(a.0 int, b.1 int) int`
+func FormatSignature(fn *Func) string {
+	params := []string{}
+	for _, param := range fn.Params {
+		params = append(params, fmt.Sprintf("%s %s", param, param.Type))
+	}
+
+	text := fmt.Sprintf("func %s(%s)", fn.Name, strings.Join(params, ", "))
+	if fn.Exported {
+		text = "export " + text
+	}
+	if fn.Result != nil {
+		text += " " + fn.Result.String()
+	}
+
+	return text
+}
+
+func (p *Program) String() string {
+	var b strings.Builder
+	Fprint(&b, p)
+	return b.String()
+}
+
+func (b *Block) String() string { return "b" + strconv.Itoa(b.Index) }
+
+// temporaries print as t<ID>, variables as <name>.<ID> so shadowed ones differ
+func (l *Local) String() string {
+	if l.IsTemp() {
+		return "t" + strconv.Itoa(l.ID)
+	}
+
+	return l.Name + "." + strconv.Itoa(l.ID)
+}
+
+func (c *Const) String() string {
+	switch v := c.Value.(type) {
+	case string: return strconv.Quote(v)
+	case float64:
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	}
+
+	return fmt.Sprint(c.Value)
+}
+
+func formatValue(v Value) string {
+	return v.(fmt.Stringer).String()
+}
+
+func formatValues(values []Value) string {
+	parts := []string{}
+	for _, v := range values {
+		parts = append(parts, formatValue(v))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func FormatInstr(instr Instr) string {
+	switch i := instr.(type) {
+	case *Copy: return fmt.Sprintf("%s = %s", i.Dst, formatValue(i.Src))
+	case *Unary: return fmt.Sprintf("%s = %s%s", i.Dst, i.Op, formatValue(i.X))
+	case *Binary: return fmt.Sprintf("%s = %s %s %s", i.Dst, formatValue(i.X), i.Op, formatValue(i.Y))
+	case *MakeList: return fmt.Sprintf("%s = [%s]", i.Dst, formatValues(i.Elems))
+	case *Load: return fmt.Sprintf("%s = %s[%s]", i.Dst, formatValue(i.List), formatValue(i.Index))
+	case *Store: return fmt.Sprintf("%s[%s] = %s", formatValue(i.List), formatValue(i.Index), formatValue(i.Value))
+	case *Display: return fmt.Sprintf("%s = display %s", i.Dst, formatValue(i.X))
+	case *Args: return fmt.Sprintf("%s = args", i.Dst)
+	case *Env: return fmt.Sprintf("%s = env %s", i.Dst, formatValue(i.Name))
+	case *Say: return formatSay(i)
+	case *Call:
+		call := fmt.Sprintf("call %s(%s)", i.Func.Name, formatValues(i.Args))
+		if i.Dst != nil {
+			call = fmt.Sprintf("%s = %s", i.Dst, call)
+		}
+		return call
+	}
+
+	return fmt.Sprintf("<unknown %T>", instr)
+}
+
+func formatSay(say *Say) string {
+	text := strings.TrimSpace("say " + formatValues(say.Args))
+
+	if say.Sep != nil {
+		text += " sep=" + formatValue(say.Sep)
+	}
+	if say.End != nil {
+		text += " end=" + formatValue(say.End)
+	}
+
+	return text
+}
+
+func FormatTerminator(term Terminator) string {
+	switch t := term.(type) {
+	case *Jump: return fmt.Sprintf("jump %s", t.Target)
+	case *Branch: return fmt.Sprintf("branch %s, %s, %s", formatValue(t.Cond), t.Then, t.Else)
+	case *Return:
+		if t.Value != nil {
+			return "return " + formatValue(t.Value)
+		}
+		return "return"
+	}
+
+	return fmt.Sprintf("<unknown %T>", term)
+}
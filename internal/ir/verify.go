@@ -0,0 +1,271 @@
+package ir
+
+import (
+	"fmt"
+
+	"simplescript/internal/types"
+)
+
+// Checks the invariants backends rely on: every block is terminated and
+// jumps stay inside its body, operands are locals of the body or constants,
+// temporaries are defined earlier in the block that uses them, constants
+// are assigned once, operand types fit each instruction, calls pass their
+// parameters' types and returns match the function's result.
+func Verify(p *Program) error {
+	if err := p.verify(nil, p.Funcs); err != nil {
+		return err
+	}
+
+	for _, fn := range p.Funcs {
+		if err := fn.verify(fn, p.Funcs); err != nil {
+			return fmt.Errorf("function %s: %v", fn.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// fn is the function the body belongs to, nil for the script's
+func (p *Body) verify(fn *Func, funcs []*Func) error {
+	if len(p.Blocks) == 0 {
+		return fmt.Errorf("program has no entry block")
+	}
+
+	if fn != nil {
+		for i, param := range fn.Params {
+			if i >= len(p.Locals) || p.Locals[i] != param {
+				return fmt.Errorf("parameter %s is not local %d", param, i)
+			}
+		}
+
+		for _, local := range p.Locals {
+			if local.Outer || local.TopLevel {
+				return fmt.Errorf("%s outlives the function", local)
+			}
+		}
+	}
+
+	declared := map[*Func]bool{}
+	for _, f := range funcs {
+		declared[f] = true
+	}
+
+	blocks := map[*Block]bool{}
+	for i, block := range p.Blocks {
+		if block.Index != i {
+			return fmt.Errorf("%s is stored at position %d", block, i)
+		}
+		blocks[block] = true
+	}
+
+	assigned := map[*Local]int{}
+
+	for _, block := range p.Blocks {
+		defined := map[*Local]bool{}
+
+		use := func(v Value) error {
+			switch v := v.(type) {
+			case *Const:
+				return nil
+			case *Local:
+				if !p.owns(v) {
+					return fmt.Errorf("%s is not a local of the program", v)
+				}
+
+				if v.IsTemp() && !defined[v] {
+					return fmt.Errorf("%s is used before it is defined in this block", v)
+				}
+
+				return nil
+			}
+
+			return fmt.Errorf("invalid operand %T", v)
+		}
+
+		for _, instr := range block.Instrs {
+			for _, v := range Uses(instr) {
+				if err := use(v); err != nil {
+					return fmt.Errorf("%s: %s: %v", block, FormatInstr(instr), err)
+				}
+			}
+
+			if call, ok := instr.(*Call); ok && !declared[call.Func] {
+				return fmt.Errorf("%s: %s: function is not part of the program", block, FormatInstr(instr))
+			}
+
+			if err := checkTypes(instr); err != nil {
+				return fmt.Errorf("%s: %s: %v", block, FormatInstr(instr), err)
+			}
+
+			if dst := Defines(instr); dst != nil {
+				if !p.owns(dst) {
+					return fmt.Errorf("%s: %s: %s is not a local of the program", block, FormatInstr(instr), dst)
+				}
+
+				defined[dst] = true
+				assigned[dst]++
+
+				if dst.Const && assigned[dst] > 1 {
+					return fmt.Errorf("%s: %s: constant %s is assigned more than once", block, FormatInstr(instr), dst)
+				}
+			}
+		}
+
+		switch t := block.Term.(type) {
+		case nil:
+			return fmt.Errorf("%s has no terminator", block)
+		case *Return:
+			if err := checkReturn(fn, t.Value, use); err != nil {
+				return fmt.Errorf("%s: %s: %v", block, FormatTerminator(t), err)
+			}
+		case *Branch:
+			if err := use(t.Cond); err != nil {
+				return fmt.Errorf("%s: %s: %v", block, FormatTerminator(t), err)
+			}
+
+			if !types.AssignableTo(t.Cond.ValueType(), types.Bool) {
+				return fmt.Errorf("%s: %s: condition is '%s', not bool", block, FormatTerminator(t), t.Cond.ValueType())
+			}
+		}
+
+		for _, next := range block.Successors() {
+			if !blocks[next] {
+				return fmt.Errorf("%s: %s: target is not a block of the program", block, FormatTerminator(block.Term))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p *Body) owns(l *Local) bool {
+	return l.ID >= 0 && l.ID < len(p.Locals) && p.Locals[l.ID] == l
+}
+
+func checkTypes(instr Instr) error {
+	switch i := instr.(type) {
+	case *Copy:
+		if !types.AssignableTo(i.Src.ValueType(), i.Dst.Type) {
+			return fmt.Errorf("cannot copy '%s' into '%s'", i.Src.ValueType(), i.Dst.Type)
+		}
+	case *Unary:
+		x := i.X.ValueType()
+		if i.Op != "-" || !(types.IsNumeric(x) || types.IsUnknown(x)) {
+			return fmt.Errorf("invalid operation: '%s' on '%s'", i.Op, x)
+		}
+
+		if !types.AssignableTo(x, i.Dst.Type) {
+			return fmt.Errorf("result '%s' does not fit '%s'", x, i.Dst.Type)
+		}
+	case *Binary:
+		x, y := i.X.ValueType(), i.Y.ValueType()
+
+		if !types.IsUnknown(x) && !types.IsUnknown(y) {
+			if !types.AssignableTo(x, y) && !types.AssignableTo(y, x) {
+				return fmt.Errorf("mismatched operands '%s' and '%s'", x, y)
+			}
+
+			if !operatorDefined(i.Op, x) {
+				return fmt.Errorf("operator '%s' is not defined on '%s'", i.Op, x)
+			}
+		}
+
+		// backends only check the type of an element when it is copied
+		result := binaryType(i.Op, i.X, i.Y)
+		if !types.AssignableTo(result, i.Dst.Type) || types.IsUnknown(result) && !types.IsUnknown(i.Dst.Type) {
+			return fmt.Errorf("result '%s' does not fit '%s'", result, i.Dst.Type)
+		}
+	case *MakeList:
+		if !types.IsList(i.Dst.Type) {
+			return fmt.Errorf("list assigned to '%s'", i.Dst.Type)
+		}
+	case *Load:
+		if err := checkIndex(i.List, i.Index); err != nil {
+			return err
+		}
+
+		if !types.IsUnknown(i.Dst.Type) {
+			return fmt.Errorf("list element assigned to '%s'", i.Dst.Type)
+		}
+	case *Store:
+		return checkIndex(i.List, i.Index)
+	case *Display:
+		if i.Dst.Type != types.Str {
+			return fmt.Errorf("displayed text assigned to '%s'", i.Dst.Type)
+		}
+	case *Args:
+		if !types.IsList(i.Dst.Type) {
+			return fmt.Errorf("arguments assigned to '%s'", i.Dst.Type)
+		}
+	case *Env:
+		if !types.AssignableTo(i.Name.ValueType(), types.Str) {
+			return fmt.Errorf("environment variable name must be str, got '%s'", i.Name.ValueType())
+		}
+
+		if i.Dst.Type != types.Str {
+			return fmt.Errorf("environment variable assigned to '%s'", i.Dst.Type)
+		}
+	case *Call:
+		if len(i.Args) != len(i.Func.Params) {
+			return fmt.Errorf("%d arguments for %d parameters", len(i.Args), len(i.Func.Params))
+		}
+
+		for n, arg := range i.Args {
+			want := i.Func.Params[n].Type
+			if !types.AssignableTo(arg.ValueType(), want) || types.IsUnknown(arg.ValueType()) && !types.IsUnknown(want) {
+				return fmt.Errorf("cannot pass '%s' as '%s'", arg.ValueType(), want)
+			}
+		}
+
+		if (i.Dst == nil) != (i.Func.Result == nil) || i.Dst != nil && !types.AssignableTo(i.Func.Result, i.Dst.Type) {
+			return fmt.Errorf("result of %s does not match its destination", i.Func.Name)
+		}
+	case *Say:
+		for _, option := range []Value{i.Sep, i.End} {
+			if option != nil && !types.AssignableTo(option.ValueType(), types.Str) {
+				return fmt.Errorf("say option must be str, got '%s'", option.ValueType())
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkReturn(fn *Func, value Value, use func(Value) error) error {
+	var result types.Type
+	if fn != nil {
+		result = fn.Result
+	}
+
+	switch {
+	case value == nil && result != nil: return fmt.Errorf("missing '%s' result", result)
+	case value == nil: return nil
+	case result == nil: return fmt.Errorf("returns a value from a body without a result")
+	case !types.AssignableTo(value.ValueType(), result) || types.IsUnknown(value.ValueType()) && !types.IsUnknown(result):
+		return fmt.Errorf("cannot return '%s' as '%s'", value.ValueType(), result)
+	}
+
+	return use(value)
+}
+
+func checkIndex(list, index Value) error {
+	if !types.AssignableTo(list.ValueType(), types.AnyList) {
+		return fmt.Errorf("cannot index '%s'", list.ValueType())
+	}
+
+	if !types.AssignableTo(index.ValueType(), types.Int) {
+		return fmt.Errorf("list index must be int, got '%s'", index.ValueType())
+	}
+
+	return nil
+}
+
+func operatorDefined(op string, dataType types.Type) bool {
+	switch dataType {
+	case types.Int, types.Float: return true
+	case types.Str: return op == "+" || IsComparison(op)
+	case types.Bool: return op == "==" || op == "!="
+	}
+
+	return false
+}
@@ -0,0 +1,197 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"simplescript/internal/analyzer"
+	"simplescript/internal/ast"
+	"simplescript/internal/diagnostic"
+	"simplescript/internal/frontend/lexer"
+	"simplescript/internal/frontend/parser"
+	"simplescript/internal/interpreter"
+	"simplescript/internal/types"
+	"simplescript/internal/value"
+)
+
+const (
+	prompt = ">>> "
+	continuationPrompt = "... "
+	replFile = "<repl>"
+)
+
+const helpText = `Enter statements or expressions. Declarations persist between entries.
+  :type <expr>   show the type of an expression
+  :ast <expr>    show the typed AST of an expression
+  :reset         forget every declaration
+  :help          show this help
+  :quit          leave the REPL
+`
+
+// keeps analyzer and interpreter state alive between entries
+type Session struct {
+	env *analyzer.Environment
+	interp *interpreter.Interpreter
+	out io.Writer
+}
+
+func NewSession(out io.Writer) *Session {
+	s := &Session{out: out}
+	s.Reset()
+	return s
+}
+
+func (s *Session) Reset() {
+	s.env = analyzer.NewEnvironment()
+	s.interp = interpreter.NewInterpreter(s.out)
+}
+
+// reads entries until EOF or :quit, joining lines while brackets are unbalanced
+func Start(in io.Reader, out io.Writer) error {
+	session := NewSession(out)
+	scanner := bufio.NewScanner(in)
+	entry := ""
+
+	fmt.Fprintf(out, "SimpleScript REPL. Type :help for commands.\n%s", prompt)
+
+	for scanner.Scan() {
+		entry += scanner.Text() + "\n"
+
+		if isIncomplete(entry) {
+			fmt.Fprint(out, continuationPrompt)
+			continue
+		}
+
+		if !session.Handle(entry) {
+			return nil
+		}
+
+		entry = ""
+		fmt.Fprint(out, prompt)
+	}
+
+	fmt.Fprintln(out)
+	return scanner.Err()
+}
+
+// runs one complete entry and reports whether the session should continue
+func (s *Session) Handle(entry string) bool {
+	trimmed := strings.TrimSpace(entry)
+
+	switch {
+	case trimmed == "":
+	case trimmed == ":quit" || trimmed == ":q":
+		return false
+	case trimmed == ":help":
+		fmt.Fprint(s.out, helpText)
+	case trimmed == ":reset":
+		s.Reset()
+		fmt.Fprintln(s.out, "Session reset.")
+	case strings.HasPrefix(trimmed, ":type "):
+		if _, dataType, ok := s.analyzeExpression(strings.TrimPrefix(trimmed, ":type ")); ok {
+			fmt.Fprintln(s.out, dataType)
+		}
+	case strings.HasPrefix(trimmed, ":ast "):
+		if expr, _, ok := s.analyzeExpression(strings.TrimPrefix(trimmed, ":ast ")); ok {
+			ast.Fprint(s.out, expr, true)
+		}
+	case strings.HasPrefix(trimmed, ":"):
+		fmt.Fprintf(s.out, "Unknown command '%s'. Type :help for commands.\n", trimmed)
+	default:
+		s.evaluate(entry)
+	}
+
+	return true
+}
+
+// evaluates expressions and prints their value, or executes statements
+func (s *Session) evaluate(entry string) {
+	if looksLikeExpression(entry) {
+		if expr, dataType, ok := s.analyzeExpression(entry); ok {
+			result, err := s.interp.Eval(expr)
+			if err != nil {
+				fmt.Fprintln(s.out, err)
+				return
+			}
+
+			fmt.Fprintf(s.out, "%s : %s\n", value.Repr(result), dataType)
+		}
+
+		return
+	}
+
+	p := parser.NewParser(lexer.NewFileLexer(replFile, entry))
+	program, err := p.Parse()
+	if err != nil {
+		s.report(p.Errors())
+		return
+	}
+
+	// declarations only become visible to later entries when the whole
+	// entry is valid and runs to the end, which is also when the
+	// interpreter keeps their values
+	scope := analyzer.NewEnclosedEnvironment(s.env)
+	a := analyzer.NewAnalyzerWithEnvironment(scope)
+	if err := a.Analyze(program); err != nil {
+		s.report(a.Errors())
+		return
+	}
+
+	if err := s.interp.Run(program); err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+
+	s.env.Merge(scope)
+}
+
+func (s *Session) analyzeExpression(source string) (ast.Expression, types.Type, bool) {
+	p := parser.NewParser(lexer.NewFileLexer(replFile, source))
+	expr, err := p.ParseStandaloneExpression()
+	if err != nil {
+		s.report(p.Errors())
+		return nil, nil, false
+	}
+
+	a := analyzer.NewAnalyzerWithEnvironment(s.env)
+	dataType, err := a.AnalyzeExpression(expr)
+	if err != nil {
+		s.report(a.Errors())
+		return nil, nil, false
+	}
+
+	return expr, dataType, true
+}
+
+func (s *Session) report(diags []diagnostic.Diagnostic) {
+	diagnostic.WriteText(s.out, diags, false)
+}
+
+// an entry is an expression when it parses as one with nothing left over
+func looksLikeExpression(entry string) bool {
+	p := parser.NewParser(lexer.NewLexer(entry))
+	_, err := p.ParseStandaloneExpression()
+	return err == nil
+}
+
+// true while brackets are still open or a string is unterminated
+func isIncomplete(entry string) bool {
+	depth := 0
+
+	for _, tok := range lexer.NewLexer(entry).Tokenize() {
+		switch tok.Tag {
+		case ast.TOKEN_LBRACE, ast.TOKEN_LBRACKET, ast.TOKEN_LPAREN:
+			depth++
+		case ast.TOKEN_RBRACE, ast.TOKEN_RBRACKET, ast.TOKEN_RPAREN:
+			depth--
+		case ast.TOKEN_INVALID:
+			if tok.Slice == "Unterminated string" {
+				return true
+			}
+		}
+	}
+
+	return depth > 0
+}
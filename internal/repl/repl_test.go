@@ -0,0 +1,80 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func session(t *testing.T, input string) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	if err := Start(strings.NewReader(input), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.String()
+}
+
+func TestDeclarationsPersistBetweenEntries(t *testing.T) {
+	out := session(t, "var x: int = 20\nx + 1\n:type x > 1\n")
+
+	for _, expected := range []string{"21 : int", "bool"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
+
+func TestMultiLineEntries(t *testing.T) {
+	out := session(t, "if true {\n  say(\"inside\")\n}\n")
+
+	if !strings.Contains(out, continuationPrompt) || !strings.Contains(out, "inside") {
+		t.Errorf("expected a continuation prompt and the block output, got:\n%s", out)
+	}
+}
+
+func TestInvalidEntriesDoNotDeclare(t *testing.T) {
+	out := session(t, "var y: int = \"no\"\ny\n")
+
+	if !strings.Contains(out, "undefined variable 'y'") {
+		t.Errorf("expected 'y' to stay undefined after a failed declaration, got:\n%s", out)
+	}
+}
+
+func TestFailedEntriesDoNotDeclare(t *testing.T) {
+	out := session(t, "var z: int = 1 / 0\nz\nvar n: int = 1\nvar c: int = 2 n = 5 var b: int = 1 / 0\nn\nc\nb\n")
+
+	if strings.Count(out, "integer divide by zero") != 2 {
+		t.Fatalf("expected both divisions to fail, got:\n%s", out)
+	}
+
+	// the analyzer and the interpreter agree that z, c and b were never declared
+	if strings.Contains(out, "RuntimeError: undefined variable") || strings.Count(out, "[NameError] undefined variable") != 3 {
+		t.Errorf("expected z, c and b to stay undefined, got:\n%s", out)
+	}
+
+	// an assignment to an earlier variable still happened before the error
+	if !strings.Contains(out, "5 : int") {
+		t.Errorf("expected n to keep the value assigned before the error, got:\n%s", out)
+	}
+}
+
+func TestReset(t *testing.T) {
+	out := session(t, "var z: int = 1\n:reset\nz\n")
+
+	if !strings.Contains(out, "undefined variable 'z'") {
+		t.Errorf("expected 'z' to be forgotten after :reset, got:\n%s", out)
+	}
+}
+
+func TestValuesPrintInDisplayFormat(t *testing.T) {
+	out := session(t, "[1, 'a', 2.0]\nsay(format('{}!', [1.5]))\n")
+
+	for _, expected := range []string{`[1, "a", 2.0] : list`, "[1.5]!"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, out)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Package testutil holds the helpers the tests of several packages share
+// to turn SimpleScript source into an analyzed program or its IR, and to
+// run the modules of the native wasm backend.
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+
+	"simplescript/internal/analyzer"
+	"simplescript/internal/ast"
+	"simplescript/internal/frontend/lexer"
+	"simplescript/internal/frontend/parser"
+	"simplescript/internal/ir"
+)
+
+// Analyze parses and analyzes input as the file test.ss, failing the test
+// on any syntax or semantic error
+func Analyze(t testing.TB, input string) *ast.Program {
+	t.Helper()
+	return AnalyzeIn(t, analyzer.NewEnvironment(), input)
+}
+
+// AnalyzeIn analyzes input against env, like a REPL entry after earlier
+// declarations
+func AnalyzeIn(t testing.TB, env *analyzer.Environment, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.NewParser(lexer.NewFileLexer("test.ss", input))
+	program, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+
+	a := analyzer.NewAnalyzerWithEnvironment(env)
+	if err := a.Analyze(program); err != nil {
+		t.Fatalf("semantic errors: %v", a.Errors())
+	}
+
+	return program
+}
+
+// Lower analyzes input like Analyze and lowers it to verified IR
+func Lower(t testing.TB, input string) *ir.Program {
+	t.Helper()
+
+	prog, err := ir.Build(Analyze(t, input))
+	if err == nil {
+		err = ir.Verify(prog)
+	}
+	if err != nil {
+		t.Fatalf("IR error: %v", err)
+	}
+
+	return prog
+}
+
+// RunNative instantiates a module of the native wasm backend in wazero and
+// calls its run export, with a host `print` that appends a newline to each
+// line. It returns what was printed and the error of a trap.
+func RunNative(t testing.TB, binary []byte) (string, error) {
+	t.Helper()
+
+	_, out, err := CallNative(t, binary, "run")
+	return out, err
+}
+
+// CallNative is like RunNative, but calls the export name with args in
+// their wasm encoding and also returns its results
+func CallNative(t testing.TB, binary []byte, name string, args ...uint64) ([]uint64, string, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfigInterpreter())
+	defer r.Close(ctx)
+
+	var out bytes.Buffer
+	_, err := r.NewHostModuleBuilder("$root").
+		NewFunctionBuilder().
+		WithFunc(func(ctx context.Context, m api.Module, ptr, length uint32) {
+			msg, _ := m.Memory().Read(ptr, length)
+			out.Write(msg)
+			out.WriteByte('\n')
+		}).
+		Export("print").
+		Instantiate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mod, err := r.Instantiate(ctx, binary)
+	if err != nil {
+		t.Fatalf("instantiate: %v", err)
+	}
+
+	fn := mod.ExportedFunction(name)
+	if fn == nil {
+		t.Fatalf("no export named %s", name)
+	}
+
+	results, err := fn.Call(ctx, args...)
+	return results, out.String(), err
+}
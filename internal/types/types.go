@@ -0,0 +1,229 @@
+// Package types describes SimpleScript types: the basic types, lists, maps,
+// functions, structs and optionals, with the identity and assignability
+// rules the analyzer checks programs against.
+package types
+
+import "strings"
+
+type Type interface {
+	// the type as written in source, used in diagnostics
+	String() string
+	typ()
+}
+
+type BasicKind int
+
+const (
+	UnknownKind BasicKind = iota
+	IntKind
+	FloatKind
+	StrKind
+	BoolKind
+)
+
+type Basic struct {
+	Kind BasicKind
+	Name string
+}
+
+// A list, map, func, struct or optional type is built from other types
+type List struct {
+	Elem Type
+}
+
+type Map struct {
+	Key, Value Type
+}
+
+// Result is nil for a function that returns nothing
+type Func struct {
+	Params []Type
+	Result Type
+}
+
+type Field struct {
+	Name string
+	Type Type
+}
+
+type Struct struct {
+	Fields []Field
+}
+
+// either a value of Elem or none
+type Optional struct {
+	Elem Type
+}
+
+var (
+	// the type of values only known at runtime, like list elements; it is
+	// assignable to and from every type, so errors are never reported twice
+	Unknown = &Basic{UnknownKind, "unknown"}
+	Int = &Basic{IntKind, "int"}
+	Float = &Basic{FloatKind, "float"}
+	Str = &Basic{StrKind, "str"}
+	Bool = &Basic{BoolKind, "bool"}
+
+	// `list` without an element type holds values of any type
+	AnyList = &List{Unknown}
+)
+
+// the basic types by the name a type annotation uses
+var Universe = map[string]*Basic{
+	"int": Int,
+	"float": Float,
+	"str": Str,
+	"bool": Bool,
+}
+
+func (*Basic) typ() {}
+func (*List) typ() {}
+func (*Map) typ() {}
+func (*Func) typ() {}
+func (*Struct) typ() {}
+func (*Optional) typ() {}
+
+func (b *Basic) String() string { return b.Name }
+
+func (l *List) String() string {
+	if l.Elem == Unknown {
+		return "list"
+	}
+
+	return "list[" + l.Elem.String() + "]"
+}
+
+func (m *Map) String() string { return "map[" + m.Key.String() + "]" + m.Value.String() }
+
+func (f *Func) String() string {
+	params := []string{}
+	for _, p := range f.Params {
+		params = append(params, p.String())
+	}
+
+	s := "func(" + strings.Join(params, ", ") + ")"
+	if f.Result != nil {
+		s += " " + f.Result.String()
+	}
+
+	return s
+}
+
+func (s *Struct) String() string {
+	fields := []string{}
+	for _, f := range s.Fields {
+		fields = append(fields, f.Name+": "+f.Type.String())
+	}
+
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+func (o *Optional) String() string { return o.Elem.String() + "?" }
+
+// reports whether a and b are the same type; compound types are compared
+// structurally, so two `list[int]` annotations are identical
+func Identical(a, b Type) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	switch a := a.(type) {
+	case *Basic:
+		b, ok := b.(*Basic)
+		return ok && a.Kind == b.Kind
+	case *List:
+		b, ok := b.(*List)
+		return ok && Identical(a.Elem, b.Elem)
+	case *Map:
+		b, ok := b.(*Map)
+		return ok && Identical(a.Key, b.Key) && Identical(a.Value, b.Value)
+	case *Func:
+		b, ok := b.(*Func)
+		if !ok || len(a.Params) != len(b.Params) || !Identical(a.Result, b.Result) {
+			return false
+		}
+
+		for i := range a.Params {
+			if !Identical(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+
+		return true
+	case *Struct:
+		b, ok := b.(*Struct)
+		if !ok || len(a.Fields) != len(b.Fields) {
+			return false
+		}
+
+		for i := range a.Fields {
+			if a.Fields[i].Name != b.Fields[i].Name || !Identical(a.Fields[i].Type, b.Fields[i].Type) {
+				return false
+			}
+		}
+
+		return true
+	case *Optional:
+		b, ok := b.(*Optional)
+		return ok && Identical(a.Elem, b.Elem)
+	}
+
+	return false
+}
+
+// Reports whether a value of type value can be stored in a variable of type
+// target. Unknown fits anywhere, an untyped `list` and a typed one convert
+// both ways since elements are checked when they are written, and a T can
+// be stored in a T?.
+func AssignableTo(value, target Type) bool {
+	if IsUnknown(value) || IsUnknown(target) || Identical(value, target) {
+		return true
+	}
+
+	switch t := target.(type) {
+	case *List:
+		v, ok := value.(*List)
+		return ok && (IsUnknown(t.Elem) || IsUnknown(v.Elem) || AssignableTo(v.Elem, t.Elem))
+	case *Optional:
+		if v, ok := value.(*Optional); ok {
+			return AssignableTo(v.Elem, t.Elem)
+		}
+
+		return AssignableTo(value, t.Elem)
+	}
+
+	return false
+}
+
+func IsUnknown(t Type) bool { return t == nil || isBasic(t, UnknownKind) }
+
+func IsNumeric(t Type) bool { return isBasic(t, IntKind) || isBasic(t, FloatKind) }
+
+func IsList(t Type) bool {
+	_, ok := t.(*List)
+	return ok
+}
+
+func isBasic(t Type, kind BasicKind) bool {
+	b, ok := t.(*Basic)
+	return ok && b.Kind == kind
+}
+
+// The type values have at runtime. Element types are only checked by the
+// analyzer, so every list is represented like an untyped `list`.
+func Erase(t Type) Type {
+	if IsList(t) {
+		return AnyList
+	}
+
+	return t
+}
+
+// the element type of a list, or Unknown for anything else
+func ElemOf(t Type) Type {
+	if l, ok := t.(*List); ok {
+		return l.Elem
+	}
+
+	return Unknown
+}
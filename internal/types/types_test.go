@@ -0,0 +1,83 @@
+package types
+
+import "testing"
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		typ Type
+		expected string
+	}{
+		{Int, "int"},
+		{AnyList, "list"},
+		{&List{Str}, "list[str]"},
+		{&Map{Str, &List{Float}}, "map[str]list[float]"},
+		{&Func{}, "func()"},
+		{&Func{Params: []Type{Int, Str}, Result: Bool}, "func(int, str) bool"},
+		{&Struct{Fields: []Field{{"x", Int}, {"y", &Optional{Str}}}}, "{x: int, y: str?}"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.typ.String(); got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestIdentical(t *testing.T) {
+	tests := []struct {
+		a, b Type
+		expected bool
+	}{
+		{Int, Int, true},
+		{Int, Float, false},
+		{&List{Int}, &List{Int}, true},
+		{&List{Int}, AnyList, false},
+		{&Map{Str, Int}, &Map{Str, Int}, true},
+		{&Map{Str, Int}, &Map{Int, Int}, false},
+		{&Func{Params: []Type{Int}}, &Func{Params: []Type{Int}}, true},
+		{&Func{Params: []Type{Int}}, &Func{Params: []Type{Int}, Result: Int}, false},
+		{&Struct{Fields: []Field{{"x", Int}}}, &Struct{Fields: []Field{{"x", Int}}}, true},
+		{&Struct{Fields: []Field{{"x", Int}}}, &Struct{Fields: []Field{{"y", Int}}}, false},
+		{&Optional{Int}, &Optional{Int}, true},
+		{&Optional{Int}, Int, false},
+	}
+
+	for _, tt := range tests {
+		if got := Identical(tt.a, tt.b); got != tt.expected {
+			t.Errorf("Identical(%s, %s): expected %t, got %t", tt.a, tt.b, tt.expected, got)
+		}
+	}
+}
+
+func TestAssignableTo(t *testing.T) {
+	tests := []struct {
+		value, target Type
+		expected bool
+	}{
+		{Int, Int, true},
+		{Int, Float, false},
+		{Unknown, Str, true},
+		{Bool, Unknown, true},
+		{AnyList, &List{Int}, true},
+		{&List{Int}, AnyList, true},
+		{&List{Int}, &List{Str}, false},
+		{&List{&List{Int}}, &List{AnyList}, true},
+		{Int, &Optional{Int}, true},
+		{&Optional{Int}, &Optional{Int}, true},
+		{&Optional{Int}, Int, false},
+		{Str, &Optional{Int}, false},
+		{&Map{Str, Int}, &Map{Str, Float}, false},
+	}
+
+	for _, tt := range tests {
+		if got := AssignableTo(tt.value, tt.target); got != tt.expected {
+			t.Errorf("AssignableTo(%s, %s): expected %t, got %t", tt.value, tt.target, tt.expected, got)
+		}
+	}
+}
+
+func TestErase(t *testing.T) {
+	if Erase(&List{Int}) != AnyList || Erase(Int) != Int {
+		t.Error("expected typed lists to erase to list and other types to stay")
+	}
+}
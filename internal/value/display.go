@@ -0,0 +1,121 @@
+package value
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Display returns the canonical text of a value, the same on every backend:
+// what say prints and format inserts. Lists print as [1, 2, 3] with the
+// strings inside them quoted; floats always show a fraction or an exponent.
+func Display(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	return Repr(v)
+}
+
+// the canonical text of a value with strings quoted, as inside a list
+func Repr(v any) string {
+	var b strings.Builder
+	writeRepr(&b, v)
+	return b.String()
+}
+
+func writeRepr(b *strings.Builder, v any) {
+	switch v := v.(type) {
+	case int: b.WriteString(strconv.Itoa(v))
+	case float64: b.WriteString(FormatFloat(v))
+	case bool: b.WriteString(strconv.FormatBool(v))
+	case string: b.WriteString(Quote(v))
+	case []any:
+		b.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			writeRepr(b, element)
+		}
+		b.WriteByte(']')
+	}
+}
+
+// Quote wraps s in double quotes, escaping quotes, backslashes, newlines,
+// carriage returns and tabs; every other byte is written as is.
+func Quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\': b.WriteByte('\\'); b.WriteByte(c)
+		case '\n': b.WriteString(`\n`)
+		case '\r': b.WriteString(`\r`)
+		case '\t': b.WriteString(`\t`)
+		default: b.WriteByte(c)
+		}
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
+
+// FormatFloat writes the shortest digits that parse back to f. Exponents
+// from -4 to 15 are written out in full, so every integer a float holds
+// exactly prints without an exponent, and with a ".0" to tell it from an
+// int; anything else uses the form 1.5e+16.
+func FormatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f): return "NaN"
+	case math.IsInf(f, 1): return "+Inf"
+	case math.IsInf(f, -1): return "-Inf"
+	}
+
+	exponent := strconv.FormatFloat(f, 'e', -1, 64)
+	exp, _ := strconv.Atoi(exponent[strings.IndexByte(exponent, 'e')+1:])
+
+	if exp < -4 || exp >= 16 {
+		return exponent
+	}
+
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+
+	return s
+}
+
+// SplitFormat splits the template of a format call at its {} placeholders,
+// returning one more piece than there are placeholders. {{ and }} stand for
+// literal braces.
+func SplitFormat(template string) ([]string, error) {
+	pieces := []string{}
+	var piece strings.Builder
+
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		next := byte(0)
+		if i+1 < len(template) {
+			next = template[i+1]
+		}
+
+		switch {
+		case c == '{' && next == '}':
+			pieces = append(pieces, piece.String())
+			piece.Reset()
+			i++
+		case (c == '{' || c == '}') && next == c:
+			piece.WriteByte(c)
+			i++
+		case c == '{': return nil, errors.New("unclosed '{' in format string, write '{{' for a literal brace")
+		case c == '}': return nil, errors.New("unmatched '}' in format string, write '}}' for a literal brace")
+		default: piece.WriteByte(c)
+		}
+	}
+
+	return append(pieces, piece.String()), nil
+}
@@ -0,0 +1,69 @@
+package value
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDisplay(t *testing.T) {
+	tests := []struct {
+		value any
+		expected string
+	}{
+		{"plain", "plain"},
+		{-3, "-3"},
+		{true, "true"},
+		{2.0, "2.0"},
+		{-0.5, "-0.5"},
+		{math.Copysign(0, -1), "-0.0"},
+		{1e6, "1000000.0"},
+		{123456789012345.6, "123456789012345.6"},
+		{1e16, "1e+16"},
+		{0.0001, "0.0001"},
+		{0.00001, "1e-05"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "+Inf"},
+		{[]any{}, "[]"},
+		{[]any{1, "a\"b\\", 2.5, []any{"x\ty", false}}, `[1, "a\"b\\", 2.5, ["x\ty", false]]`},
+	}
+
+	for _, tt := range tests {
+		if got := Display(tt.value); got != tt.expected {
+			t.Errorf("Display(%#v): expected %q, got %q", tt.value, tt.expected, got)
+		}
+	}
+
+	if got := Repr("a\nb"); got != `"a\nb"` {
+		t.Errorf("Repr quotes strings, got %q", got)
+	}
+}
+
+func TestSplitFormat(t *testing.T) {
+	tests := []struct {
+		template string
+		expected []string
+		err string
+	}{
+		{"no placeholders", []string{"no placeholders"}, ""},
+		{"{} and {}", []string{"", " and ", ""}, ""},
+		{"{{}} {}", []string{"{} ", ""}, ""},
+		{"{x}", nil, "unclosed '{'"},
+		{"a } b", nil, "unmatched '}'"},
+	}
+
+	for _, tt := range tests {
+		pieces, err := SplitFormat(tt.template)
+
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%q: expected an error containing %q, got %v", tt.template, tt.err, err)
+			}
+			continue
+		}
+
+		if err != nil || strings.Join(pieces, "|") != strings.Join(tt.expected, "|") || len(pieces) != len(tt.expected) {
+			t.Errorf("%q: expected %q, got %q (%v)", tt.template, tt.expected, pieces, err)
+		}
+	}
+}
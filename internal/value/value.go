@@ -0,0 +1,153 @@
+// Package value implements the runtime semantics of SimpleScript values
+// shared by the interpreter and the bytecode VM. Values are plain Go values:
+// int, float64, string, bool and []any for lists, matching the Go backend.
+package value
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrDivideByZero = errors.New("integer divide by zero")
+	ErrOverflow = errors.New("integer overflow")
+)
+
+// the SimpleScript name of a runtime value's type
+func TypeName(v any) string {
+	switch v.(type) {
+	case int: return "int"
+	case float64: return "float"
+	case string: return "str"
+	case bool: return "bool"
+	case []any: return "list"
+	default: return "unknown"
+	}
+}
+
+// checks that a value without a static type, like a list element, holds
+// a value of the type named typeName
+func Check(v any, typeName string) error {
+	if TypeName(v) != typeName {
+		return fmt.Errorf("type mismatch: cannot use %s as %s", TypeName(v), typeName)
+	}
+
+	return nil
+}
+
+func Negate(v any) (any, error) {
+	switch n := v.(type) {
+	case int:
+		if n == math.MinInt {
+			return nil, ErrOverflow
+		}
+		return -n, nil
+	case float64: return -n, nil
+	}
+
+	return nil, fmt.Errorf("invalid operation: cannot use '-' on %s", TypeName(v))
+}
+
+// applies an arithmetic or comparison operator
+func Binary(op string, left, right any) (any, error) {
+	switch l := left.(type) {
+	case int:
+		if r, ok := right.(int); ok {
+			if op == "/" && r == 0 {
+				return nil, ErrDivideByZero
+			}
+
+			if result, ok := arithmetic(op, l, r); ok {
+				if n, isInt := result.(int); isInt && overflows(op, l, r, n) {
+					return nil, ErrOverflow
+				}
+
+				return result, nil
+			}
+		}
+	case float64:
+		if r, ok := right.(float64); ok {
+			if result, ok := arithmetic(op, l, r); ok {
+				return result, nil
+			}
+		}
+	case string:
+		if r, ok := right.(string); ok {
+			if op == "+" {
+				return l + r, nil
+			}
+
+			if result, ok := compare(op, l, r); ok {
+				return result, nil
+			}
+		}
+	}
+
+	_, leftIsList := left.([]any)
+	_, rightIsList := right.([]any)
+
+	if (op == "==" || op == "!=") && !leftIsList && !rightIsList {
+		return (left == right) == (op == "=="), nil
+	}
+
+	return nil, fmt.Errorf("invalid operation '%s %s %s'", TypeName(left), op, TypeName(right))
+}
+
+// returns the list and checks that the index is within its bounds
+func CheckIndex(target any, index any) ([]any, int, error) {
+	if err := Check(target, "list"); err != nil {
+		return nil, 0, err
+	}
+	if err := Check(index, "int"); err != nil {
+		return nil, 0, err
+	}
+
+	list, i := target.([]any), index.(int)
+
+	if i < 0 || i >= len(list) {
+		return nil, 0, fmt.Errorf("index %d out of range for list of length %d", i, len(list))
+	}
+
+	return list, i, nil
+}
+
+// reports whether an int operator wrapped around instead of producing result
+func overflows(op string, l, r, result int) bool {
+	switch op {
+	case "+": return (r > 0 && result < l) || (r < 0 && result > l)
+	case "-": return (r > 0 && result > l) || (r < 0 && result < l)
+	case "*": return l != 0 && (result/l != r || (l == -1 && r == math.MinInt))
+	case "/": return l == math.MinInt && r == -1
+	}
+
+	return false
+}
+
+type number interface {
+	int | float64
+}
+
+func arithmetic[T number](op string, l, r T) (any, bool) {
+	switch op {
+	case "+": return l + r, true
+	case "-": return l - r, true
+	case "*": return l * r, true
+	case "/": return l / r, true
+	}
+
+	return compare(op, l, r)
+}
+
+func compare[T number | string](op string, l, r T) (any, bool) {
+	switch op {
+	case "==": return l == r, true
+	case "!=": return l != r, true
+	case "<": return l < r, true
+	case "<=": return l <= r, true
+	case ">": return l > r, true
+	case ">=": return l >= r, true
+	}
+
+	return nil, false
+}
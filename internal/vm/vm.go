@@ -0,0 +1,224 @@
+package vm
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"simplescript/internal/bytecode"
+	"simplescript/internal/value"
+)
+
+// an error raised while executing bytecode, located with the chunk's position table
+type RuntimeError struct {
+	File string
+	Position bytecode.Position
+	Message string
+}
+
+func (e *RuntimeError) Error() string {
+	return fmt.Sprintf(
+		"RuntimeError: %s at %s:%d:%d",
+		e.Message,
+		e.File,
+		e.Position.Line,
+		e.Position.Col,
+	)
+}
+
+// a stack machine executing one verified chunk
+type VM struct {
+	chunk *bytecode.Chunk
+	stack []any
+	locals []any
+	frames []callFrame
+	ip int
+	out io.Writer
+	args []string
+}
+
+// what OP_RETURN restores in the caller
+type callFrame struct {
+	ip int
+	locals []any
+}
+
+// the deepest chain of calls a program may make before it is stopped
+const maxCallDepth = 100000
+
+func NewVM(chunk *bytecode.Chunk, out io.Writer) *VM {
+	return &VM{
+		chunk: chunk,
+		stack: make([]any, 0, 256),
+		locals: make([]any, chunk.NumLocals),
+		out: out,
+	}
+}
+
+// sets the command-line arguments OP_ARGS pushes
+func (vm *VM) WithArgs(args []string) *VM {
+	vm.args = args
+	return vm
+}
+
+func (vm *VM) Run() (err error) {
+	code := vm.chunk.Code
+
+	// verified chunks are well formed, but a hand-edited one can still unbalance the stack
+	defer func() {
+		if r := recover(); r != nil {
+			err = vm.error(min(vm.ip, len(code)-1), fmt.Errorf("invalid bytecode: %v", r))
+		}
+	}()
+
+	for vm.ip < len(code) {
+		start := vm.ip
+		op := bytecode.Opcode(code[vm.ip])
+		operand := 0
+
+		if op.Width() > 1 {
+			operand = vm.chunk.Operand(vm.ip + 1)
+		}
+
+		vm.ip += op.Width()
+
+		switch op {
+		case bytecode.OP_CONSTANT:
+			vm.push(vm.chunk.Constants[operand])
+		case bytecode.OP_GET_LOCAL:
+			vm.push(vm.locals[operand])
+		case bytecode.OP_SET_LOCAL:
+			vm.locals[operand] = vm.pop()
+		case bytecode.OP_LIST:
+			elements := make([]any, operand)
+			copy(elements, vm.stack[len(vm.stack)-operand:])
+			vm.stack = vm.stack[:len(vm.stack)-operand]
+			vm.push(elements)
+		case bytecode.OP_INDEX:
+			index := vm.pop()
+			list, i, err := value.CheckIndex(vm.pop(), index)
+			if err != nil {
+				return vm.error(start, err)
+			}
+
+			vm.push(list[i])
+		case bytecode.OP_SET_INDEX:
+			val := vm.pop()
+			index := vm.pop()
+			list, i, err := value.CheckIndex(vm.pop(), index)
+			if err != nil {
+				return vm.error(start, err)
+			}
+
+			list[i] = val
+		case bytecode.OP_NEGATE:
+			result, err := value.Negate(vm.pop())
+			if err != nil {
+				return vm.error(start, err)
+			}
+
+			vm.push(result)
+		case bytecode.OP_SAY:
+			args := make([]any, operand)
+			copy(args, vm.stack[len(vm.stack)-operand:])
+			vm.stack = vm.stack[:len(vm.stack)-operand]
+			texts := make([]string, operand)
+			for i, arg := range args {
+				texts[i] = value.Display(arg)
+			}
+
+			fmt.Fprintln(vm.out, strings.Join(texts, " "))
+		case bytecode.OP_DISPLAY:
+			vm.push(value.Display(vm.pop()))
+		case bytecode.OP_PRINT:
+			fmt.Fprint(vm.out, vm.pop())
+		case bytecode.OP_ARGS:
+			args := []any{}
+			for _, arg := range vm.args {
+				args = append(args, arg)
+			}
+			vm.push(args)
+		case bytecode.OP_ENV:
+			name := vm.pop()
+			if err := value.Check(name, "str"); err != nil {
+				return vm.error(start, err)
+			}
+
+			vm.push(os.Getenv(name.(string)))
+		case bytecode.OP_CHECK:
+			if err := value.Check(vm.peek(), bytecode.CheckTypes[operand]); err != nil {
+				return vm.error(start, err)
+			}
+		case bytecode.OP_JUMP:
+			vm.ip += operand
+		case bytecode.OP_JUMP_IF_FALSE:
+			if vm.pop() != true {
+				vm.ip += operand
+			}
+		case bytecode.OP_LOOP:
+			vm.ip -= operand
+		case bytecode.OP_CALL:
+			fn := vm.chunk.Functions[operand]
+			if len(vm.frames) == maxCallDepth {
+				return vm.error(start, fmt.Errorf("stack overflow: more than %d nested calls", maxCallDepth))
+			}
+
+			locals := make([]any, fn.NumLocals)
+			copy(locals, vm.stack[len(vm.stack)-fn.NumParams:])
+			vm.stack = vm.stack[:len(vm.stack)-fn.NumParams]
+
+			vm.frames = append(vm.frames, callFrame{ip: vm.ip, locals: vm.locals})
+			vm.locals = locals
+			vm.ip = fn.Entry
+		case bytecode.OP_RETURN:
+			caller := vm.frames[len(vm.frames)-1]
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			vm.ip, vm.locals = caller.ip, caller.locals
+		case bytecode.OP_HALT:
+			return nil
+		default:
+			right := vm.pop()
+			left := vm.pop()
+
+			result, err := value.Binary(binaryOperators[op], left, right)
+			if err != nil {
+				return vm.error(start, err)
+			}
+
+			vm.push(result)
+		}
+	}
+
+	return nil
+}
+
+var binaryOperators = map[bytecode.Opcode]string{}
+
+func init() {
+	for operator, op := range bytecode.BinaryOpcodes {
+		binaryOperators[op] = operator
+	}
+}
+
+func (vm *VM) push(v any) {
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *VM) pop() any {
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return v
+}
+
+func (vm *VM) peek() any {
+	return vm.stack[len(vm.stack)-1]
+}
+
+func (vm *VM) error(offset int, err error) error {
+	return &RuntimeError{
+		File: vm.chunk.File,
+		Position: vm.chunk.Positions[offset],
+		Message: err.Error(),
+	}
+}
@@ -0,0 +1,93 @@
+package vm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"simplescript/internal/backend"
+	"simplescript/internal/testutil"
+)
+
+func run(t *testing.T, input string) (string, error) {
+	t.Helper()
+
+	chunk, err := backend.CompileBytecode(testutil.Lower(t, input))
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	var out bytes.Buffer
+	err = NewVM(chunk, &out).WithArgs([]string{"one", "two"}).Run()
+	return out.String(), err
+}
+
+func TestPrograms(t *testing.T) {
+	tests := []struct {
+		name string
+		input string
+		expected string
+	}{
+		{"arithmetic", `say((10 + 20) * 2, 7 / 2, 0.5 * 3.0, -4)`, "60 3 1.5 -4\n"},
+		{"lists", `var xs: list = [1, "a", true] xs[1] = 2.5 say(xs, xs[0])`, "[1, 2.5, true] 1\n"},
+		{"swap", `var a: int = 1 var b: int = 2 a, b = b, a say(a, b)`, "2 1\n"},
+		{"augmented index", `var xs: list = [1, 2] var i: int = 1 xs[i] += 5 say(xs)`, "[1, 7]\n"},
+		{"shadowing", `var x: int = 1 if true { var x: str = "inner" say(x) } say(x)`, "inner\n1\n"},
+		{
+			"loops",
+			`var sum: int = 0
+			for i in 0..10 {
+				if i == 2 { continue }
+				if i == 5 { break }
+				for j in 0..i { sum += 1 }
+			}
+			say(sum)`,
+			"8\n",
+		},
+		{"return ends the program", `say(1) return 0 say(2)`, "1\n"},
+		{"args and env", `var a: list = args() say(a, a[1] + "!", env("SS_TEST_UNSET") == "")`, "[\"one\", \"two\"] two! true\n"},
+		{
+			"functions",
+			`say(fib(10), first([1.5]))
+			log("done")
+			func fib(n: int) int { if n < 2 { return n } return fib(n - 1) + fib(n - 2) }
+			func first(xs: list) float { return xs[0] }
+			func log(s: str) { if s == "" { return } say(s) }`,
+			"55 1.5\ndone\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := run(t, tt.input)
+			if err != nil {
+				t.Fatalf("unexpected runtime error: %v", err)
+			}
+
+			if out != tt.expected {
+				t.Errorf("unexpected output.\nExpected: %q\nGot: %q", tt.expected, out)
+			}
+		})
+	}
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string
+	}{
+		{"var z: int = 0\nsay(1 / z)", "RuntimeError: integer divide by zero at test.ss:2:5"},
+		{"var xs: list = [1, 2, 3]\nsay(xs[5])", "RuntimeError: index 5 out of range for list of length 3 at test.ss:2:5"},
+		{"var n: int = 9223372036854775807\nsay(n * 2)", "RuntimeError: integer overflow at test.ss:2:5"},
+		{"var xs: list = [1]\nsay(env(xs[0]))", "RuntimeError: type mismatch: cannot use int as str at test.ss:2:5"},
+		{"say(f([1]))\nfunc f(xs: list) str {\n\treturn xs[0]\n}", "RuntimeError: type mismatch: cannot use int as str at test.ss:3:2"},
+		{"f(1)\nfunc f(n: int) { f(n + 1) }", "RuntimeError: stack overflow: more than 100000 nested calls"},
+	}
+
+	for _, tt := range tests {
+		_, err := run(t, tt.input)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("unexpected error.\nExpected: %q\nGot: %v", tt.expected, err)
+		}
+	}
+}